@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+const defaultAnalysisDepth = 16
+
+// Move classifications, by the drop in winning chances they cause.
+const (
+	MoveBest       = "best"
+	MoveExcellent  = "excellent"
+	MoveGood       = "good"
+	MoveInaccuracy = "inaccuracy"
+	MoveMistake    = "mistake"
+	MoveBlunder    = "blunder"
+)
+
+// Win-percentage loss thresholds for the classifications above, as used
+// by common game-review tools.
+const (
+	excellentThreshold  = 2.0
+	inaccuracyThreshold = 10.0
+	mistakeThreshold    = 20.0
+	blunderThreshold    = 30.0
+)
+
+// Centipawn losses are computed on scores capped at ±cpLossCap so that a
+// missed mate does not report a loss of 100000 centipawns.
+const cpLossCap = 1000
+
+// winPercent maps a side-to-move score to the chance of winning, using the
+// logistic fit of game outcomes to Stockfish evaluations.
+func winPercent(line *InfoLine) float64 {
+	switch {
+	case line.Mate != nil && *line.Mate > 0:
+		return 100
+	case line.Mate != nil:
+		return 0
+	case line.ScoreCP == nil:
+		return 50
+	}
+	cp := math.Max(-cpLossCap, math.Min(cpLossCap, float64(*line.ScoreCP)))
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*cp))-1)
+}
+
+// pvToSAN converts a UCI principal variation to SAN, stopping at the first
+// move that is not legal.
+func pvToSAN(p *Position, pv []string) []string {
+	san := []string{}
+	for _, s := range pv {
+		m, err := p.ParseUCI(s)
+		if err != nil {
+			break
+		}
+		san = append(san, p.SAN(m))
+		p = p.Play(m)
+	}
+	return san
+}
+
+// MoveEval is the engine's verdict on one root move. Scores are from the
+// point of view of the side to move.
+type MoveEval struct {
+	UCI        string   `json:"uci"`
+	SAN        string   `json:"san"`
+	ScoreCP    *int     `json:"score_cp,omitempty"`
+	Mate       *int     `json:"mate,omitempty"`
+	WDL        *WDL     `json:"wdl,omitempty"`
+	WinPercent float64  `json:"win_percent"`
+	PV         []string `json:"pv"`
+}
+
+type MoveCheckResult struct {
+	Status         string   `json:"status"`
+	FEN            string   `json:"fen"`
+	Engine         string   `json:"engine"`
+	Move           MoveEval `json:"move"`
+	Best           MoveEval `json:"best"`
+	CPLoss         int      `json:"cp_loss"`
+	WinPercentLoss float64  `json:"win_percent_loss"`
+	Classification string   `json:"classification"`
+	// Outlook states the position's WDL after the best move.
+	Outlook string `json:"outlook,omitempty"`
+	// Refutation is the opponent's best answer to a bad move, in SAN.
+	Refutation []string `json:"refutation,omitempty"`
+}
+
+// Analyzer runs one-off engine searches for the analysis tools.
+type Analyzer struct {
+	engines        *EngineRegistry
+	maxSessions    int
+	commandTimeout time.Duration
+	logger         zerolog.Logger
+}
+
+func newAnalyzer(
+	engines *EngineRegistry,
+	maxSessions int,
+	commandTimeout time.Duration,
+	logger zerolog.Logger,
+) *Analyzer {
+	return &Analyzer{
+		engines:        engines,
+		maxSessions:    maxSessions,
+		commandTimeout: commandTimeout,
+		logger:         logger.With().Str("component", ComponentAnalyzer).Logger(),
+	}
+}
+
+// analysisLimits builds a fixed-depth or fixed-time search.
+func analysisLimits(depth, moveTimeMS int) uci.Limits {
+	if moveTimeMS > 0 {
+		return uci.Limits{MoveTime: time.Duration(moveTimeMS) * time.Millisecond}
+	}
+	if depth <= 0 {
+		depth = defaultAnalysisDepth
+	}
+	return uci.Limits{Depth: depth}
+}
+
+func (a *Analyzer) timeout(moveTimeMS int) time.Duration {
+	return a.commandTimeout + time.Duration(moveTimeMS)*time.Millisecond
+}
+
+// CheckMove compares a move against the engine's best move: one search over
+// all moves and one restricted to the move with "searchmoves".
+func (a *Analyzer) CheckMove(
+	ctx context.Context,
+	game *Game,
+	move string,
+	engineName string,
+	depth, moveTimeMS int,
+) (*MoveCheckResult, error) {
+	pos := game.Position()
+	m, err := pos.ParseMove(move)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := a.engines.Get(engineName)
+	if err != nil {
+		return nil, err
+	}
+	release, err := a.engines.reserve(ctx, 1, PriorityInteractive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	player := &enginePlayer{config: engine, logger: a.logger}
+	defer player.close()
+
+	limits := analysisLimits(depth, moveTimeMS)
+	timeout := a.timeout(moveTimeMS)
+
+	best, err := player.search(ctx, game.PositionCommand(), limits, timeout)
+	if err != nil {
+		return nil, err
+	}
+	best.fillWDL(pos)
+	bestLine := best.Best()
+	if bestLine == nil {
+		return nil, fmt.Errorf("engine reported no evaluation")
+	}
+
+	moveLine := bestLine
+	if len(bestLine.PV) == 0 || bestLine.PV[0] != pos.UCI(m) {
+		limits.SearchMoves = []string{pos.UCI(m)}
+		restricted, err := player.search(ctx, game.PositionCommand(), limits, timeout)
+		if err != nil {
+			return nil, err
+		}
+		restricted.fillWDL(pos)
+		if moveLine = restricted.Best(); moveLine == nil {
+			return nil, fmt.Errorf("engine reported no evaluation for %s", move)
+		}
+	}
+
+	bestMove, err := pos.ParseUCI(best.BestMove)
+	if err != nil {
+		return nil, fmt.Errorf("engine returned an illegal best move: %w", err)
+	}
+
+	result := &MoveCheckResult{
+		Status: "success",
+		FEN:    pos.FEN(),
+		Engine: engine.Name,
+		Move:   moveEval(pos, m, moveLine),
+		Best:   moveEval(pos, bestMove, bestLine),
+	}
+	if moveLine != bestLine {
+		capped := func(l *InfoLine) int {
+			return max(-cpLossCap, min(cpLossCap, l.scoreValue()))
+		}
+		result.CPLoss = max(0, capped(bestLine)-capped(moveLine))
+		result.WinPercentLoss = round1(math.Max(0, result.Best.WinPercent-result.Move.WinPercent))
+	}
+	result.Classification = classifyMove(m == bestMove, result.WinPercentLoss)
+	if bestLine.WDL != nil {
+		result.Outlook = bestLine.WDL.outlook(pos.Turn())
+	}
+
+	switch result.Classification {
+	case MoveInaccuracy, MoveMistake, MoveBlunder:
+		if len(result.Move.PV) > 1 {
+			result.Refutation = result.Move.PV[1:]
+		}
+	}
+	return result, nil
+}
+
+func moveEval(pos *Position, m Move, line *InfoLine) MoveEval {
+	pv := line.PV
+	if len(pv) == 0 || pv[0] != pos.UCI(m) {
+		pv = []string{pos.UCI(m)}
+	}
+	return MoveEval{
+		UCI:        pos.UCI(m),
+		SAN:        pos.SAN(m),
+		ScoreCP:    line.ScoreCP,
+		Mate:       line.Mate,
+		WDL:        line.WDL,
+		WinPercent: round1(winPercent(line)),
+		PV:         pvToSAN(pos, pv),
+	}
+}
+
+func classifyMove(isBest bool, winPercentLoss float64) string {
+	switch {
+	case isBest:
+		return MoveBest
+	case winPercentLoss >= blunderThreshold:
+		return MoveBlunder
+	case winPercentLoss >= mistakeThreshold:
+		return MoveMistake
+	case winPercentLoss >= inaccuracyThreshold:
+		return MoveInaccuracy
+	case winPercentLoss < excellentThreshold:
+		return MoveExcellent
+	}
+	return MoveGood
+}
+
+func (a *Analyzer) handleCheckMove(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	move, err := request.RequireString("move")
+	if err != nil {
+		return mcp.NewToolResultError("Missing 'move' parameter"), nil
+	}
+	fen := request.GetString("fen", startFEN)
+	moves := request.GetStringSlice("moves", nil)
+	engineName := request.GetString("engine", "")
+
+	a.logger.Info().
+		Str("fen", fen).
+		Int("moves", len(moves)).
+		Str("move", move).
+		Str("engine", engineName).
+		Msg("Received move check")
+
+	game, err := newGameFromMoves(fen, moves)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid position: %s", err.Error())), nil
+	}
+	if result, reason := game.Outcome(); result != ResultOngoing {
+		return mcp.NewToolResultError(fmt.Sprintf("Game is over: %s (%s)", result, reason)), nil
+	}
+
+	result, err := a.CheckMove(
+		ctx,
+		game,
+		strings.TrimSpace(move),
+		engineName,
+		request.GetInt("depth", 0),
+		request.GetInt("movetime", 0),
+	)
+	if err != nil {
+		a.logger.Warn().Err(err).Str("move", move).Msg("Move check failed")
+		return mcp.NewToolResultError(fmt.Sprintf("Move check failed: %s", err.Error())), nil
+	}
+	return jsonToolResult(result, a.logger)
+}
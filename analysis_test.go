@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestCheckMoveTakesEngineSlot(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	config.QueueMaxWait = 0
+	engines := newEngineRegistry(config, zerolog.Nop())
+	analyzer := newAnalyzer(engines, config.MaxSessions, config.CommandTimeout, zerolog.Nop())
+	game, err := newGameFromMoves("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := analyzer.CheckMove(context.Background(), game, "e4", "", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if free := engines.slots.free(); free != config.MaxSessions {
+		t.Errorf("%d of %d engine slots free after the check", free, config.MaxSessions)
+	}
+
+	release, err := engines.reserve(context.Background(), config.MaxSessions, PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	_, err = analyzer.CheckMove(context.Background(), game, "e4", "", 1, 0)
+	if err == nil || !strings.Contains(err.Error(), "maximum number of engines") {
+		t.Errorf("check without a free engine: %v", err)
+	}
+}
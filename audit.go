@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog"
+)
+
+const (
+	auditBackupTimeFormat = "20060102T150405.000000000"
+	redactedFEN           = "[fen redacted]"
+)
+
+// fenPattern matches FENs, with or without the move counters, anywhere in
+// a string.
+var fenPattern = regexp.MustCompile(
+	`[1-8pnbrqkPNBRQK]{1,8}(?:/[1-8pnbrqkPNBRQK]{1,8}){7} [wb] (?:-|[KQkqA-Ha-h]{1,4}) (?:-|[a-h][36])(?: \d+ \d+)?`,
+)
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	Time            time.Time      `json:"time"`
+	Client          string         `json:"client"`
+	SessionID       string         `json:"session_id,omitempty"`
+	Tool            string         `json:"tool"`
+	Arguments       map[string]any `json:"arguments,omitempty"`
+	Status          string         `json:"status"`
+	DurationMS      int64          `json:"duration_ms"`
+	Output          string         `json:"output"`
+	OutputTruncated bool           `json:"output_truncated,omitempty"`
+}
+
+// AuditLog records every tool call as a JSON line, apart from the
+// diagnostic log.
+type AuditLog struct {
+	config AuditConfig
+	mu     sync.Mutex
+	writer *rotatingFile
+	logger zerolog.Logger
+}
+
+func newAuditLog(config AuditConfig, logger zerolog.Logger) (*AuditLog, error) {
+	writer, err := openRotatingFile(config.Path, config.MaxSize, config.MaxAge, config.MaxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLog{
+		config: config,
+		writer: writer,
+		logger: logger.With().Str("component", ComponentAudit).Logger(),
+	}, nil
+}
+
+// middleware records the calls of every tool handler.
+func (a *AuditLog) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started := time.Now()
+		result, err := next(ctx, request)
+		a.record(ctx, request, result, err, started)
+		return result, err
+	}
+}
+
+func (a *AuditLog) record(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+	result *mcp.CallToolResult,
+	callErr error,
+	started time.Time,
+) {
+	entry := AuditEntry{
+		Time:       started.UTC(),
+		Client:     clientIdentity(ctx),
+		Tool:       request.Params.Name,
+		Arguments:  request.GetArguments(),
+		Status:     "success",
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	entry.SessionID, _ = entry.Arguments["session_id"].(string)
+
+	switch {
+	case callErr != nil:
+		entry.Status = "error"
+		entry.Output = callErr.Error()
+	case result != nil:
+		entry.Output = toolResultText(result)
+		// Tools report failures inside their JSON as well as with IsError.
+		var reply struct {
+			Status    string `json:"status"`
+			SessionID string `json:"session_id"`
+		}
+		if json.Unmarshal([]byte(entry.Output), &reply) == nil {
+			if reply.Status != "" {
+				entry.Status = reply.Status
+			}
+			if reply.SessionID != "" {
+				entry.SessionID = reply.SessionID
+			}
+		}
+		if result.IsError {
+			entry.Status = "error"
+		}
+	}
+
+	if a.config.RedactFEN {
+		entry.Arguments, _ = redactFENs(entry.Arguments).(map[string]any)
+		entry.Output = fenPattern.ReplaceAllString(entry.Output, redactedFEN)
+	}
+	if limit := a.config.MaxOutput; limit > 0 && len(entry.Output) > limit {
+		entry.Output = strings.ToValidUTF8(entry.Output[:limit], "")
+		entry.OutputTruncated = true
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		a.logger.Error().Err(err).Str("tool", entry.Tool).Msg("Failed to marshal audit entry")
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.writer.Write(append(line, '\n')); err != nil {
+		a.logger.Error().Err(err).Str("tool", entry.Tool).Msg("Failed to write audit entry")
+	}
+}
+
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.writer.Close()
+}
+
+// toolResultText joins the text contents of a tool result.
+func toolResultText(result *mcp.CallToolResult) string {
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// redactFENs replaces the FENs in the strings of a decoded JSON value.
+func redactFENs(v any) any {
+	switch v := v.(type) {
+	case string:
+		return fenPattern.ReplaceAllString(v, redactedFEN)
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, value := range v {
+			redacted[key] = redactFENs(value)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, value := range v {
+			redacted[i] = redactFENs(value)
+		}
+		return redacted
+	}
+	return v
+}
+
+// clientIdentity names the client of a tool call: the authenticated
+// caller, or else its MCP session.
+func clientIdentity(ctx context.Context) string {
+	if p := principalFromContext(ctx); p != nil {
+		return p.Name
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return "unknown"
+}
+
+// rotatingFile is an append-only file that is moved aside to
+// <path>.<timestamp> when it reaches maxSize bytes or has been written to
+// for maxAge. Only the newest maxBackups of those are kept; zero keeps all.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size, r.openedAt = file, info.Size(), time.Now()
+	return nil
+}
+
+// Write appends p, rotating the file first when it is due. When rotation
+// fails, p still goes to the current file and the rotation error is
+// returned with the write's.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	var rotateErr error
+	full := r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize
+	old := r.maxAge > 0 && r.size > 0 && time.Since(r.openedAt) >= r.maxAge
+	if full || old {
+		if rotateErr = r.rotate(); rotateErr != nil {
+			rotateErr = fmt.Errorf("failed to rotate %s: %w", r.path, rotateErr)
+			if r.file == nil {
+				return 0, rotateErr
+			}
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, errors.Join(rotateErr, err)
+}
+
+// rotate moves the file aside and opens a new one. On failure the current
+// file is reopened, so that entries keep being written to it; r.file is
+// nil only when that fails too, and the next Write tries again.
+func (r *rotatingFile) rotate() error {
+	if err := r.moveAside(); err != nil {
+		if reopenErr := r.open(); reopenErr != nil {
+			r.file = nil
+			return errors.Join(err, reopenErr)
+		}
+		return err
+	}
+	return r.prune()
+}
+
+func (r *rotatingFile) moveAside() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	backup := r.path + "." + time.Now().UTC().Format(auditBackupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		// Put the file back for rotate to reopen it.
+		_ = os.Rename(backup, r.path)
+		return err
+	}
+	return nil
+}
+
+// prune removes the oldest backups beyond maxBackups. Backup names sort by
+// their timestamp.
+func (r *rotatingFile) prune() error {
+	if r.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > r.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func (r *rotatingFile) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
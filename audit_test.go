@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+const auditFEN = "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"
+
+func readAuditLog(t *testing.T, path string) []AuditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		entries = append(entries, decode[AuditEntry](t, scanner.Text()))
+	}
+	return entries
+}
+
+func TestAuditLogRecordsToolCalls(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	handler := newTestHandler(t, config, true)
+	audit := AuditConfig{
+		Path:      filepath.Join(t.TempDir(), "audit.log"),
+		MaxOutput: 60,
+		RedactFEN: true,
+	}
+	auditLog, err := newAuditLog(audit, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := auditLog.middleware(handler.handle)
+
+	text, _ := callTool(t, handle, map[string]any{"command": "position fen " + auditFEN})
+	sessionID := decode[CommandResult](t, text).SessionID
+	callTool(t, handle, map[string]any{"command": "eval", "session_id": sessionID})
+	if err := auditLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readAuditLog(t, audit.Path)
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(entries))
+	}
+	first, second := entries[0], entries[1]
+	if first.Status != "success" || first.SessionID != sessionID || first.Client != "unknown" {
+		t.Errorf("first entry = %+v", first)
+	}
+	if first.Arguments["command"] != "position fen "+redactedFEN {
+		t.Errorf("arguments not redacted: %v", first.Arguments)
+	}
+	if !first.OutputTruncated || len(first.Output) > audit.MaxOutput {
+		t.Errorf("output not truncated: %q", first.Output)
+	}
+	if second.Status != "error" || second.SessionID != sessionID || !strings.Contains(second.Output, "unsupported") {
+		t.Errorf("second entry = %+v", second)
+	}
+	data, _ := os.ReadFile(audit.Path)
+	if strings.Contains(string(data), "4k3") {
+		t.Errorf("FEN leaked into audit log:\n%s", data)
+	}
+}
+
+func TestRedactFENs(t *testing.T) {
+	args := map[string]any{
+		"fen":   "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4",
+		"items": []any{map[string]any{"fen": "4k3/8/8/8/8/8/8/4K3 b - -"}},
+		"depth": float64(12),
+	}
+	got := redactFENs(args).(map[string]any)
+	if got["fen"] != redactedFEN || got["depth"] != float64(12) {
+		t.Errorf("redacted = %v", got)
+	}
+	if item := got["items"].([]any)[0].(map[string]any); item["fen"] != redactedFEN {
+		t.Errorf("nested fen = %v", item["fen"])
+	}
+	if args["fen"] == redactedFEN {
+		t.Error("arguments modified in place")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	r, err := openRotatingFile(path, 10, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := r.Write([]byte("0123456\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Errorf("%d backups, want 2: %v", len(backups), backups)
+	}
+
+	r.maxSize, r.maxAge = 0, time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	r.Write([]byte("aged\n"))
+	r.Close()
+	if data, _ := os.ReadFile(path); string(data) != "aged\n" {
+		t.Errorf("current file = %q", data)
+	}
+}
+
+func TestAuditOutputUnlimited(t *testing.T) {
+	handler := newTestHandler(t, testConfig(fakeEngine(t, ucitest.Script{})), true)
+	audit := AuditConfig{Path: filepath.Join(t.TempDir(), "audit.log")}
+	auditLog, err := newAuditLog(audit, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, _ := callTool(t, auditLog.middleware(handler.handle), map[string]any{"command": "uci"})
+	auditLog.Close()
+
+	entries := readAuditLog(t, audit.Path)
+	if len(entries) != 1 || entries[0].OutputTruncated || entries[0].Output != output {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestRotatingFileRecoversFromFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "audit")
+	path := filepath.Join(dir, "audit.log")
+	r, err := openRotatingFile(path, 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Write([]byte("0123456\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotation fails while the directory is gone; later entries are
+	// written once it is back.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("lost\n")); err == nil || !strings.Contains(err.Error(), "failed to rotate") {
+		t.Errorf("write during failed rotation: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("kept\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "kept\n" {
+		t.Errorf("current file = %q", data)
+	}
+}
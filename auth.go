@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog"
+)
+
+// Scopes granted to API keys and tokens. Tools not listed in toolScopes
+// only need an authenticated caller.
+const (
+	ScopeAll     = "*"
+	ScopeUCI     = "uci"     // raw UCI commands through chess_engine
+	ScopeOptions = "options" // setoption through chess_engine
+	ScopeBatch   = "batch"   // tools that run many searches
+)
+
+var toolScopes = map[string]string{
+	"chess_engine":       ScopeUCI,
+	"session_transcript": ScopeUCI,
+	"run_match":          ScopeBatch,
+	"run_test_suite":     ScopeBatch,
+	"analyze_batch":      ScopeBatch,
+	"generate_puzzles":   ScopeBatch,
+}
+
+var (
+	errUnauthenticated = errors.New("missing credentials")
+	errInvalidToken    = errors.New("invalid token")
+)
+
+// Principal is an authenticated caller: an API key or the subject of a
+// bearer token.
+type Principal struct {
+	Name   string
+	Scopes []string
+	// MaxSessions limits the persistent sessions of the caller; zero means
+	// only the global limits apply.
+	MaxSessions int
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAll)
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principalFromContext returns the caller, or nil when authentication is
+// off, as in stdio mode.
+func principalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// APIKey is an entry of the API keys file. The key is given either in
+// clear or as the hex SHA-256 of the key.
+type APIKey struct {
+	Name        string   `json:"name"`
+	Key         string   `json:"key,omitempty"`
+	KeySHA256   string   `json:"key_sha256,omitempty"`
+	Scopes      []string `json:"scopes"`
+	MaxSessions int      `json:"max_sessions,omitempty"`
+}
+
+// Authenticator checks the credentials of HTTP requests: static API keys
+// and HMAC-signed JWT bearer tokens.
+type Authenticator struct {
+	keys        map[[sha256.Size]byte]*Principal
+	tokenSecret []byte
+	issuer      string
+	audience    string
+	logger      zerolog.Logger
+}
+
+// newAuthenticator loads the configured credentials. It returns nil when
+// neither API keys nor a token key are configured.
+func newAuthenticator(config AuthConfig, logger zerolog.Logger) (*Authenticator, error) {
+	if config.KeysFile == "" && config.TokenKeyFile == "" {
+		return nil, nil
+	}
+	a := &Authenticator{
+		keys:     make(map[[sha256.Size]byte]*Principal),
+		issuer:   config.TokenIssuer,
+		audience: config.TokenAudience,
+		logger:   logger.With().Str("component", ComponentAuth).Logger(),
+	}
+
+	if config.KeysFile != "" {
+		if err := a.loadKeys(config.KeysFile); err != nil {
+			return nil, err
+		}
+	}
+	if config.TokenKeyFile != "" {
+		secret, err := os.ReadFile(config.TokenKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token key: %w", err)
+		}
+		a.tokenSecret = bytes.TrimRight(secret, "\r\n")
+		if len(a.tokenSecret) < 32 {
+			return nil, fmt.Errorf("token key %s must be at least 32 bytes", config.TokenKeyFile)
+		}
+	}
+
+	a.logger.Info().
+		Int("api_keys", len(a.keys)).
+		Bool("tokens", a.tokenSecret != nil).
+		Msg("Authentication enabled")
+	return a, nil
+}
+
+func (a *Authenticator) loadKeys(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read API keys: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("invalid API keys file %s: %w", path, err)
+	}
+
+	for i, key := range keys {
+		if key.Name == "" {
+			return fmt.Errorf("API key %d: name must be set", i+1)
+		}
+		var digest [sha256.Size]byte
+		switch {
+		case key.Key != "" && key.KeySHA256 == "":
+			digest = sha256.Sum256([]byte(key.Key))
+		case key.KeySHA256 != "" && key.Key == "":
+			raw, err := hex.DecodeString(key.KeySHA256)
+			if err != nil || len(raw) != sha256.Size {
+				return fmt.Errorf("API key %q: key_sha256 must be a hex SHA-256", key.Name)
+			}
+			copy(digest[:], raw)
+		default:
+			return fmt.Errorf("API key %q: set exactly one of key and key_sha256", key.Name)
+		}
+		if key.MaxSessions < 0 {
+			return fmt.Errorf("API key %q: max_sessions must not be negative", key.Name)
+		}
+		if _, dup := a.keys[digest]; dup {
+			return fmt.Errorf("API key %q is a duplicate", key.Name)
+		}
+		a.keys[digest] = &Principal{Name: key.Name, Scopes: key.Scopes, MaxSessions: key.MaxSessions}
+	}
+	return nil
+}
+
+// Authenticate returns the caller of an HTTP request. Credentials are an
+// "X-API-Key" header or an "Authorization: Bearer" header holding either an
+// API key or a JWT.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		scheme, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			credential = strings.TrimSpace(value)
+		}
+	}
+	if credential == "" {
+		return nil, errUnauthenticated
+	}
+
+	if strings.Count(credential, ".") == 2 && a.tokenSecret != nil {
+		return a.verifyToken(credential, time.Now())
+	}
+	digest := sha256.Sum256([]byte(credential))
+	for known, p := range a.keys {
+		if subtle.ConstantTimeCompare(known[:], digest[:]) == 1 {
+			return p, nil
+		}
+	}
+	return nil, errors.New("invalid API key")
+}
+
+type tokenClaims struct {
+	Subject     string          `json:"sub"`
+	Issuer      string          `json:"iss"`
+	Audience    json.RawMessage `json:"aud"`
+	ExpiresAt   *int64          `json:"exp"`
+	NotBefore   *int64          `json:"nbf"`
+	Scope       string          `json:"scope"`
+	Scopes      []string        `json:"scopes"`
+	MaxSessions int             `json:"max_sessions"`
+}
+
+var tokenHashes = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// verifyToken checks an HMAC-signed JWT against the local key.
+func (a *Authenticator) verifyToken(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return nil, errInvalidToken
+	}
+	newHash, ok := tokenHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", errInvalidToken, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	mac := hmac.New(newHash, a.tokenSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, fmt.Errorf("%w: bad signature", errInvalidToken)
+	}
+
+	var claims tokenClaims
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	switch {
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", errInvalidToken)
+	case claims.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: no expiry", errInvalidToken)
+	case now.Unix() >= *claims.ExpiresAt:
+		return nil, fmt.Errorf("%w: expired", errInvalidToken)
+	case claims.NotBefore != nil && now.Unix() < *claims.NotBefore:
+		return nil, fmt.Errorf("%w: not valid yet", errInvalidToken)
+	case a.issuer != "" && claims.Issuer != a.issuer:
+		return nil, fmt.Errorf("%w: wrong issuer", errInvalidToken)
+	case a.audience != "" && !tokenAudience(claims.Audience, a.audience):
+		return nil, fmt.Errorf("%w: wrong audience", errInvalidToken)
+	}
+
+	scopes := append(strings.Fields(claims.Scope), claims.Scopes...)
+	return &Principal{Name: claims.Subject, Scopes: scopes, MaxSessions: max(claims.MaxSessions, 0)}, nil
+}
+
+func decodeTokenPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// tokenAudience reports whether the "aud" claim, a string or a list of
+// them, contains audience.
+func tokenAudience(raw json.RawMessage, audience string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == audience
+	}
+	var many []string
+	return json.Unmarshal(raw, &many) == nil && slices.Contains(many, audience)
+}
+
+// Middleware rejects HTTP requests without valid credentials and passes
+// the caller on in the request context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			a.logger.Warn().Err(err).Str("remote_addr", r.RemoteAddr).Msg("Rejected request")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp-stockfish"`)
+			http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
+
+// toolMiddleware checks that the caller holds the scopes a tool call needs.
+func (a *Authenticator) toolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		p := principalFromContext(ctx)
+		if p == nil {
+			return next(ctx, request)
+		}
+		for _, scope := range requiredScopes(request) {
+			if !p.HasScope(scope) {
+				a.logger.Warn().
+					Str("client", p.Name).
+					Str("tool", request.Params.Name).
+					Str("scope", scope).
+					Msg("Tool call denied")
+				return mcp.NewToolResultError(fmt.Sprintf("Forbidden: %s requires the %q scope", request.Params.Name, scope)), nil
+			}
+		}
+		return next(ctx, request)
+	}
+}
+
+func requiredScopes(request mcp.CallToolRequest) []string {
+	scope, ok := toolScopes[request.Params.Name]
+	if !ok {
+		return nil
+	}
+	scopes := []string{scope}
+	if request.Params.Name == "chess_engine" {
+		fields := strings.Fields(request.GetString("command", ""))
+		if len(fields) > 0 && strings.ToLower(fields[0]) == StockfishCmdSetOption {
+			scopes = append(scopes, ScopeOptions)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+var testTokenKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestAuthenticator(t *testing.T, keys []APIKey) *Authenticator {
+	t.Helper()
+	dir := t.TempDir()
+	config := AuthConfig{
+		KeysFile:      filepath.Join(dir, "keys.json"),
+		TokenKeyFile:  filepath.Join(dir, "token.key"),
+		TokenAudience: "mcp-stockfish",
+	}
+	data, _ := json.Marshal(keys)
+	if err := os.WriteFile(config.KeysFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.TokenKeyFile, append(testTokenKey, '\n'), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newAuthenticator(config, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func signToken(alg string, key []byte, claims map[string]any) string {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return signed + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func authRequest(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	digest := sha256.Sum256([]byte("hashed-secret"))
+	auth := newTestAuthenticator(t, []APIKey{
+		{Name: "ci", Key: "plain-secret", Scopes: []string{ScopeUCI}, MaxSessions: 2},
+		{Name: "ops", KeySHA256: hex.EncodeToString(digest[:]), Scopes: []string{ScopeAll}},
+	})
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    string
+		wantErr string
+	}{
+		{"api key header", authRequest("X-API-Key", "plain-secret"), "ci", ""},
+		{"api key bearer", authRequest("Authorization", "Bearer hashed-secret"), "ops", ""},
+		{"unknown key", authRequest("X-API-Key", "guess"), "", "invalid API key"},
+		{"no credentials", authRequest("", ""), "", "missing credentials"},
+		{
+			"token",
+			authRequest("Authorization", "Bearer "+signToken("HS256", testTokenKey, map[string]any{
+				"sub": "agent-7", "exp": exp, "aud": []string{"mcp-stockfish"}, "scope": "uci batch",
+			})),
+			"agent-7",
+			"",
+		},
+		{
+			"expired token",
+			authRequest("Authorization", "Bearer "+signToken("HS256", testTokenKey, map[string]any{
+				"sub": "agent-7", "exp": time.Now().Add(-time.Minute).Unix(), "aud": "mcp-stockfish",
+			})),
+			"",
+			"expired",
+		},
+		{
+			"wrong key",
+			authRequest("Authorization", "Bearer "+signToken("HS256", []byte("another key"), map[string]any{
+				"sub": "agent-7", "exp": exp, "aud": "mcp-stockfish",
+			})),
+			"",
+			"bad signature",
+		},
+		{
+			"wrong audience",
+			authRequest("Authorization", "Bearer "+signToken("HS256", testTokenKey, map[string]any{
+				"sub": "agent-7", "exp": exp, "aud": "elsewhere",
+			})),
+			"",
+			"wrong audience",
+		},
+		{
+			"unsigned token",
+			authRequest("Authorization", "Bearer "+signToken("none", testTokenKey, map[string]any{
+				"sub": "agent-7", "exp": exp, "aud": "mcp-stockfish",
+			})),
+			"",
+			"unsupported algorithm",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := auth.Authenticate(tt.request)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || p.Name != tt.want {
+				t.Fatalf("principal = %+v, %v", p, err)
+			}
+		})
+	}
+
+	p, _ := auth.Authenticate(authRequest("X-API-Key", "plain-secret"))
+	if p.MaxSessions != 2 || !p.HasScope(ScopeUCI) || p.HasScope(ScopeBatch) {
+		t.Errorf("ci key = %+v", p)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	auth := newTestAuthenticator(t, []APIKey{{Name: "ci", Key: "plain-secret"}})
+	handler := newHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, principalFromContext(r.Context()).Name)
+	}), auth, nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, authRequest("", ""))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("without credentials: %d %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, authRequest("X-API-Key", "plain-secret"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ci" {
+		t.Errorf("with key: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestToolScopes(t *testing.T) {
+	auth := newTestAuthenticator(t, nil)
+	called := false
+	handle := auth.toolMiddleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	tests := []struct {
+		tool    string
+		command string
+		scopes  []string
+		allowed bool
+	}{
+		{"chess_engine", "go depth 10", []string{ScopeUCI}, true},
+		{"chess_engine", "setoption name Hash value 64", []string{ScopeUCI}, false},
+		{"chess_engine", "setoption name Hash value 64", []string{ScopeUCI, ScopeOptions}, true},
+		{"chess_engine", "go depth 10", nil, false},
+		{"run_match", "", []string{ScopeUCI}, false},
+		{"analyze_batch", "", []string{ScopeBatch}, true},
+		{"render_board", "", nil, true},
+		{"run_test_suite", "", []string{ScopeAll}, true},
+	}
+	for _, tt := range tests {
+		called = false
+		var request mcp.CallToolRequest
+		request.Params.Name = tt.tool
+		request.Params.Arguments = map[string]any{"command": tt.command}
+		ctx := withPrincipal(context.Background(), &Principal{Name: "ci", Scopes: tt.scopes})
+
+		result, _ := handle(ctx, request)
+		if called != tt.allowed || result.IsError == tt.allowed {
+			t.Errorf("%s %q with %v: called %v, result %+v", tt.tool, tt.command, tt.scopes, called, result)
+		}
+	}
+
+	// Without authentication, as over stdio, everything is allowed.
+	var request mcp.CallToolRequest
+	request.Params.Name = "run_match"
+	if handle(context.Background(), request); !called {
+		t.Error("unauthenticated call denied")
+	}
+}
+
+func TestSessionOwnersAndQuotas(t *testing.T) {
+	sm := newTestSessionManager(t, testConfig(fakeEngine(t, ucitest.Script{})))
+	alice := withPrincipal(context.Background(), &Principal{Name: "alice", MaxSessions: 1})
+	bob := withPrincipal(context.Background(), &Principal{Name: "bob"})
+
+	session, _, err := sm.getOrCreateSession(alice, "", testEngine, PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Owner != "alice" || session.State().Owner != "alice" {
+		t.Errorf("owner = %q", session.Owner)
+	}
+	if again, _, err := sm.getOrCreateSession(alice, session.ID, "", PriorityInteractive); err != nil || again != session {
+		t.Errorf("own session: %v", err)
+	}
+	if _, _, err := sm.getOrCreateSession(alice, "", testEngine, PriorityInteractive); err == nil ||
+		!strings.Contains(err.Error(), "quota") {
+		t.Errorf("second session of alice: %v", err)
+	}
+	if _, _, err := sm.getOrCreateSession(bob, session.ID, "", PriorityInteractive); err == nil ||
+		!strings.Contains(err.Error(), "not found") {
+		t.Errorf("bob used alice's session: %v", err)
+	}
+	if _, _, err := sm.getOrCreateSession(bob, "", testEngine, PriorityInteractive); err != nil {
+		t.Errorf("bob: %v", err)
+	}
+}
+
+func TestAuthOverHTTP(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	handler := newTestHandler(t, config, true)
+	auth := newTestAuthenticator(t, []APIKey{
+		{Name: "alice", Key: "alice-secret", Scopes: []string{ScopeUCI}},
+		{Name: "bob", Key: "bob-secret", Scopes: []string{ScopeUCI}},
+		{Name: "eve", Key: "eve-secret"},
+	})
+	s := server.NewMCPServer("test", "test", server.WithToolHandlerMiddleware(auth.toolMiddleware))
+	s.AddTool(newChessEngineTool(handler.engines), handler.handle)
+	url, _ := startHTTPServer(t, s, auth, nil)
+
+	alice := connectMCP(t, url, map[string]string{"X-API-Key": "alice-secret"})
+	text, isErr := callRemoteTool(t, alice, "chess_engine", map[string]any{"command": "uci"})
+	if isErr {
+		t.Fatal(text)
+	}
+	session := decode[CommandResult](t, text).SessionID
+
+	bob := connectMCP(t, url, map[string]string{"X-API-Key": "bob-secret"})
+	text, _ = callRemoteTool(t, bob, "chess_engine", map[string]any{"command": "isready", "session_id": session})
+	if result := decode[CommandResult](t, text); result.Status != "error" || !strings.Contains(result.Error, "not found") {
+		t.Errorf("bob used alice's session: %s", text)
+	}
+
+	eve := connectMCP(t, url, map[string]string{"X-API-Key": "eve-secret"})
+	if text, isErr := callRemoteTool(t, eve, "chess_engine", map[string]any{"command": "uci"}); !isErr ||
+		!strings.Contains(text, "Forbidden") {
+		t.Errorf("eve without scopes: %s", text)
+	}
+
+	token := signToken("HS256", testTokenKey, map[string]any{
+		"sub":   "carol",
+		"aud":   "mcp-stockfish",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": ScopeUCI,
+	})
+	carol := connectMCP(t, url, map[string]string{"Authorization": "Bearer " + token})
+	if text, isErr := callRemoteTool(t, carol, "chess_engine", map[string]any{"command": "uci"}); isErr {
+		t.Errorf("carol with a token: %s", text)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const maxBatchItems = 500
+
+// BatchItem is one position of a batch: a FEN and/or moves, with an
+// optional limit that overrides the batch's.
+type BatchItem struct {
+	ID         string   `json:"id,omitempty"`
+	FEN        string   `json:"fen,omitempty"`
+	Moves      []string `json:"moves,omitempty"`
+	Depth      int      `json:"depth,omitempty"`
+	MoveTimeMS int      `json:"movetime,omitempty"`
+}
+
+// UnmarshalJSON accepts a bare FEN string as well as an object.
+func (b *BatchItem) UnmarshalJSON(data []byte) error {
+	var fen string
+	if err := json.Unmarshal(data, &fen); err == nil {
+		*b = BatchItem{FEN: fen}
+		return nil
+	}
+	type plain BatchItem
+	return json.Unmarshal(data, (*plain)(b))
+}
+
+type BatchItemResult struct {
+	Index     int       `json:"index"`
+	ID        string    `json:"id,omitempty"`
+	FEN       string    `json:"fen,omitempty"`
+	Depth     int       `json:"depth,omitempty"`
+	Best      *MoveEval `json:"best,omitempty"`
+	Outlook   string    `json:"outlook,omitempty"`
+	ElapsedMS int64     `json:"elapsed_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type BatchResult struct {
+	Status    string            `json:"status"`
+	Engine    string            `json:"engine"`
+	Workers   int               `json:"workers"`
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	ElapsedMS int64             `json:"elapsed_ms"`
+	Results   []BatchItemResult `json:"results"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type BatchConfig struct {
+	Engine     string
+	Depth      int
+	MoveTimeMS int
+	Workers    int
+}
+
+// AnalyzeBatch evaluates the items on a pool of engines, one process per
+// worker. Workers take free slots of the global engine limit, so the batch
+// runs on fewer engines while sessions and other batches use them; it waits
+// for at least one. Failures, including timeouts, are reported per item;
+// items not started when ctx is done are marked as such and the result is
+// partial.
+func (a *Analyzer) AnalyzeBatch(
+	ctx context.Context,
+	items []BatchItem,
+	cfg BatchConfig,
+) (*BatchResult, error) {
+	engine, err := a.engines.Get(cfg.Engine)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := min(a.maxSessions, engine.MaxSessions, len(items))
+	if cfg.Workers > 0 {
+		workers = min(workers, cfg.Workers)
+	}
+	workers = max(workers, 1)
+
+	workers, release, err := a.engines.reserveUpTo(ctx, workers)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result := &BatchResult{
+		Status:  "success",
+		Engine:  engine.Name,
+		Workers: workers,
+		Total:   len(items),
+		Results: make([]BatchItemResult, len(items)),
+	}
+
+	a.logger.Info().
+		Str("engine", engine.Name).
+		Int("items", len(items)).
+		Int("workers", workers).
+		Msg("Batch analysis started")
+
+	started := time.Now()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			player := &enginePlayer{config: engine, logger: a.logger}
+			defer player.close()
+			for i := range jobs {
+				result.Results[i] = a.analyzeItem(ctx, player, i, items[i], cfg)
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(items); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	result.ElapsedMS = time.Since(started).Milliseconds()
+
+	for i := next; i < len(items); i++ {
+		result.Results[i] = BatchItemResult{
+			Index: i + 1,
+			ID:    items[i].ID,
+			Error: "not run: " + ctx.Err().Error(),
+		}
+	}
+
+	for _, r := range result.Results {
+		if r.Error != "" {
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+	}
+	switch {
+	case next < len(items):
+		result.Status = "partial"
+		result.Error = ctx.Err().Error()
+	case result.Failed > 0:
+		result.Status = "partial"
+	}
+
+	a.logger.Info().
+		Int("succeeded", result.Succeeded).
+		Int("failed", result.Failed).
+		Int64("elapsed_ms", result.ElapsedMS).
+		Msg("Batch analysis finished")
+
+	return result, nil
+}
+
+func (a *Analyzer) analyzeItem(
+	ctx context.Context,
+	player *enginePlayer,
+	index int,
+	item BatchItem,
+	cfg BatchConfig,
+) BatchItemResult {
+	started := time.Now()
+	result := BatchItemResult{Index: index + 1, ID: item.ID}
+	fail := func(err error) BatchItemResult {
+		result.Error = err.Error()
+		result.ElapsedMS = time.Since(started).Milliseconds()
+		return result
+	}
+
+	fen := item.FEN
+	if fen == "" {
+		fen = startFEN
+	}
+	game, err := newGameFromMoves(fen, item.Moves)
+	if err != nil {
+		return fail(fmt.Errorf("invalid position: %w", err))
+	}
+	pos := game.Position()
+	result.FEN = pos.FEN()
+	if outcome, reason := game.Outcome(); outcome != ResultOngoing {
+		return fail(fmt.Errorf("game is over: %s (%s)", outcome, reason))
+	}
+
+	depth, moveTimeMS := cfg.Depth, cfg.MoveTimeMS
+	if item.Depth > 0 || item.MoveTimeMS > 0 {
+		depth, moveTimeMS = item.Depth, item.MoveTimeMS
+	}
+
+	search, err := player.search(
+		ctx,
+		game.PositionCommand(),
+		analysisLimits(depth, moveTimeMS),
+		a.timeout(moveTimeMS),
+	)
+	if err != nil {
+		return fail(err)
+	}
+	search.fillWDL(pos)
+
+	m, err := pos.ParseUCI(search.BestMove)
+	if err != nil {
+		return fail(fmt.Errorf("engine returned an illegal move: %s", search.BestMove))
+	}
+	line := search.Best()
+	if line == nil {
+		return fail(fmt.Errorf("engine reported no evaluation"))
+	}
+
+	best := moveEval(pos, m, line)
+	result.Best = &best
+	result.Depth = line.Depth
+	if line.WDL != nil {
+		result.Outlook = line.WDL.outlook(pos.Turn())
+	}
+	result.ElapsedMS = time.Since(started).Milliseconds()
+	return result
+}
+
+func (a *Analyzer) handleAnalyzeBatch(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	raw, ok := request.GetArguments()["items"]
+	if !ok {
+		return mcp.NewToolResultError("Missing 'items' parameter"), nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid items: %s", err.Error())), nil
+	}
+	var items []BatchItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid items: %s", err.Error())), nil
+	}
+	if len(items) == 0 {
+		return mcp.NewToolResultError("No items to analyze"), nil
+	}
+	if len(items) > maxBatchItems {
+		return mcp.NewToolResultError(
+			fmt.Sprintf("Too many items: %d (maximum %d)", len(items), maxBatchItems),
+		), nil
+	}
+
+	cfg := BatchConfig{
+		Engine:     request.GetString("engine", ""),
+		Depth:      request.GetInt("depth", 0),
+		MoveTimeMS: request.GetInt("movetime", 0),
+		Workers:    request.GetInt("workers", 0),
+	}
+
+	a.logger.Info().
+		Int("items", len(items)).
+		Str("engine", cfg.Engine).
+		Msg("Received batch analysis request")
+
+	result, err := a.AnalyzeBatch(ctx, items, cfg)
+	if err != nil {
+		a.logger.Warn().Err(err).Msg("Batch analysis failed")
+		return mcp.NewToolResultError(fmt.Sprintf("Batch analysis failed: %s", err.Error())), nil
+	}
+	return jsonToolResult(result, a.logger)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestBatchWorkersShareEngineSlots(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{SearchTime: 20 * time.Millisecond})
+	config := testConfig(engine)
+	config.MaxSessions = 3
+	config.QueueMaxWait = 0
+	sm := newTestSessionManager(t, config)
+	analyzer := newAnalyzer(sm.engines, config.MaxSessions, config.CommandTimeout, zerolog.Nop())
+
+	items := make([]BatchItem, 6)
+	for i := range items {
+		items[i] = BatchItem{FEN: startFEN}
+	}
+
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	result, err := analyzer.AnalyzeBatch(context.Background(), items, BatchConfig{Depth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Workers != 2 || result.Succeeded != len(items) {
+		t.Errorf("batch beside a session: %d workers, %d succeeded", result.Workers, result.Succeeded)
+	}
+
+	// A concurrent batch holding engines leaves this one fewer, and none
+	// once they are all taken.
+	other, err := sm.engines.reserve(context.Background(), 1, PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other()
+	result, err = analyzer.AnalyzeBatch(context.Background(), items, BatchConfig{Depth: 1})
+	if err != nil || result.Workers != 1 {
+		t.Errorf("batch beside a session and a batch: %+v, %v", result, err)
+	}
+	last, err := sm.engines.reserve(context.Background(), 1, PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer last()
+	_, err = analyzer.AnalyzeBatch(context.Background(), items, BatchConfig{Depth: 1})
+	if err == nil || !strings.Contains(err.Error(), "maximum number of engines (3) running") {
+		t.Errorf("batch without a free engine: %v", err)
+	}
+}
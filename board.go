@@ -0,0 +1,883 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file holds a small mailbox board model: enough to validate moves,
+// detect game ends and convert between UCI and SAN without an engine.
+
+type Color uint8
+
+const (
+	White Color = iota
+	Black
+)
+
+func (c Color) Other() Color {
+	return c ^ 1
+}
+
+func (c Color) String() string {
+	if c == White {
+		return "white"
+	}
+	return "black"
+}
+
+type PieceType uint8
+
+const (
+	NoPieceType PieceType = iota
+	Pawn
+	Knight
+	Bishop
+	Rook
+	Queen
+	King
+)
+
+// Piece packs a color and a piece type; the zero value is an empty square.
+type Piece uint8
+
+const NoPiece Piece = 0
+
+func makePiece(c Color, t PieceType) Piece {
+	return Piece(c)<<3 | Piece(t)
+}
+
+func (p Piece) Type() PieceType {
+	return PieceType(p & 7)
+}
+
+func (p Piece) Color() Color {
+	return Color(p >> 3)
+}
+
+const pieceLetters = " pnbrqk"
+
+func (p Piece) FENChar() byte {
+	c := pieceLetters[p.Type()]
+	if p.Color() == White {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+func pieceFromFENChar(c byte) (Piece, bool) {
+	color := White
+	if c >= 'a' && c <= 'z' {
+		color = Black
+		c = c - 'a' + 'A'
+	}
+	idx := strings.IndexByte(strings.ToUpper(pieceLetters), c)
+	if idx <= 0 {
+		return NoPiece, false
+	}
+	return makePiece(color, PieceType(idx)), true
+}
+
+// Square indexes the board from a1 (0) to h8 (63).
+type Square int8
+
+const NoSquare Square = -1
+
+func newSquare(file, rank int) Square {
+	if file < 0 || file > 7 || rank < 0 || rank > 7 {
+		return NoSquare
+	}
+	return Square(rank*8 + file)
+}
+
+func (s Square) File() int {
+	return int(s) & 7
+}
+
+func (s Square) Rank() int {
+	return int(s) >> 3
+}
+
+func (s Square) String() string {
+	if s == NoSquare {
+		return "-"
+	}
+	return string([]byte{byte('a' + s.File()), byte('1' + s.Rank())})
+}
+
+func parseSquare(s string) (Square, error) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return NoSquare, fmt.Errorf("invalid square %q", s)
+	}
+	return newSquare(int(s[0]-'a'), int(s[1]-'1')), nil
+}
+
+// Move is a move in board coordinates. Castling is encoded internally as
+// the king capturing its own rook, which covers standard chess and Chess960.
+type Move struct {
+	From      Square
+	To        Square
+	Promotion PieceType
+}
+
+const (
+	castleKingSide  = 0
+	castleQueenSide = 1
+)
+
+// Position is a full chess position as described by a FEN.
+type Position struct {
+	board    [64]Piece
+	turn     Color
+	castling [2][2]Square // [color][castleKingSide|castleQueenSide] rook origin
+	epSquare Square
+	halfmove int
+	fullmove int
+	chess960 bool
+}
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func startPosition() *Position {
+	pos, _ := parseFEN(startFEN)
+	return pos
+}
+
+func (p *Position) Turn() Color {
+	return p.turn
+}
+
+func (p *Position) PieceAt(sq Square) Piece {
+	return p.board[sq]
+}
+
+// parseFEN parses a FEN string. The halfmove and fullmove counters are
+// optional. Castling rights may be given as KQkq, X-FEN or Shredder-FEN;
+// rights that need Chess960 rules mark the position as Chess960.
+func parseFEN(fen string) (*Position, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid FEN %q: expected at least 4 fields", fen)
+	}
+
+	p := &Position{epSquare: NoSquare, fullmove: 1}
+	for c := range p.castling {
+		p.castling[c] = [2]Square{NoSquare, NoSquare}
+	}
+
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("invalid FEN %q: expected 8 ranks", fen)
+	}
+	for i, row := range ranks {
+		rank := 7 - i
+		file := 0
+		for j := 0; j < len(row); j++ {
+			c := row[j]
+			if c >= '1' && c <= '8' {
+				file += int(c - '0')
+				continue
+			}
+			piece, ok := pieceFromFENChar(c)
+			if !ok || file > 7 {
+				return nil, fmt.Errorf("invalid FEN %q: bad rank %q", fen, row)
+			}
+			p.board[newSquare(file, rank)] = piece
+			file++
+		}
+		if file != 8 {
+			return nil, fmt.Errorf("invalid FEN %q: rank %q does not have 8 files", fen, row)
+		}
+	}
+
+	switch fields[1] {
+	case "w":
+		p.turn = White
+	case "b":
+		p.turn = Black
+	default:
+		return nil, fmt.Errorf("invalid FEN %q: bad side to move %q", fen, fields[1])
+	}
+
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			if err := p.addCastlingRight(fields[2][i]); err != nil {
+				return nil, fmt.Errorf("invalid FEN %q: %w", fen, err)
+			}
+		}
+		p.chess960 = p.chess960 || p.castlingNeeds960()
+	}
+
+	if fields[3] != "-" {
+		sq, err := parseSquare(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid FEN %q: %w", fen, err)
+		}
+		if (p.turn == White && sq.Rank() != 5) || (p.turn == Black && sq.Rank() != 2) {
+			return nil, fmt.Errorf("invalid FEN %q: bad en-passant square %s", fen, sq)
+		}
+		if p.enPassantPlausible(sq) {
+			p.epSquare = sq
+		}
+	}
+
+	if len(fields) > 4 {
+		n, err := strconv.Atoi(fields[4])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid FEN %q: bad halfmove clock", fen)
+		}
+		p.halfmove = n
+	}
+	if len(fields) > 5 {
+		n, err := strconv.Atoi(fields[5])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid FEN %q: bad fullmove number", fen)
+		}
+		p.fullmove = n
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid FEN %q: %w", fen, err)
+	}
+	return p, nil
+}
+
+// enPassantPlausible reports whether a pawn of the side not to move could
+// just have pushed two squares past sq. An en-passant square that fails this
+// is dropped, as it would let a pawn capture a piece that never moved there.
+func (p *Position) enPassantPlausible(sq Square) bool {
+	dir := -1
+	if p.turn == Black {
+		dir = 1
+	}
+	pawn := newSquare(sq.File(), sq.Rank()+dir)
+	start := newSquare(sq.File(), sq.Rank()-dir)
+	return p.board[sq] == NoPiece &&
+		p.board[start] == NoPiece &&
+		p.board[pawn] == makePiece(p.turn.Other(), Pawn)
+}
+
+// addCastlingRight adds one castling letter. KQkq select the outermost rook
+// on each side of the king (X-FEN); file letters A-H/a-h name the rook
+// directly (Shredder-FEN), as needed when a Chess960 side has two rooks on
+// one wing.
+func (p *Position) addCastlingRight(c byte) error {
+	color := White
+	if c >= 'a' && c <= 'z' {
+		color = Black
+		c = c - 'a' + 'A'
+	}
+	backRank := 0
+	if color == Black {
+		backRank = 7
+	}
+	king := p.kingSquare(color)
+	if king == NoSquare || king.Rank() != backRank {
+		return fmt.Errorf("castling right %q without a king on the back rank", c)
+	}
+	rook := makePiece(color, Rook)
+
+	if c >= 'A' && c <= 'H' {
+		sq := newSquare(int(c-'A'), backRank)
+		if p.board[sq] != rook || sq.File() == king.File() {
+			return fmt.Errorf("castling right %q without a rook", c)
+		}
+		side := castleQueenSide
+		if sq.File() > king.File() {
+			side = castleKingSide
+		}
+		p.castling[color][side] = sq
+		p.chess960 = true
+		return nil
+	}
+
+	var side int
+	var from, to, step int
+	switch c {
+	case 'K':
+		side, from, to, step = castleKingSide, 7, king.File(), -1
+	case 'Q':
+		side, from, to, step = castleQueenSide, 0, king.File(), 1
+	default:
+		return fmt.Errorf("invalid castling right %q", c)
+	}
+	for f := from; f != to; f += step {
+		if sq := newSquare(f, backRank); p.board[sq] == rook {
+			p.castling[color][side] = sq
+			return nil
+		}
+	}
+	return fmt.Errorf("castling right %q without a rook", c)
+}
+
+// castlingNeeds960 reports castling rights that only make sense in
+// Chess960: a king off the e-file or a castling rook off the corner.
+func (p *Position) castlingNeeds960() bool {
+	for c, rooks := range p.castling {
+		for side, rook := range rooks {
+			if rook == NoSquare {
+				continue
+			}
+			corner := 7
+			if side == castleQueenSide {
+				corner = 0
+			}
+			if rook.File() != corner || p.kingSquare(Color(c)).File() != 4 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Chess960 reports whether the position is played under Chess960 castling
+// rules, which also switches UCI castling to king-takes-rook.
+func (p *Position) Chess960() bool {
+	return p.chess960
+}
+
+func (p *Position) validate() error {
+	for _, c := range []Color{White, Black} {
+		kings := 0
+		for _, piece := range p.board {
+			if piece == makePiece(c, King) {
+				kings++
+			}
+		}
+		if kings != 1 {
+			return fmt.Errorf("%s must have exactly one king", c)
+		}
+	}
+	for sq, piece := range p.board {
+		if piece.Type() == Pawn && (Square(sq).Rank() == 0 || Square(sq).Rank() == 7) {
+			return fmt.Errorf("pawn on back rank %s", Square(sq))
+		}
+	}
+	if p.isAttacked(p.kingSquare(p.turn.Other()), p.turn) {
+		return fmt.Errorf("side not to move is in check")
+	}
+	return nil
+}
+
+// FEN formats the position as a FEN string.
+func (p *Position) FEN() string {
+	var sb strings.Builder
+	sb.WriteString(p.boardFEN())
+	if p.turn == White {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+	sb.WriteString(p.castlingFEN())
+	sb.WriteByte(' ')
+	sb.WriteString(p.epSquare.String())
+	fmt.Fprintf(&sb, " %d %d", p.halfmove, p.fullmove)
+	return sb.String()
+}
+
+func (p *Position) boardFEN() string {
+	var sb strings.Builder
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			piece := p.board[newSquare(file, rank)]
+			if piece == NoPiece {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(piece.FENChar())
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+	return sb.String()
+}
+
+// castlingFEN writes the castling rights as X-FEN: KQkq for the outermost
+// rook on each side, the rook's file letter for an inner one. Chess960
+// positions whose rights look standard use Shredder-FEN, so that they keep
+// Chess960 rules when parsed back.
+func (p *Position) castlingFEN() string {
+	return p.formatCastling(p.chess960 && !p.castlingNeeds960())
+}
+
+// formatCastling writes the rights as X-FEN, or with shredder as
+// Shredder-FEN, which always uses file letters.
+func (p *Position) formatCastling(shredder bool) string {
+	var sb strings.Builder
+	for _, c := range []Color{White, Black} {
+		for side, letter := range []byte{'K', 'Q'} {
+			rook := p.castling[c][side]
+			if rook == NoSquare {
+				continue
+			}
+			if shredder || !p.outermostRook(c, side, rook) {
+				letter = byte('A' + rook.File())
+			}
+			if c == Black {
+				letter = letter - 'A' + 'a'
+			}
+			sb.WriteByte(letter)
+		}
+	}
+	if sb.Len() == 0 {
+		return "-"
+	}
+	return sb.String()
+}
+
+// outermostRook reports whether no other rook of color c stands between
+// rook and the corner on its side of the board.
+func (p *Position) outermostRook(c Color, side int, rook Square) bool {
+	corner, step := 7, 1
+	if side == castleQueenSide {
+		corner, step = 0, -1
+	}
+	for f := rook.File() + step; f*step <= corner*step; f += step {
+		if p.board[newSquare(f, rook.Rank())] == makePiece(c, Rook) {
+			return false
+		}
+	}
+	return true
+}
+
+// ShredderFEN formats the position with Shredder-FEN castling rights.
+func (p *Position) ShredderFEN() string {
+	fields := strings.Fields(p.FEN())
+	fields[2] = p.formatCastling(true)
+	return strings.Join(fields, " ")
+}
+
+// repetitionKey identifies a position for threefold repetition: placement,
+// side to move, castling rights and a capturable en-passant square.
+func (p *Position) repetitionKey() string {
+	ep := NoSquare
+	if p.epSquare != NoSquare && p.hasEnPassantCapture() {
+		ep = p.epSquare
+	}
+	return fmt.Sprintf("%s %d %s %s", p.boardFEN(), p.turn, p.castlingFEN(), ep)
+}
+
+func (p *Position) hasEnPassantCapture() bool {
+	for _, m := range p.LegalMoves() {
+		if m.To == p.epSquare && p.board[m.From].Type() == Pawn {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Position) kingSquare(c Color) Square {
+	king := makePiece(c, King)
+	for sq, piece := range p.board {
+		if piece == king {
+			return Square(sq)
+		}
+	}
+	return NoSquare
+}
+
+func (p *Position) isCastle(m Move) bool {
+	piece, target := p.board[m.From], p.board[m.To]
+	return piece.Type() == King && target.Type() == Rook && target.Color() == piece.Color()
+}
+
+func (p *Position) isCapture(m Move) bool {
+	if p.isCastle(m) {
+		return false
+	}
+	if p.board[m.To] != NoPiece {
+		return true
+	}
+	return p.board[m.From].Type() == Pawn && m.To == p.epSquare
+}
+
+// castleTargets returns the king and rook destination squares of a castle.
+func castleTargets(king, rook Square) (Square, Square) {
+	rank := king.Rank()
+	if rook.File() > king.File() {
+		return newSquare(6, rank), newSquare(5, rank)
+	}
+	return newSquare(2, rank), newSquare(3, rank)
+}
+
+// Play returns the position after m. The move is assumed to be legal.
+func (p *Position) Play(m Move) *Position {
+	next := *p
+	piece := p.board[m.From]
+	color := piece.Color()
+	next.epSquare = NoSquare
+	next.halfmove++
+
+	switch {
+	case p.isCastle(m):
+		kingTo, rookTo := castleTargets(m.From, m.To)
+		next.board[m.From] = NoPiece
+		next.board[m.To] = NoPiece
+		next.board[kingTo] = piece
+		next.board[rookTo] = makePiece(color, Rook)
+	case piece.Type() == Pawn:
+		next.halfmove = 0
+		if m.To == p.epSquare {
+			next.board[newSquare(m.To.File(), m.From.Rank())] = NoPiece
+		}
+		next.board[m.From] = NoPiece
+		next.board[m.To] = piece
+		if m.Promotion != NoPieceType {
+			next.board[m.To] = makePiece(color, m.Promotion)
+		}
+		if diff := int(m.To) - int(m.From); diff == 16 || diff == -16 {
+			next.epSquare = Square((int(m.To) + int(m.From)) / 2)
+		}
+	default:
+		if p.board[m.To] != NoPiece {
+			next.halfmove = 0
+		}
+		next.board[m.From] = NoPiece
+		next.board[m.To] = piece
+	}
+
+	if piece.Type() == King {
+		next.castling[color] = [2]Square{NoSquare, NoSquare}
+	}
+	for c := range next.castling {
+		for side, rook := range next.castling[c] {
+			if rook == m.From || rook == m.To {
+				next.castling[c][side] = NoSquare
+			}
+		}
+	}
+
+	if color == Black {
+		next.fullmove++
+	}
+	next.turn = color.Other()
+	return &next
+}
+
+var (
+	knightOffsets = [8][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingOffsets   = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	bishopDirs    = [4][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+	rookDirs      = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+)
+
+// isAttacked reports whether sq is attacked by any piece of color by.
+func (p *Position) isAttacked(sq Square, by Color) bool {
+	return len(p.attackers(sq, by)) > 0
+}
+
+// attackers lists the squares of pieces of color by that attack sq.
+func (p *Position) attackers(sq Square, by Color) []Square {
+	var result []Square
+	file, rank := sq.File(), sq.Rank()
+
+	pawnRank := rank - 1
+	if by == Black {
+		pawnRank = rank + 1
+	}
+	for _, df := range []int{-1, 1} {
+		if from := newSquare(file+df, pawnRank); from != NoSquare &&
+			p.board[from] == makePiece(by, Pawn) {
+			result = append(result, from)
+		}
+	}
+
+	for _, off := range knightOffsets {
+		if from := newSquare(file+off[0], rank+off[1]); from != NoSquare &&
+			p.board[from] == makePiece(by, Knight) {
+			result = append(result, from)
+		}
+	}
+
+	for _, off := range kingOffsets {
+		if from := newSquare(file+off[0], rank+off[1]); from != NoSquare &&
+			p.board[from] == makePiece(by, King) {
+			result = append(result, from)
+		}
+	}
+
+	slide := func(dirs [4][2]int, kind PieceType) {
+		for _, d := range dirs {
+			for f, r := file+d[0], rank+d[1]; ; f, r = f+d[0], r+d[1] {
+				from := newSquare(f, r)
+				if from == NoSquare {
+					break
+				}
+				piece := p.board[from]
+				if piece == NoPiece {
+					continue
+				}
+				if piece.Color() == by && (piece.Type() == kind || piece.Type() == Queen) {
+					result = append(result, from)
+				}
+				break
+			}
+		}
+	}
+	slide(bishopDirs, Bishop)
+	slide(rookDirs, Rook)
+
+	return result
+}
+
+func (p *Position) InCheck() bool {
+	return p.isAttacked(p.kingSquare(p.turn), p.turn.Other())
+}
+
+// LegalMoves generates all legal moves for the side to move.
+func (p *Position) LegalMoves() []Move {
+	var legal []Move
+	for _, m := range p.pseudoLegalMoves() {
+		next := p.Play(m)
+		if !next.isAttacked(next.kingSquare(p.turn), p.turn.Other()) {
+			legal = append(legal, m)
+		}
+	}
+	return legal
+}
+
+func (p *Position) IsLegal(m Move) bool {
+	for _, legal := range p.LegalMoves() {
+		if legal == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Position) pseudoLegalMoves() []Move {
+	var moves []Move
+	us := p.turn
+
+	add := func(from, to Square) {
+		target := p.board[to]
+		if target != NoPiece && target.Color() == us {
+			return
+		}
+		moves = append(moves, Move{From: from, To: to})
+	}
+
+	for i, piece := range p.board {
+		if piece == NoPiece || piece.Color() != us {
+			continue
+		}
+		from := Square(i)
+		file, rank := from.File(), from.Rank()
+
+		switch piece.Type() {
+		case Pawn:
+			moves = append(moves, p.pawnMoves(from)...)
+		case Knight:
+			for _, off := range knightOffsets {
+				if to := newSquare(file+off[0], rank+off[1]); to != NoSquare {
+					add(from, to)
+				}
+			}
+		case King:
+			for _, off := range kingOffsets {
+				if to := newSquare(file+off[0], rank+off[1]); to != NoSquare {
+					add(from, to)
+				}
+			}
+			moves = append(moves, p.castlingMoves(from)...)
+		default:
+			var dirs [][2]int
+			if piece.Type() != Rook {
+				dirs = append(dirs, bishopDirs[:]...)
+			}
+			if piece.Type() != Bishop {
+				dirs = append(dirs, rookDirs[:]...)
+			}
+			for _, d := range dirs {
+				for f, r := file+d[0], rank+d[1]; ; f, r = f+d[0], r+d[1] {
+					to := newSquare(f, r)
+					if to == NoSquare {
+						break
+					}
+					add(from, to)
+					if p.board[to] != NoPiece {
+						break
+					}
+				}
+			}
+		}
+	}
+	return moves
+}
+
+func (p *Position) pawnMoves(from Square) []Move {
+	var moves []Move
+	us := p.turn
+	dir, startRank, lastRank := 1, 1, 7
+	if us == Black {
+		dir, startRank, lastRank = -1, 6, 0
+	}
+	file, rank := from.File(), from.Rank()
+
+	push := func(to Square) {
+		if to.Rank() == lastRank {
+			for _, promo := range []PieceType{Queen, Rook, Bishop, Knight} {
+				moves = append(moves, Move{From: from, To: to, Promotion: promo})
+			}
+			return
+		}
+		moves = append(moves, Move{From: from, To: to})
+	}
+
+	if one := newSquare(file, rank+dir); one != NoSquare && p.board[one] == NoPiece {
+		push(one)
+		if two := newSquare(file, rank+2*dir); rank == startRank && p.board[two] == NoPiece {
+			push(two)
+		}
+	}
+	for _, df := range []int{-1, 1} {
+		to := newSquare(file+df, rank+dir)
+		if to == NoSquare {
+			continue
+		}
+		target := p.board[to]
+		if (target != NoPiece && target.Color() != us) || to == p.epSquare {
+			push(to)
+		}
+	}
+	return moves
+}
+
+// castlingMoves generates castles for the king on from. Squares between the
+// king, the rook and their destinations must be empty, and the king may not
+// be in check or pass through an attacked square; the destination square
+// itself is checked by the legality filter.
+func (p *Position) castlingMoves(from Square) []Move {
+	var moves []Move
+	us := p.turn
+	if p.InCheck() {
+		return nil
+	}
+
+	for _, rook := range p.castling[us] {
+		if rook == NoSquare || p.board[rook] != makePiece(us, Rook) {
+			continue
+		}
+		kingTo, rookTo := castleTargets(from, rook)
+		rank := from.Rank()
+
+		lo := min(from.File(), kingTo.File(), rook.File(), rookTo.File())
+		hi := max(from.File(), kingTo.File(), rook.File(), rookTo.File())
+		clear := true
+		for f := lo; f <= hi && clear; f++ {
+			sq := newSquare(f, rank)
+			if sq != from && sq != rook && p.board[sq] != NoPiece {
+				clear = false
+			}
+		}
+		if !clear {
+			continue
+		}
+
+		step := 1
+		if kingTo.File() < from.File() {
+			step = -1
+		}
+		safe := true
+		for f := from.File(); f != kingTo.File() && safe; f += step {
+			if p.isAttacked(newSquare(f, rank), us.Other()) {
+				safe = false
+			}
+		}
+		if !safe {
+			continue
+		}
+		moves = append(moves, Move{From: from, To: rook})
+	}
+	return moves
+}
+
+// UCI formats m in UCI notation. Castling is written as king-to-destination
+// (e1g1) in standard chess and as king-takes-rook in Chess960.
+func (p *Position) UCI(m Move) string {
+	to := m.To
+	if p.isCastle(m) && !p.chess960 {
+		to, _ = castleTargets(m.From, m.To)
+	}
+	s := m.From.String() + to.String()
+	if m.Promotion != NoPieceType {
+		s += string(pieceLetters[m.Promotion])
+	}
+	return s
+}
+
+// ParseUCI parses a move in UCI notation and checks that it is legal. Both
+// castling encodings are accepted.
+func (p *Position) ParseUCI(s string) (Move, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, m := range p.LegalMoves() {
+		if p.UCI(m) == s {
+			return m, nil
+		}
+		if p.isCastle(m) && m.From.String()+m.To.String() == s {
+			return m, nil
+		}
+	}
+	return Move{}, fmt.Errorf("illegal move %q in position %s", s, p.FEN())
+}
+
+func (p *Position) pieceCount() int {
+	count := 0
+	for _, piece := range p.board {
+		if piece != NoPiece {
+			count++
+		}
+	}
+	return count
+}
+
+// pieceValues are the conventional material values in pawns.
+var pieceValues = [...]int{Pawn: 1, Knight: 3, Bishop: 3, Rook: 5, Queen: 9, King: 0}
+
+// material returns the total material of both sides in pawns, a measure of
+// the game phase (78 at the start).
+func (p *Position) material() int {
+	total := 0
+	for _, piece := range p.board {
+		if piece != NoPiece {
+			total += pieceValues[piece.Type()]
+		}
+	}
+	return total
+}
+
+// insufficientMaterial reports positions where neither side can mate:
+// bare kings, a single minor piece, or bishops all on one square color.
+func (p *Position) insufficientMaterial() bool {
+	minors := 0
+	bishopColors := map[int]bool{}
+	knights := 0
+	for sq, piece := range p.board {
+		switch piece.Type() {
+		case NoPieceType, King:
+		case Bishop:
+			minors++
+			s := Square(sq)
+			bishopColors[(s.File()+s.Rank())%2] = true
+		case Knight:
+			minors++
+			knights++
+		default:
+			return false
+		}
+	}
+	if minors <= 1 {
+		return true
+	}
+	return knights == 0 && len(bishopColors) == 1
+}
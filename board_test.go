@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+)
+
+func perft(p *Position, depth int) int {
+	moves := p.LegalMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+	nodes := 0
+	for _, m := range moves {
+		nodes += perft(p.Play(m), depth-1)
+	}
+	return nodes
+}
+
+// TestPerft counts the leaf nodes of the legal move tree against the
+// published perft results, which exercise castling through attacked
+// squares, en passant discovering check, promotions and Chess960 castling
+// with the king or rook already on its destination.
+func TestPerft(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		nodes []int // by depth, from 1
+	}{
+		{"startpos", startFEN, []int{20, 400, 8902, 197281}},
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []int{48, 2039, 97862}},
+		{"position 3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int{14, 191, 2812, 43238}},
+		{"position 4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int{6, 264, 9467}},
+		{"position 5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int{44, 1486, 62379}},
+		{"position 6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", []int{46, 2079, 89890}},
+		{"chess960 HFhf", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []int{21, 528, 12189}},
+		{"chess960 HEhe", "2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", []int{21, 807, 18002}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := parseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.nodes {
+				if got := perft(pos, i+1); got != want {
+					t.Errorf("depth %d: %d nodes, want %d", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFEN(t *testing.T) {
+	tests := []struct {
+		name     string
+		fen      string
+		want     string // FEN() of the parsed position; empty when parsing fails
+		chess960 bool
+	}{
+		{"startpos", startFEN, startFEN, false},
+		{"counters optional", "8/8/8/8/8/8/8/K6k w - -", "8/8/8/8/8/8/8/K6k w - - 0 1", false},
+		{"en passant after a double push", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", false},
+		{"en passant without a pawn", "4k3/8/8/3Pn3/8/8/8/4K3 w - e6 0 1", "4k3/8/8/3Pn3/8/8/8/4K3 w - - 0 1", false},
+		{"en passant with the start square taken", "4k3/3n4/8/3pP3/8/8/8/4K3 w - d6 0 1", "4k3/3n4/8/3pP3/8/8/8/4K3 w - - 0 1", false},
+		{"en passant on the wrong rank", "4k3/8/8/3pP3/8/8/8/4K3 w - d3 0 1", "", false},
+		{"x-fen", "rn2k1r1/ppp1pp1p/3p2p1/5bn1/P7/2N2B2/1PPPPP2/2BNK1RR w Gkq - 4 11", "rn2k1r1/ppp1pp1p/3p2p1/5bn1/P7/2N2B2/1PPPPP2/2BNK1RR w Gkq - 4 11", true},
+		{"shredder", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9", true},
+		{"two kings", "4k3/8/8/8/8/8/8/3KK3 w - - 0 1", "", false},
+		{"side not to move in check", "4k3/4R3/8/8/8/8/8/4K3 w - - 0 1", "", false},
+		{"pawn on the back rank", "P3k3/8/8/8/8/8/8/4K3 w - - 0 1", "", false},
+		{"castling without a rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := parseFEN(tt.fen)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("parsed as %s", pos.FEN())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pos.FEN(); got != tt.want {
+				t.Errorf("FEN() = %s", got)
+			}
+			if pos.Chess960() != tt.chess960 {
+				t.Errorf("Chess960() = %v", pos.Chess960())
+			}
+		})
+	}
+}
+
+func TestEnPassant(t *testing.T) {
+	pos, err := parseFEN("4k3/8/8/3Pn3/8/8/8/4K3 w - e6 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pos.ParseUCI("d5e6"); err == nil {
+		t.Error("d5e6 captured a knight that never passed e6")
+	}
+
+	pos, err = parseFEN("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := pos.ParseUCI("e5d6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pos.Play(m).FEN(); got != "4k3/8/3P4/8/8/8/8/4K3 b - - 0 1" {
+		t.Errorf("after e5d6: %s", got)
+	}
+}
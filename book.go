@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+)
+
+// Polyglot books are sorted arrays of 16-byte big-endian entries: the
+// position key, the move, its weight and a learn value.
+const polyglotEntrySize = 16
+
+const (
+	polyglotCastleOffset    = 768
+	polyglotEnPassantOffset = 772
+	polyglotTurnOffset      = 780
+)
+
+// polyglotKey computes the Polyglot hash of a position.
+func polyglotKey(p *Position) uint64 {
+	var key uint64
+	for sq, piece := range p.board {
+		if piece == NoPiece {
+			continue
+		}
+		kind := 2 * (int(piece.Type()) - 1)
+		if piece.Color() == White {
+			kind++
+		}
+		key ^= polyglotRandom[64*kind+sq]
+	}
+
+	for c, sides := range p.castling {
+		for side, rook := range sides {
+			if rook != NoSquare {
+				key ^= polyglotRandom[polyglotCastleOffset+2*c+side]
+			}
+		}
+	}
+
+	if p.polyglotEnPassant() {
+		key ^= polyglotRandom[polyglotEnPassantOffset+p.epSquare.File()]
+	}
+
+	if p.turn == White {
+		key ^= polyglotRandom[polyglotTurnOffset]
+	}
+	return key
+}
+
+// polyglotEnPassant reports whether the en passant file is hashed: unlike
+// FEN, Polyglot only counts it when a pawn of the side to move stands next
+// to the pawn that just advanced two squares, legal capture or not.
+func (p *Position) polyglotEnPassant() bool {
+	if p.epSquare == NoSquare {
+		return false
+	}
+	pawn := makePiece(p.turn, Pawn)
+	rank := p.epSquare.Rank() - 1
+	if p.turn == Black {
+		rank = p.epSquare.Rank() + 1
+	}
+	for _, df := range []int{-1, 1} {
+		if sq := newSquare(p.epSquare.File()+df, rank); sq != NoSquare && p.board[sq] == pawn {
+			return true
+		}
+	}
+	return false
+}
+
+// decodePolyglotMove converts a Polyglot move to a legal move. Castling is
+// stored as the king capturing its own rook, like the board does.
+func (p *Position) decodePolyglotMove(raw uint16) (Move, bool) {
+	m := Move{
+		From: newSquare(int(raw>>6)&7, int(raw>>9)&7),
+		To:   newSquare(int(raw)&7, int(raw>>3)&7),
+	}
+	if promo := int(raw>>12) & 7; promo > 0 {
+		m.Promotion = PieceType(int(Pawn) + promo)
+	}
+	for _, legal := range p.LegalMoves() {
+		if legal == m {
+			return m, true
+		}
+	}
+	return Move{}, false
+}
+
+type BookMove struct {
+	UCI         string  `json:"uci"`
+	SAN         string  `json:"san"`
+	Weight      int     `json:"weight"`
+	Probability float64 `json:"probability"`
+	Learn       uint32  `json:"learn,omitempty"`
+
+	move Move
+}
+
+type BookResult struct {
+	Status string     `json:"status"`
+	FEN    string     `json:"fen"`
+	Key    string     `json:"key"`
+	Moves  []BookMove `json:"moves"`
+}
+
+// OpeningBook reads a Polyglot book from disk, binary searching the file
+// for every lookup instead of loading it into memory.
+type OpeningBook struct {
+	file    *os.File
+	entries int64
+	mu      sync.Mutex
+	logger  zerolog.Logger
+}
+
+func openOpeningBook(path string, logger zerolog.Logger) (*OpeningBook, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open opening book: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open opening book: %w", err)
+	}
+	if info.Size()%polyglotEntrySize != 0 {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a Polyglot book: size %d is not a multiple of %d",
+			path, info.Size(), polyglotEntrySize)
+	}
+
+	return &OpeningBook{
+		file:    file,
+		entries: info.Size() / polyglotEntrySize,
+		logger:  logger.With().Str("component", ComponentOpeningBook).Logger(),
+	}, nil
+}
+
+func (b *OpeningBook) Close() error {
+	return b.file.Close()
+}
+
+func (b *OpeningBook) entry(i int64) (key uint64, move uint16, weight uint16, learn uint32, err error) {
+	var buf [polyglotEntrySize]byte
+	if _, err = b.file.ReadAt(buf[:], i*polyglotEntrySize); err != nil && err != io.EOF {
+		return
+	}
+	err = nil
+	key = binary.BigEndian.Uint64(buf[0:8])
+	move = binary.BigEndian.Uint16(buf[8:10])
+	weight = binary.BigEndian.Uint16(buf[10:12])
+	learn = binary.BigEndian.Uint32(buf[12:16])
+	return
+}
+
+// Moves returns the book moves for a position, highest weight first.
+// Entries that do not decode to a legal move are skipped.
+func (b *OpeningBook) Moves(p *Position) ([]BookMove, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := polyglotKey(p)
+
+	var searchErr error
+	first := sort.Search(int(b.entries), func(i int) bool {
+		k, _, _, _, err := b.entry(int64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return k >= key
+	})
+	if searchErr != nil {
+		return nil, fmt.Errorf("failed to read opening book: %w", searchErr)
+	}
+
+	moves := []BookMove{}
+	total := 0
+	for i := int64(first); i < b.entries; i++ {
+		k, raw, weight, learn, err := b.entry(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read opening book: %w", err)
+		}
+		if k != key {
+			break
+		}
+		m, ok := p.decodePolyglotMove(raw)
+		if !ok {
+			b.logger.Debug().Str("fen", p.FEN()).Uint16("move", raw).Msg("Skipping illegal book move")
+			continue
+		}
+		moves = append(moves, BookMove{
+			UCI:    p.UCI(m),
+			SAN:    p.SAN(m),
+			Weight: int(weight),
+			Learn:  learn,
+			move:   m,
+		})
+		total += int(weight)
+	}
+
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].Weight > moves[j].Weight
+	})
+	for i := range moves {
+		if total > 0 {
+			moves[i].Probability = float64(moves[i].Weight) / float64(total)
+		}
+	}
+	return moves, nil
+}
+
+// Pick chooses a book move at random, proportionally to the weights.
+// Zero-weight moves are never picked.
+func (b *OpeningBook) Pick(p *Position) (*BookMove, error) {
+	moves, err := b.Moves(p)
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, m := range moves {
+		total += m.Weight
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	n := rand.Intn(total)
+	for i := range moves {
+		if n < moves[i].Weight {
+			return &moves[i], nil
+		}
+		n -= moves[i].Weight
+	}
+	return nil, nil
+}
+
+func (b *OpeningBook) handle(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	if b == nil {
+		return mcp.NewToolResultError("No opening book configured (set MCP_STOCKFISH_BOOK_PATH)"), nil
+	}
+
+	fen := request.GetString("fen", startFEN)
+	moves := request.GetStringSlice("moves", nil)
+
+	b.logger.Info().Str("fen", fen).Int("moves", len(moves)).Msg("Received book lookup")
+
+	game, err := newGameFromMoves(fen, moves)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid position: %s", err.Error())), nil
+	}
+	pos := game.Position()
+
+	bookMoves, err := b.Moves(pos)
+	if err != nil {
+		b.logger.Error().Err(err).Msg("Book lookup failed")
+		return mcp.NewToolResultError(fmt.Sprintf("Book lookup failed: %s", err.Error())), nil
+	}
+
+	return jsonToolResult(BookResult{
+		Status: "success",
+		FEN:    pos.FEN(),
+		Key:    fmt.Sprintf("%016x", polyglotKey(pos)),
+		Moves:  bookMoves,
+	}, b.logger)
+}
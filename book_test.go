@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// TestPolyglotKey checks the keys against the examples of the Polyglot book
+// format specification.
+func TestPolyglotKey(t *testing.T) {
+	tests := []struct {
+		moves string
+		want  uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+	}
+	for _, tt := range tests {
+		game, err := newGameFromMoves("", strings.Fields(tt.moves))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := polyglotKey(game.Position()); got != tt.want {
+			t.Errorf("key after %q = %#016x, want %#016x", tt.moves, got, tt.want)
+		}
+	}
+}
+
+func polyglotMove(uci string) uint16 {
+	from, _ := parseSquare(uci[0:2])
+	to, _ := parseSquare(uci[2:4])
+	return uint16(to.File() | to.Rank()<<3 | from.File()<<6 | from.Rank()<<9)
+}
+
+func TestOpeningBookMoves(t *testing.T) {
+	castling, err := parseFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		key    uint64
+		move   string
+		weight uint16
+	}
+	entries := []entry{
+		{polyglotKey(startPosition()), "d2d4", 1},
+		{polyglotKey(startPosition()), "e2e4", 3},
+		{polyglotKey(startPosition()), "e2e5", 9}, // illegal, skipped
+		{polyglotKey(castling), "e1h1", 1},
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int { return cmp.Compare(a.key, b.key) })
+	var data []byte
+	for _, e := range entries {
+		data = binary.BigEndian.AppendUint64(data, e.key)
+		data = binary.BigEndian.AppendUint16(data, polyglotMove(e.move))
+		data = binary.BigEndian.AppendUint16(data, e.weight)
+		data = binary.BigEndian.AppendUint32(data, 0)
+	}
+	path := filepath.Join(t.TempDir(), "book.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := openOpeningBook(path, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer book.Close()
+
+	moves, err := book.Moves(startPosition())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 || moves[0].SAN != "e4" || moves[0].Probability != 0.75 || moves[1].UCI != "d2d4" {
+		t.Errorf("start position moves = %+v", moves)
+	}
+
+	// Polyglot stores castling as the king taking its rook.
+	moves, err = book.Moves(castling)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].UCI != "e1g1" || moves[0].SAN != "O-O" {
+		t.Errorf("castling moves = %+v", moves)
+	}
+}
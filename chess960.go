@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	chess960Positions     = 960
+	chess960StandardIndex = 518
+)
+
+// chess960Knights lists the knight placements on the five squares left
+// after the bishops and the queen, in Scharnagl's order.
+var chess960Knights = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2},
+	{1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+// chess960BackRank returns the white back rank of Chess960 start position
+// index (0-959) in Scharnagl's numbering, e.g. "RNBQKBNR" for 518.
+func chess960BackRank(index int) (string, error) {
+	if index < 0 || index >= chess960Positions {
+		return "", fmt.Errorf("chess960 index %d out of range 0-%d", index, chess960Positions-1)
+	}
+
+	var rank [8]byte
+	n := index
+	rank[2*(n%4)+1] = 'B' // light-squared bishop on b, d, f or h
+	n /= 4
+	rank[2*(n%4)] = 'B' // dark-squared bishop on a, c, e or g
+	n /= 4
+
+	empty := func() []int {
+		var files []int
+		for f, piece := range rank {
+			if piece == 0 {
+				files = append(files, f)
+			}
+		}
+		return files
+	}
+
+	rank[empty()[n%6]] = 'Q'
+	n /= 6
+
+	free := empty()
+	for _, i := range chess960Knights[n] {
+		rank[free[i]] = 'N'
+	}
+
+	// The three remaining squares take rook, king, rook from left to right.
+	for i, f := range empty() {
+		rank[f] = "RKR"[i]
+	}
+	return string(rank[:]), nil
+}
+
+// chess960Position builds the start position of a Chess960 index.
+func chess960Position(index int) (*Position, error) {
+	back, err := chess960BackRank(index)
+	if err != nil {
+		return nil, err
+	}
+	fen := fmt.Sprintf("%s/pppppppp/8/8/8/8/PPPPPPPP/%s w KQkq - 0 1", strings.ToLower(back), back)
+	pos, err := parseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	// Index 518 is the standard position, which still castles with
+	// Chess960 rules when played as such.
+	pos.chess960 = true
+	return pos, nil
+}
+
+type Chess960Result struct {
+	Status      string `json:"status"`
+	Index       int    `json:"index"`
+	BackRank    string `json:"back_rank"`
+	FEN         string `json:"fen"`
+	ShredderFEN string `json:"shredder_fen"`
+}
+
+func (gm *GameManager) handleChess960Position(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	index := rand.Intn(chess960Positions)
+	if _, ok := request.GetArguments()["index"]; ok {
+		index = request.GetInt("index", chess960StandardIndex)
+	}
+
+	pos, err := chess960Position(index)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	back, _ := chess960BackRank(index)
+
+	gm.logger.Info().Int("index", index).Str("back_rank", back).Msg("Generated Chess960 position")
+
+	return jsonToolResult(Chess960Result{
+		Status:      "success",
+		Index:       index,
+		BackRank:    back,
+		FEN:         pos.FEN(),
+		ShredderFEN: pos.ShredderFEN(),
+	}, gm.logger)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// runMatchCommand implements "mcp-stockfish match", which plays a match
+// from the command line and prints the result as JSON.
+func runMatchCommand(args []string) error {
+	fs := flag.NewFlagSet("match", flag.ContinueOnError)
+	engineA := fs.String("engine-a", "", "engine for player A (default: the default engine)")
+	engineB := fs.String("engine-b", "", "engine for player B (default: the default engine)")
+	optionsA := fs.String("options-a", "", `options for player A, e.g. "Skill Level=5;Hash=64"`)
+	optionsB := fs.String("options-b", "", `options for player B, e.g. "Skill Level=10"`)
+	games := fs.Int("games", defaultMatchGames, "number of games")
+	openingsFile := fs.String("openings", "", "PGN file, or file with one FEN per line")
+	moveTime := fs.Duration("movetime", defaultMatchMoveTime, "time per move")
+	depth := fs.Int("depth", 0, "fixed depth per move")
+	nodes := fs.Int("nodes", 0, "fixed nodes per move")
+	maxPlies := fs.Int("max-plies", defaultMatchMaxPlies, "adjudicate a draw after this many plies")
+	resignScore := fs.Int("resign-score", 0, "resign at or below -N centipawns")
+	resignMoves := fs.Int("resign-moves", 0, "consecutive moves for resign adjudication")
+	drawScore := fs.Int("draw-score", 0, "draw when both scores are within ±N centipawns")
+	drawMoves := fs.Int("draw-moves", 0, "consecutive moves for draw adjudication")
+	drawMinPly := fs.Int("draw-min-ply", 0, "earliest ply for draw adjudication")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	log, err := newLogger(cfg.Logging)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	openings, err := readOpeningsFile(*openingsFile)
+	if err != nil {
+		return err
+	}
+
+	engines := newEngineRegistry(cfg.Stockfish, log)
+	runner := newMatchRunner(engines, cfg.Stockfish.CommandTimeout, log)
+
+	if *depth > 0 || *nodes > 0 {
+		*moveTime = 0
+	}
+	matchCfg := MatchConfig{
+		PlayerA: MatchPlayer{
+			Engine:  defaultString(*engineA, engines.DefaultName()),
+			Options: parseEngineOptions(*optionsA),
+		},
+		PlayerB: MatchPlayer{
+			Engine:  defaultString(*engineB, engines.DefaultName()),
+			Options: parseEngineOptions(*optionsB),
+		},
+		Games:       *games,
+		Openings:    openings,
+		MoveTime:    *moveTime,
+		Depth:       *depth,
+		Nodes:       *nodes,
+		MaxPlies:    *maxPlies,
+		ResignScore: *resignScore,
+		ResignMoves: *resignMoves,
+		DrawScore:   *drawScore,
+		DrawMoves:   *drawMoves,
+		DrawMinPly:  *drawMinPly,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	result, err := runner.Run(ctx, matchCfg)
+	if err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+// runSuiteCommand implements "mcp-stockfish suite", which runs an EPD test
+// suite from the command line and prints the result as JSON.
+func runSuiteCommand(args []string) error {
+	fs := flag.NewFlagSet("suite", flag.ContinueOnError)
+	engine := fs.String("engine", "", "engine to test (default: the default engine)")
+	file := fs.String("file", "", "EPD file with the suite")
+	moveTime := fs.Duration("movetime", defaultSuiteMoveTime, "time per position")
+	depth := fs.Int("depth", 0, "fixed depth per position")
+	nodes := fs.Int("nodes", 0, "fixed nodes per position")
+	workers := fs.Int("workers", 0, "engines searching in parallel (default: max sessions)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" && fs.NArg() > 0 {
+		*file = fs.Arg(0)
+	}
+	if *file == "" {
+		return fmt.Errorf("no EPD file given")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	log, err := newLogger(cfg.Logging)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return fmt.Errorf("failed to read suite: %w", err)
+	}
+	records, err := parseEPD(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid EPD: %w", err)
+	}
+
+	engines := newEngineRegistry(cfg.Stockfish, log)
+	runner := newSuiteRunner(engines, cfg.Stockfish.MaxSessions, cfg.Stockfish.CommandTimeout, log)
+
+	if *depth > 0 || *nodes > 0 {
+		*moveTime = 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	result, err := runner.Run(ctx, records, SuiteConfig{
+		Engine:   *engine,
+		MoveTime: *moveTime,
+		Depth:    *depth,
+		Nodes:    *nodes,
+		Workers:  *workers,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+// runHealthCommand implements "mcp-stockfish health", which runs the
+// engine readiness checks, prints the report as JSON and fails when the
+// server would not be ready. It suits container health checks; the session
+// pool of a running server is only checked by /readyz and the health tool.
+func runHealthCommand(args []string) error {
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 0, "engine handshake deadline (default: MCP_STOCKFISH_READY_TIMEOUT)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	log, err := newLogger(cfg.Logging)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	if *timeout <= 0 {
+		*timeout = cfg.Stockfish.ReadyTimeout
+	}
+
+	health := newHealthChecker(newEngineRegistry(cfg.Stockfish, log), nil, *timeout, version, log)
+	report := health.Check(context.Background())
+	if err := printJSON(report); err != nil {
+		return err
+	}
+	if !report.Ready {
+		return fmt.Errorf("not ready: %s", report.Status)
+	}
+	return nil
+}
+
+// readOpeningsFile loads openings from a PGN file (all games) or from a
+// text file with one FEN or move line per line.
+func readOpeningsFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read openings: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".pgn") {
+		return []string{string(data)}, nil
+	}
+
+	var openings []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			openings = append(openings, line)
+		}
+	}
+	return openings, nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
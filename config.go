@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -13,6 +16,9 @@ type Config struct {
 	Stockfish StockfishConfig
 	Server    ServerConfig
 	Logging   LoggingConfig
+	Audit     AuditConfig
+	Auth      AuthConfig
+	RateLimit RateLimitConfig
 }
 
 type StockfishConfig struct {
@@ -20,6 +26,67 @@ type StockfishConfig struct {
 	MaxSessions    int
 	SessionTimeout time.Duration
 	CommandTimeout time.Duration
+	DefaultEngine  string
+	Engines        map[string]EngineConfig
+	ShowWDL        bool
+
+	// SyzygyPath is a list of tablebase directories separated like $PATH.
+	SyzygyPath       string
+	SyzygyProbeLimit int
+
+	// BookPath is a Polyglot opening book. UseBook makes game and analysis
+	// tools consult it by default.
+	BookPath string
+	UseBook  bool
+
+	// Requests that find MaxSessions in use wait in a queue of at most
+	// QueueMaxLength entries for up to QueueMaxWait. A zero length fails
+	// them immediately. Sessions idle for EvictIdleAfter may be closed to
+	// make room; zero disables eviction.
+	QueueMaxLength int
+	QueueMaxWait   time.Duration
+	EvictIdleAfter time.Duration
+
+	// PersistentSessions keeps an engine process per client session instead
+	// of one per command. Their state is written to SnapshotPath on
+	// shutdown and every SnapshotInterval, and restored on the next start.
+	PersistentSessions bool
+	SnapshotPath       string
+	SnapshotInterval   time.Duration
+
+	// TranscriptSize is the number of commands kept per persistent session;
+	// zero disables transcripts. TranscriptDir receives JSONL exports.
+	TranscriptSize int
+	TranscriptDir  string
+
+	// Limits are the default resource limits of the engine processes.
+	Limits ResourceLimits
+
+	// ReadyTimeout bounds the engine handshake of the readiness checks.
+	ReadyTimeout time.Duration
+	// AllowDegraded starts the server even when engines fail the startup
+	// check.
+	AllowDegraded bool
+}
+
+// EngineConfig describes one UCI engine binary of the registry.
+type EngineConfig struct {
+	Name           string
+	Path           string
+	Args           []string
+	Options        []EngineOptionValue
+	WorkDir        string
+	MaxSessions    int
+	SessionTimeout time.Duration
+	// ShowWDL enables UCI_ShowWDL on new sessions if the engine supports it.
+	ShowWDL bool
+	Limits  ResourceLimits
+}
+
+// EngineOptionValue is a "setoption" applied to every new session of an engine.
+type EngineOptionValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 type ServerConfig struct {
@@ -37,6 +104,46 @@ type LoggingConfig struct {
 	Output string
 }
 
+// AuthConfig enables authentication of HTTP requests when KeysFile or
+// TokenKeyFile is set. Stdio clients are never authenticated.
+type AuthConfig struct {
+	// KeysFile is a JSON list of API keys with their scopes and quotas.
+	KeysFile string
+	// TokenKeyFile holds the HMAC key of JWT bearer tokens. Tokens must
+	// carry TokenIssuer and TokenAudience when those are set.
+	TokenKeyFile  string
+	TokenIssuer   string
+	TokenAudience string
+}
+
+// RateLimitConfig limits each client, identified like in the audit log.
+type RateLimitConfig struct {
+	// Rate is the number of tool calls per second a client may sustain,
+	// with bursts of Burst calls. Calls over the limit wait for up to
+	// MaxWait, then fail. Zero disables the limit.
+	Rate    float64
+	Burst   int
+	MaxWait time.Duration
+	// EngineTime is the time a client may spend in tool calls within any
+	// EngineTimeWindow. Zero disables the budget.
+	EngineTime       time.Duration
+	EngineTimeWindow time.Duration
+}
+
+// AuditConfig sets up the audit log of tool calls. An empty Path disables it.
+type AuditConfig struct {
+	Path string
+	// The file is rotated at MaxSize bytes or after MaxAge; MaxBackups
+	// rotated files are kept. Zero disables the respective limit.
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+	// MaxOutput is the number of bytes of tool output kept per entry;
+	// zero keeps all of it.
+	MaxOutput int
+	RedactFEN bool
+}
+
 func loadConfig() (*Config, error) {
 	_ = godotenv.Load()
 
@@ -46,6 +153,29 @@ func loadConfig() (*Config, error) {
 			MaxSessions:    getIntEnv("MCP_STOCKFISH_MAX_SESSIONS", 10),
 			SessionTimeout: getDurationEnv("MCP_STOCKFISH_SESSION_TIMEOUT", 30*time.Minute),
 			CommandTimeout: getDurationEnv("MCP_STOCKFISH_COMMAND_TIMEOUT", 30*time.Second),
+			DefaultEngine:  getEnv("MCP_STOCKFISH_DEFAULT_ENGINE", DefaultEngineName),
+			ShowWDL:        getBoolEnv("MCP_STOCKFISH_SHOW_WDL", true),
+
+			SyzygyPath:       getEnv("MCP_STOCKFISH_SYZYGY_PATH", ""),
+			SyzygyProbeLimit: getIntEnv("MCP_STOCKFISH_SYZYGY_PROBE_LIMIT", 7),
+
+			BookPath: getEnv("MCP_STOCKFISH_BOOK_PATH", ""),
+			UseBook:  getBoolEnv("MCP_STOCKFISH_USE_BOOK", false),
+
+			QueueMaxLength: getIntEnv("MCP_STOCKFISH_QUEUE_MAX_LENGTH", 32),
+			QueueMaxWait:   getDurationEnv("MCP_STOCKFISH_QUEUE_MAX_WAIT", 30*time.Second),
+			EvictIdleAfter: getDurationEnv("MCP_STOCKFISH_EVICT_IDLE_AFTER", time.Minute),
+
+			SnapshotPath:     getEnv("MCP_STOCKFISH_SNAPSHOT_PATH", ""),
+			SnapshotInterval: getDurationEnv("MCP_STOCKFISH_SNAPSHOT_INTERVAL", time.Minute),
+
+			TranscriptSize: getIntEnv("MCP_STOCKFISH_TRANSCRIPT_SIZE", 100),
+			TranscriptDir:  getEnv("MCP_STOCKFISH_TRANSCRIPT_DIR", ""),
+
+			Limits: loadResourceLimits("MCP_STOCKFISH_", ResourceLimits{}),
+
+			ReadyTimeout:  getDurationEnv("MCP_STOCKFISH_READY_TIMEOUT", 5*time.Second),
+			AllowDegraded: getBoolEnv("MCP_STOCKFISH_ALLOW_DEGRADED", false),
 		},
 		Server: ServerConfig{
 			Name:    getEnv("MCP_STOCKFISH_SERVER_NAME", "mcp-stockfish ♟️"),
@@ -60,8 +190,35 @@ func loadConfig() (*Config, error) {
 			Format: getEnv("MCP_STOCKFISH_LOG_FORMAT", "console"),
 			Output: getEnv("MCP_STOCKFISH_LOG_OUTPUT", "stderr"),
 		},
+		Auth: AuthConfig{
+			KeysFile:      getEnv("MCP_STOCKFISH_AUTH_KEYS_FILE", ""),
+			TokenKeyFile:  getEnv("MCP_STOCKFISH_AUTH_TOKEN_KEY_FILE", ""),
+			TokenIssuer:   getEnv("MCP_STOCKFISH_AUTH_TOKEN_ISSUER", ""),
+			TokenAudience: getEnv("MCP_STOCKFISH_AUTH_TOKEN_AUDIENCE", ""),
+		},
+		RateLimit: RateLimitConfig{
+			Rate:             float64(getIntEnv("MCP_STOCKFISH_RATE_LIMIT", 0)) / 60,
+			Burst:            getIntEnv("MCP_STOCKFISH_RATE_BURST", 10),
+			MaxWait:          getDurationEnv("MCP_STOCKFISH_RATE_MAX_WAIT", 0),
+			EngineTime:       getDurationEnv("MCP_STOCKFISH_ENGINE_TIME_QUOTA", 0),
+			EngineTimeWindow: getDurationEnv("MCP_STOCKFISH_ENGINE_TIME_WINDOW", time.Hour),
+		},
+		Audit: AuditConfig{
+			Path:       getEnv("MCP_STOCKFISH_AUDIT_LOG", ""),
+			MaxSize:    int64(getIntEnv("MCP_STOCKFISH_AUDIT_MAX_SIZE_MB", 100)) << 20,
+			MaxAge:     getDurationEnv("MCP_STOCKFISH_AUDIT_MAX_AGE", 24*time.Hour),
+			MaxBackups: getIntEnv("MCP_STOCKFISH_AUDIT_MAX_BACKUPS", 7),
+			MaxOutput:  getIntEnv("MCP_STOCKFISH_AUDIT_MAX_OUTPUT", 2048),
+			RedactFEN:  getBoolEnv("MCP_STOCKFISH_AUDIT_REDACT_FEN", false),
+		},
 	}
 
+	config.Stockfish.Engines = loadEngines(config.Stockfish)
+	config.Stockfish.PersistentSessions = getBoolEnv(
+		"MCP_STOCKFISH_PERSISTENT_SESSIONS",
+		config.Server.Mode == "http",
+	)
+
 	if err := validateConfig(config); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -78,6 +235,67 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("session_timeout must be positive")
 	}
 
+	if config.Stockfish.QueueMaxLength < 0 {
+		return fmt.Errorf("queue_max_length must not be negative")
+	}
+
+	if config.Stockfish.QueueMaxWait < 0 || config.Stockfish.EvictIdleAfter < 0 {
+		return fmt.Errorf("queue_max_wait and evict_idle_after must not be negative")
+	}
+
+	if config.Stockfish.SnapshotInterval < 0 {
+		return fmt.Errorf("snapshot_interval must not be negative")
+	}
+
+	if config.Stockfish.TranscriptSize < 0 {
+		return fmt.Errorf("transcript_size must not be negative")
+	}
+
+	if config.Stockfish.ReadyTimeout <= 0 {
+		return fmt.Errorf("ready_timeout must be positive")
+	}
+
+	if _, ok := config.Stockfish.Engines[config.Stockfish.DefaultEngine]; !ok {
+		return fmt.Errorf("default engine %q is not configured", config.Stockfish.DefaultEngine)
+	}
+
+	for name, engine := range config.Stockfish.Engines {
+		if engine.Path == "" {
+			return fmt.Errorf("engine %q: path must be set", name)
+		}
+		if engine.MaxSessions <= 0 {
+			return fmt.Errorf("engine %q: max_sessions must be positive", name)
+		}
+		if engine.SessionTimeout <= 0 {
+			return fmt.Errorf("engine %q: session_timeout must be positive", name)
+		}
+		if err := engine.Limits.validate(); err != nil {
+			return fmt.Errorf("engine %q: %w", name, err)
+		}
+	}
+
+	if config.Stockfish.SyzygyPath != "" {
+		for _, dir := range filepath.SplitList(config.Stockfish.SyzygyPath) {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("syzygy path %q is not a directory", dir)
+			}
+		}
+	}
+
+	if config.Stockfish.SyzygyProbeLimit < 0 || config.Stockfish.SyzygyProbeLimit > 7 {
+		return fmt.Errorf("syzygy_probe_limit must be between 0 and 7")
+	}
+
+	if config.Stockfish.BookPath != "" {
+		if info, err := os.Stat(config.Stockfish.BookPath); err != nil || info.IsDir() {
+			return fmt.Errorf("book path %q is not a file", config.Stockfish.BookPath)
+		}
+	}
+
+	if config.Stockfish.UseBook && config.Stockfish.BookPath == "" {
+		return fmt.Errorf("use_book requires a book path")
+	}
+
 	if config.Server.Mode != "stdio" && config.Server.Mode != "http" {
 		return fmt.Errorf("server mode must be 'stdio' or 'http'")
 	}
@@ -95,9 +313,125 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("invalid log level: %s", config.Logging.Level)
 	}
 
+	if config.Audit.MaxSize < 0 || config.Audit.MaxAge < 0 || config.Audit.MaxBackups < 0 ||
+		config.Audit.MaxOutput < 0 {
+		return fmt.Errorf("audit log limits must not be negative")
+	}
+
+	if limits := config.RateLimit; limits.Rate < 0 || limits.MaxWait < 0 || limits.EngineTime < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+	if config.RateLimit.Rate > 0 && config.RateLimit.Burst < 1 {
+		return fmt.Errorf("rate_burst must be at least 1")
+	}
+	if config.RateLimit.EngineTime > 0 && config.RateLimit.EngineTimeWindow <= 0 {
+		return fmt.Errorf("engine_time_window must be positive")
+	}
+
 	return nil
 }
 
+// loadEngines builds the engine registry. The engine named DefaultEngineName
+// always comes from MCP_STOCKFISH_PATH; further engines are listed in
+// MCP_STOCKFISH_ENGINES and configured through MCP_STOCKFISH_ENGINE_<NAME>_*.
+// Server-wide options such as SyzygyPath come first so that per-engine
+// options can override them.
+func loadEngines(sf StockfishConfig) map[string]EngineConfig {
+	engines := map[string]EngineConfig{
+		DefaultEngineName: {
+			Name:           DefaultEngineName,
+			Path:           sf.Path,
+			Options:        sf.globalOptions(),
+			MaxSessions:    sf.MaxSessions,
+			SessionTimeout: sf.SessionTimeout,
+			ShowWDL:        sf.ShowWDL,
+			Limits:         sf.Limits,
+		},
+	}
+
+	for _, name := range getListEnv("MCP_STOCKFISH_ENGINES", ",") {
+		prefix := "MCP_STOCKFISH_ENGINE_" + engineEnvKey(name) + "_"
+		options := parseEngineOptions(getEnv(prefix+"OPTIONS", ""))
+		engines[name] = EngineConfig{
+			Name:           name,
+			Path:           getEnv(prefix+"PATH", ""),
+			Args:           strings.Fields(getEnv(prefix+"ARGS", "")),
+			Options:        append(sf.globalOptions(), options...),
+			WorkDir:        getEnv(prefix+"WORKDIR", ""),
+			MaxSessions:    getIntEnv(prefix+"MAX_SESSIONS", sf.MaxSessions),
+			SessionTimeout: getDurationEnv(prefix+"SESSION_TIMEOUT", sf.SessionTimeout),
+			ShowWDL:        getBoolEnv(prefix+"SHOW_WDL", sf.ShowWDL),
+			Limits:         loadResourceLimits(prefix, sf.Limits),
+		}
+	}
+
+	return engines
+}
+
+// globalOptions are the options applied to every engine session.
+func (c StockfishConfig) globalOptions() []EngineOptionValue {
+	var options []EngineOptionValue
+	if c.SyzygyPath != "" {
+		options = append(options,
+			EngineOptionValue{Name: "SyzygyPath", Value: c.SyzygyPath},
+			EngineOptionValue{Name: "SyzygyProbeLimit", Value: strconv.Itoa(c.SyzygyProbeLimit)},
+		)
+	}
+	return options
+}
+
+// loadResourceLimits reads the engine process limits from the variables
+// under prefix, either MCP_STOCKFISH_ for the defaults or an engine's own
+// MCP_STOCKFISH_ENGINE_<NAME>_ prefix. Variables that are not set keep the
+// value from defaults; MEMORY_LIMIT_MB is converted to bytes.
+func loadResourceLimits(prefix string, defaults ResourceLimits) ResourceLimits {
+	return ResourceLimits{
+		CPUAffinity:  getEnv(prefix+"CPU_AFFINITY", defaults.CPUAffinity),
+		Nice:         getIntEnv(prefix+"NICE", defaults.Nice),
+		MemoryBytes:  int64(getIntEnv(prefix+"MEMORY_LIMIT_MB", int(defaults.MemoryBytes>>20))) << 20,
+		CgroupParent: getEnv(prefix+"CGROUP_PARENT", defaults.CgroupParent),
+		MaxOpenFiles: uint64(max(getIntEnv(prefix+"MAX_OPEN_FILES", int(defaults.MaxOpenFiles)), 0)),
+		SessionDir:   getEnv(prefix+"SESSION_DIR", defaults.SessionDir),
+	}
+}
+
+// parseEngineOptions parses "Hash=128;Threads=2" into setoption values.
+func parseEngineOptions(raw string) []EngineOptionValue {
+	var options []EngineOptionValue
+	for _, pair := range strings.Split(raw, ";") {
+		name, value, _ := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		options = append(options, EngineOptionValue{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return options
+}
+
+func engineEnvKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// engineNames returns the configured engine names in a stable order.
+func (c StockfishConfig) engineNames() []string {
+	names := make([]string, 0, len(c.Engines))
+	for name := range c.Engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -131,3 +465,13 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getListEnv(key, sep string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), sep) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
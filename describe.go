@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const defaultThreatDepth = 12
+
+var pieceNames = [...]string{
+	Pawn:   "pawn",
+	Knight: "knight",
+	Bishop: "bishop",
+	Rook:   "rook",
+	Queen:  "queen",
+	King:   "king",
+}
+
+// pieceLabel names a piece on a square, e.g. "white knight on f3".
+func (p *Position) pieceLabel(sq Square) string {
+	piece := p.board[sq]
+	return fmt.Sprintf("%s %s on %s", piece.Color(), pieceNames[piece.Type()], sq)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+type SideMaterial struct {
+	Pawns      int  `json:"pawns"`
+	Knights    int  `json:"knights"`
+	Bishops    int  `json:"bishops"`
+	Rooks      int  `json:"rooks"`
+	Queens     int  `json:"queens"`
+	Points     int  `json:"points"`
+	BishopPair bool `json:"bishop_pair,omitempty"`
+}
+
+type MaterialInfo struct {
+	White SideMaterial `json:"white"`
+	Black SideMaterial `json:"black"`
+	// Balance is White's points minus Black's.
+	Balance int `json:"balance"`
+}
+
+// PieceNote describes a piece together with the pieces attacking and
+// defending it.
+type PieceNote struct {
+	Square    string   `json:"square"`
+	Piece     string   `json:"piece"`
+	Color     string   `json:"color"`
+	Attackers []string `json:"attackers,omitempty"`
+	Defenders []string `json:"defenders,omitempty"`
+}
+
+type Pin struct {
+	Pinned   string `json:"pinned"`
+	PinnedTo string `json:"pinned_to"`
+	By       string `json:"by"`
+	// Absolute pins are pins to the king: the pinned piece cannot move
+	// off the line at all.
+	Absolute bool `json:"absolute"`
+}
+
+type PawnStructure struct {
+	Passed   []string `json:"passed"`
+	Doubled  []string `json:"doubled"`
+	Isolated []string `json:"isolated"`
+	Islands  int      `json:"islands"`
+}
+
+type KingSafety struct {
+	Square   string `json:"square"`
+	Location string `json:"location"`
+	// ShieldPawns counts own pawns on the three files around the king, one
+	// or two ranks in front of it.
+	ShieldPawns    int      `json:"shield_pawns"`
+	OpenFiles      []string `json:"open_files,omitempty"`
+	HalfOpenFiles  []string `json:"half_open_files,omitempty"`
+	AttackedSquare int      `json:"attacked_squares"`
+	Status         string   `json:"status"`
+}
+
+type Threat struct {
+	UCI     string   `json:"uci"`
+	SAN     string   `json:"san"`
+	ScoreCP *int     `json:"score_cp,omitempty"`
+	Mate    *int     `json:"mate,omitempty"`
+	PV      []string `json:"pv"`
+}
+
+type PositionDescription struct {
+	Status        string                   `json:"status"`
+	FEN           string                   `json:"fen"`
+	Turn          string                   `json:"turn"`
+	Material      MaterialInfo             `json:"material"`
+	InCheck       bool                     `json:"in_check"`
+	Checkers      []string                 `json:"checkers,omitempty"`
+	CheckingMoves []string                 `json:"checking_moves"`
+	Hanging       []PieceNote              `json:"hanging"`
+	Undefended    []PieceNote              `json:"undefended"`
+	AttackedLower []PieceNote              `json:"attacked_by_lower_value"`
+	Pins          []Pin                    `json:"pins"`
+	Pawns         map[string]PawnStructure `json:"pawn_structure"`
+	KingSafety    map[string]KingSafety    `json:"king_safety"`
+	// Threat is what the opponent would play if the side to move passed,
+	// found with a null-move search. Scores are from the opponent's view.
+	Threat      *Threat  `json:"threat,omitempty"`
+	ThreatError string   `json:"threat_error,omitempty"`
+	Summary     []string `json:"summary"`
+}
+
+// describePosition collects the static facts of a position. The engine
+// threat is added separately by the analyzer.
+func describePosition(p *Position) *PositionDescription {
+	d := &PositionDescription{
+		Status:        "success",
+		FEN:           p.FEN(),
+		Turn:          p.turn.String(),
+		Material:      p.materialInfo(),
+		InCheck:       p.InCheck(),
+		CheckingMoves: []string{},
+		Hanging:       []PieceNote{},
+		Undefended:    []PieceNote{},
+		AttackedLower: []PieceNote{},
+		Pins:          p.pins(),
+		Pawns:         map[string]PawnStructure{},
+		KingSafety:    map[string]KingSafety{},
+	}
+
+	if d.InCheck {
+		for _, sq := range p.attackers(p.kingSquare(p.turn), p.turn.Other()) {
+			d.Checkers = append(d.Checkers, p.pieceLabel(sq))
+		}
+	}
+	for _, m := range p.LegalMoves() {
+		if p.Play(m).InCheck() {
+			d.CheckingMoves = append(d.CheckingMoves, p.SAN(m))
+		}
+	}
+
+	for i, piece := range p.board {
+		sq := Square(i)
+		if piece == NoPiece || piece.Type() == King {
+			continue
+		}
+		attackers := p.attackers(sq, piece.Color().Other())
+		defenders := p.attackers(sq, piece.Color())
+		note := PieceNote{
+			Square:    sq.String(),
+			Piece:     pieceNames[piece.Type()],
+			Color:     piece.Color().String(),
+			Attackers: p.labels(attackers),
+			Defenders: p.labels(defenders),
+		}
+		switch {
+		case len(attackers) > 0 && len(defenders) == 0:
+			d.Hanging = append(d.Hanging, note)
+		case len(defenders) == 0 && piece.Type() != Pawn:
+			d.Undefended = append(d.Undefended, note)
+		}
+		for _, from := range attackers {
+			attacker := p.board[from].Type()
+			if attacker != King && pieceValues[attacker] < pieceValues[piece.Type()] {
+				d.AttackedLower = append(d.AttackedLower, note)
+				break
+			}
+		}
+	}
+
+	for _, c := range []Color{White, Black} {
+		d.Pawns[c.String()] = p.pawnStructure(c)
+		d.KingSafety[c.String()] = p.kingSafety(c)
+	}
+
+	d.Summary = d.summarize(p)
+	return d
+}
+
+func (p *Position) labels(squares []Square) []string {
+	var labels []string
+	for _, sq := range squares {
+		labels = append(labels, p.pieceLabel(sq))
+	}
+	return labels
+}
+
+func (p *Position) materialInfo() MaterialInfo {
+	var sides [2]SideMaterial
+	for _, piece := range p.board {
+		if piece == NoPiece {
+			continue
+		}
+		side := &sides[piece.Color()]
+		switch piece.Type() {
+		case Pawn:
+			side.Pawns++
+		case Knight:
+			side.Knights++
+		case Bishop:
+			side.Bishops++
+		case Rook:
+			side.Rooks++
+		case Queen:
+			side.Queens++
+		}
+		side.Points += pieceValues[piece.Type()]
+	}
+	for i := range sides {
+		sides[i].BishopPair = sides[i].Bishops >= 2
+	}
+	return MaterialInfo{
+		White:   sides[White],
+		Black:   sides[Black],
+		Balance: sides[White].Points - sides[Black].Points,
+	}
+}
+
+// pins finds pieces pinned to their king (absolute) or to their queen by a
+// less valuable slider.
+func (p *Position) pins() []Pin {
+	pins := []Pin{}
+	for i, target := range p.board {
+		if target.Type() != King && target.Type() != Queen {
+			continue
+		}
+		from := Square(i)
+		own, enemy := target.Color(), target.Color().Other()
+
+		check := func(dirs [4][2]int, slider PieceType) {
+			for _, d := range dirs {
+				pinned := NoSquare
+				for f, r := from.File()+d[0], from.Rank()+d[1]; ; f, r = f+d[0], r+d[1] {
+					sq := newSquare(f, r)
+					if sq == NoSquare {
+						break
+					}
+					piece := p.board[sq]
+					if piece == NoPiece {
+						continue
+					}
+					if pinned == NoSquare {
+						if piece.Color() != own {
+							break
+						}
+						pinned = sq
+						continue
+					}
+					isSlider := piece.Type() == slider || piece.Type() == Queen
+					if piece.Color() == enemy && isSlider &&
+						(target.Type() == King || pieceValues[piece.Type()] < pieceValues[Queen]) {
+						pins = append(pins, Pin{
+							Pinned:   p.pieceLabel(pinned),
+							PinnedTo: p.pieceLabel(from),
+							By:       p.pieceLabel(sq),
+							Absolute: target.Type() == King,
+						})
+					}
+					break
+				}
+			}
+		}
+		check(rookDirs, Rook)
+		check(bishopDirs, Bishop)
+	}
+	return pins
+}
+
+func (p *Position) pawnStructure(c Color) PawnStructure {
+	ps := PawnStructure{Passed: []string{}, Doubled: []string{}, Isolated: []string{}}
+	var own, enemy [8][]int // ranks of pawns per file
+	for i, piece := range p.board {
+		if piece.Type() != Pawn {
+			continue
+		}
+		sq := Square(i)
+		if piece.Color() == c {
+			own[sq.File()] = append(own[sq.File()], sq.Rank())
+		} else {
+			enemy[sq.File()] = append(enemy[sq.File()], sq.Rank())
+		}
+	}
+
+	ahead := func(rank, other int) bool {
+		if c == White {
+			return other > rank
+		}
+		return other < rank
+	}
+
+	inIsland := false
+	for file := 0; file < 8; file++ {
+		if len(own[file]) == 0 {
+			inIsland = false
+			continue
+		}
+		if !inIsland {
+			ps.Islands++
+			inIsland = true
+		}
+		if len(own[file]) > 1 {
+			ps.Doubled = append(ps.Doubled, string(rune('a'+file)))
+		}
+
+		isolated := (file == 0 || len(own[file-1]) == 0) && (file == 7 || len(own[file+1]) == 0)
+		for _, rank := range own[file] {
+			sq := newSquare(file, rank).String()
+			if isolated {
+				ps.Isolated = append(ps.Isolated, sq)
+			}
+			passed := true
+			for f := max(file-1, 0); f <= min(file+1, 7); f++ {
+				for _, other := range enemy[f] {
+					if ahead(rank, other) {
+						passed = false
+					}
+				}
+			}
+			if passed {
+				ps.Passed = append(ps.Passed, sq)
+			}
+		}
+	}
+	sort.Strings(ps.Passed)
+	sort.Strings(ps.Isolated)
+	return ps
+}
+
+func (p *Position) kingSafety(c Color) KingSafety {
+	king := p.kingSquare(c)
+	ks := KingSafety{Square: king.String()}
+
+	backRank, forward := 0, 1
+	if c == Black {
+		backRank, forward = 7, -1
+	}
+	switch {
+	case king.Rank() != backRank:
+		ks.Location = "advanced"
+	case king.File() >= 5:
+		ks.Location = "kingside"
+	case king.File() <= 2:
+		ks.Location = "queenside"
+	default:
+		ks.Location = "center"
+	}
+
+	for f := max(king.File()-1, 0); f <= min(king.File()+1, 7); f++ {
+		for step := 1; step <= 2; step++ {
+			if sq := newSquare(f, king.Rank()+step*forward); sq != NoSquare &&
+				p.board[sq] == makePiece(c, Pawn) {
+				ks.ShieldPawns++
+				break
+			}
+		}
+
+		ownPawn, enemyPawn := false, false
+		for r := 0; r < 8; r++ {
+			switch p.board[newSquare(f, r)] {
+			case makePiece(c, Pawn):
+				ownPawn = true
+			case makePiece(c.Other(), Pawn):
+				enemyPawn = true
+			}
+		}
+		file := string(rune('a' + f))
+		switch {
+		case !ownPawn && !enemyPawn:
+			ks.OpenFiles = append(ks.OpenFiles, file)
+		case !ownPawn:
+			ks.HalfOpenFiles = append(ks.HalfOpenFiles, file)
+		}
+	}
+
+	for _, off := range kingOffsets {
+		if sq := newSquare(king.File()+off[0], king.Rank()+off[1]); sq != NoSquare &&
+			p.isAttacked(sq, c.Other()) {
+			ks.AttackedSquare++
+		}
+	}
+
+	exposure := len(ks.OpenFiles)*2 + len(ks.HalfOpenFiles) + max(0, 2-ks.ShieldPawns)
+	switch {
+	case exposure == 0 && ks.AttackedSquare == 0:
+		ks.Status = "safe"
+	case exposure <= 2 && ks.AttackedSquare <= 2:
+		ks.Status = "slightly weakened"
+	default:
+		ks.Status = "exposed"
+	}
+	return ks
+}
+
+// summarize turns the facts into short sentences an assistant can quote.
+func (d *PositionDescription) summarize(p *Position) []string {
+	var s []string
+
+	switch balance := d.Material.Balance; {
+	case balance > 0:
+		s = append(s, fmt.Sprintf("White is up %d point(s) of material", balance))
+	case balance < 0:
+		s = append(s, fmt.Sprintf("Black is up %d point(s) of material", -balance))
+	default:
+		s = append(s, "Material is level")
+	}
+
+	if d.InCheck {
+		s = append(s, fmt.Sprintf("%s is in check from the %s",
+			capitalize(d.Turn), strings.Join(d.Checkers, " and ")))
+	}
+	for _, n := range d.Hanging {
+		s = append(s, fmt.Sprintf("The %s %s on %s is hanging (attacked by the %s, undefended)",
+			n.Color, n.Piece, n.Square, strings.Join(n.Attackers, ", ")))
+	}
+	for _, n := range d.AttackedLower {
+		if len(n.Defenders) == 0 {
+			continue // already reported as hanging
+		}
+		s = append(s, fmt.Sprintf("The %s %s on %s is attacked by a less valuable piece (%s)",
+			n.Color, n.Piece, n.Square, strings.Join(n.Attackers, ", ")))
+	}
+	for _, pin := range d.Pins {
+		s = append(s, fmt.Sprintf("The %s is pinned to the %s by the %s", pin.Pinned, pin.PinnedTo, pin.By))
+	}
+	for _, c := range []Color{White, Black} {
+		if passed := d.Pawns[c.String()].Passed; len(passed) > 0 {
+			s = append(s, fmt.Sprintf("%s has passed pawn(s) on %s", capitalize(c.String()), strings.Join(passed, ", ")))
+		}
+		if ks := d.KingSafety[c.String()]; ks.Status != "safe" {
+			s = append(s, fmt.Sprintf("%s's king on %s is %s", capitalize(c.String()), ks.Square, ks.Status))
+		}
+	}
+	if len(d.CheckingMoves) > 0 {
+		s = append(s, fmt.Sprintf("%s has checks: %s", capitalize(d.Turn), strings.Join(d.CheckingMoves, ", ")))
+	}
+	return s
+}
+
+// nullMove returns the position with the other side to move, or an error if
+// passing is not possible because the side to move is in check.
+func (p *Position) nullMove() (*Position, error) {
+	if p.InCheck() {
+		return nil, fmt.Errorf("cannot pass while in check")
+	}
+	next := *p
+	next.turn = p.turn.Other()
+	next.epSquare = NoSquare
+	return &next, nil
+}
+
+// Threat searches the position with the side to move passing, which reveals
+// what the opponent is threatening to do.
+func (a *Analyzer) Threat(
+	ctx context.Context,
+	p *Position,
+	engineName string,
+	depth, moveTimeMS int,
+) (*Threat, error) {
+	null, err := p.nullMove()
+	if err != nil {
+		return nil, err
+	}
+	if len(null.LegalMoves()) == 0 {
+		return nil, fmt.Errorf("the opponent has no moves")
+	}
+
+	engine, err := a.engines.Get(engineName)
+	if err != nil {
+		return nil, err
+	}
+	release, err := a.engines.reserve(ctx, 1, PriorityInteractive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	player := &enginePlayer{config: engine, logger: a.logger}
+	defer player.close()
+
+	if depth <= 0 && moveTimeMS <= 0 {
+		depth = defaultThreatDepth
+	}
+	search, err := player.search(
+		ctx,
+		newGame(null).PositionCommand(),
+		analysisLimits(depth, moveTimeMS),
+		a.timeout(moveTimeMS),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m, err := null.ParseUCI(search.BestMove)
+	if err != nil {
+		return nil, fmt.Errorf("engine returned an illegal move: %w", err)
+	}
+
+	threat := &Threat{UCI: null.UCI(m), SAN: null.SAN(m), PV: []string{null.SAN(m)}}
+	if line := search.Best(); line != nil {
+		threat.ScoreCP, threat.Mate = line.ScoreCP, line.Mate
+		if len(line.PV) > 0 && line.PV[0] == threat.UCI {
+			threat.PV = pvToSAN(null, line.PV)
+		}
+	}
+	return threat, nil
+}
+
+func (a *Analyzer) handleDescribePosition(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	fen := request.GetString("fen", startFEN)
+	moves := request.GetStringSlice("moves", nil)
+	engineName := request.GetString("engine", "")
+
+	a.logger.Info().Str("fen", fen).Int("moves", len(moves)).Msg("Received position description request")
+
+	game, err := newGameFromMoves(fen, moves)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid position: %s", err.Error())), nil
+	}
+	pos := game.Position()
+	d := describePosition(pos)
+
+	if request.GetBool("threat", true) {
+		threat, err := a.Threat(ctx, pos, engineName, request.GetInt("depth", 0), request.GetInt("movetime", 0))
+		if err != nil {
+			a.logger.Debug().Err(err).Msg("No threat search")
+			d.ThreatError = err.Error()
+		} else {
+			d.Threat = threat
+			d.Summary = append(d.Summary, fmt.Sprintf("%s threatens %s",
+				capitalize(pos.Turn().Other().String()), threat.SAN))
+		}
+	}
+
+	return jsonToolResult(d, a.logger)
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+// NNUE statuses of an engine, as reported by its first search.
+const (
+	NNUEEnabled  = "enabled"
+	NNUEDisabled = "disabled"
+	NNUEError    = "error"
+)
+
+// nnuePattern matches Stockfish network file names.
+var nnuePattern = regexp.MustCompile(`nn-[0-9a-f]+\.nnue`)
+
+// EngineInfo is what an engine reports about itself in its "uci" reply,
+// plus the evaluation network it announces when searching.
+type EngineInfo struct {
+	Name     string       `json:"name"`
+	Path     string       `json:"path"`
+	IDName   string       `json:"id_name,omitempty"`
+	IDAuthor string       `json:"id_author,omitempty"`
+	Options  []uci.Option `json:"options"`
+	// NNUE is empty when the engine says nothing about its evaluation.
+	NNUE     string   `json:"nnue,omitempty"`
+	Networks []string `json:"networks,omitempty"`
+	// NNUEMessage is the engine's report when NNUE is not enabled.
+	NNUEMessage string `json:"nnue_message,omitempty"`
+}
+
+// Option returns the option of that name, compared case-insensitively as
+// UCI option names are.
+func (i *EngineInfo) Option(name string) (uci.Option, bool) {
+	return (&uci.ID{Options: i.Options}).Option(name)
+}
+
+// EngineRegistry resolves engine names to their configuration and caches
+// the identification each engine reports on "uci".
+type EngineRegistry struct {
+	config         StockfishConfig
+	commandTimeout time.Duration
+	logger         zerolog.Logger
+
+	mu   sync.Mutex
+	info map[string]*EngineInfo
+	// failures keeps the last error of engines that could not be described.
+	failures map[string]error
+	// describing holds the identification in progress of each engine, which
+	// concurrent callers of Describe wait for.
+	describing map[string]*describeCall
+
+	// slots counts the engines running under MCP_STOCKFISH_MAX_SESSIONS.
+	slots *engineSlots
+	// queue, when set, orders reservations with the queued session
+	// requests by priority.
+	queue slotQueue
+}
+
+// slotQueue hands out engine slots in priority order.
+type slotQueue interface {
+	reserveSlots(ctx context.Context, need, n int, priority Priority) (int, error)
+}
+
+func newEngineRegistry(config StockfishConfig, logger zerolog.Logger) *EngineRegistry {
+	return &EngineRegistry{
+		config:         config,
+		commandTimeout: config.CommandTimeout,
+		logger:         logger.With().Str("component", ComponentEngineRegistry).Logger(),
+		info:           make(map[string]*EngineInfo),
+		failures:       make(map[string]error),
+		describing:     make(map[string]*describeCall),
+		slots:          newEngineSlots(config.MaxSessions),
+	}
+}
+
+// reserve takes n engine slots for processes started outside the session
+// pool, waiting for them as long as a queued session request would, in
+// the session queue at priority when there is one. The returned function
+// frees them.
+func (r *EngineRegistry) reserve(ctx context.Context, n int, priority Priority) (func(), error) {
+	if _, err := r.acquire(ctx, n, n, priority); err != nil {
+		return nil, err
+	}
+	return sync.OnceFunc(func() { r.slots.release(n) }), nil
+}
+
+// reserveUpTo takes between one and n engine slots, as many as are free
+// once one is, for batch work that can spread over a varying number of
+// engines.
+func (r *EngineRegistry) reserveUpTo(ctx context.Context, n int) (int, func(), error) {
+	taken, err := r.acquire(ctx, 1, n, PriorityBatch)
+	if err != nil {
+		return 0, nil, err
+	}
+	return taken, sync.OnceFunc(func() { r.slots.release(taken) }), nil
+}
+
+func (r *EngineRegistry) acquire(ctx context.Context, need, n int, priority Priority) (int, error) {
+	if r.queue != nil {
+		return r.queue.reserveSlots(ctx, need, n, priority)
+	}
+	return r.slots.acquireUpTo(ctx, need, n, r.slotWait())
+}
+
+// slotWait is how long a request waits for a free engine: QueueMaxWait,
+// or not at all when queueing is disabled.
+func (r *EngineRegistry) slotWait() time.Duration {
+	if r.config.QueueMaxLength == 0 {
+		return 0
+	}
+	return r.config.QueueMaxWait
+}
+
+// Get returns the configuration of the named engine. An empty name selects
+// the default engine.
+func (r *EngineRegistry) Get(name string) (EngineConfig, error) {
+	if name == "" {
+		name = r.config.DefaultEngine
+	}
+	engine, ok := r.config.Engines[name]
+	if !ok {
+		return EngineConfig{}, fmt.Errorf(
+			"unknown engine %q (available: %s)",
+			name,
+			strings.Join(r.Names(), ", "),
+		)
+	}
+	return engine, nil
+}
+
+func (r *EngineRegistry) Names() []string {
+	return r.config.engineNames()
+}
+
+func (r *EngineRegistry) DefaultName() string {
+	return r.config.DefaultEngine
+}
+
+// describeCall is an identification in progress.
+type describeCall struct {
+	done chan struct{}
+	info *EngineInfo
+	err  error
+}
+
+// Describe launches the engine once, performs the "uci" handshake and a
+// depth 1 search, which makes NNUE engines load and report their network,
+// and caches the result for subsequent calls. Failures are not cached.
+// Concurrent calls share one launch, which runs without holding r.mu so
+// that Status never waits for an engine.
+func (r *EngineRegistry) Describe(name string) (*EngineInfo, error) {
+	engine, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	if info, ok := r.info[engine.Name]; ok {
+		r.mu.Unlock()
+		return info, nil
+	}
+	if call, ok := r.describing[engine.Name]; ok {
+		r.mu.Unlock()
+		<-call.done
+		return call.info, call.err
+	}
+	call := &describeCall{done: make(chan struct{})}
+	r.describing[engine.Name] = call
+	r.mu.Unlock()
+
+	call.info, call.err = r.identify(engine)
+
+	r.mu.Lock()
+	delete(r.describing, engine.Name)
+	if call.err != nil {
+		r.failures[engine.Name] = call.err
+	} else {
+		delete(r.failures, engine.Name)
+		r.info[engine.Name] = call.info
+	}
+	r.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	info := call.info
+
+	r.logger.Debug().
+		Str("engine", engine.Name).
+		Str("id_name", info.IDName).
+		Int("options", len(info.Options)).
+		Str("nnue", info.NNUE).
+		Msg("Engine identified")
+
+	return info, nil
+}
+
+func (r *EngineRegistry) identify(engine EngineConfig) (*EngineInfo, error) {
+	session, err := createEphemeralStockfishSession(engine, r.logger)
+	if err != nil {
+		return nil, err
+	}
+	defer session.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.commandTimeout)
+	defer cancel()
+
+	id, err := session.client.Handshake(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("engine %q: uci handshake failed: %w", engine.Name, commandError(err, r.commandTimeout))
+	}
+
+	info := &EngineInfo{
+		Name:     engine.Name,
+		Path:     engine.Path,
+		IDName:   id.Name,
+		IDAuthor: id.Author,
+		Options:  id.Options,
+	}
+
+	if err := session.client.SetPosition(ctx, ""); err != nil {
+		return nil, fmt.Errorf("engine %q: %w", engine.Name, err)
+	}
+	result, err := session.client.Go(ctx, uci.Limits{Depth: 1}, nil)
+	info.NNUE, info.Networks, info.NNUEMessage = parseNNUEStatus(result.Lines)
+	if err != nil {
+		err = commandError(err, r.commandTimeout)
+		if info.NNUEMessage != "" {
+			err = fmt.Errorf("%w (%s)", err, info.NNUEMessage)
+		}
+		return nil, fmt.Errorf("engine %q: test search failed: %w", engine.Name, err)
+	}
+	return info, nil
+}
+
+// parseNNUEStatus reads the "info string" lines Stockfish prints about its
+// evaluation: "NNUE evaluation using nn-....nnue", "classical evaluation
+// enabled" or an "ERROR:" about the network file.
+func parseNNUEStatus(lines []string) (status string, networks []string, message string) {
+	for _, line := range lines {
+		info, ok := uci.ParseInfo(line)
+		if !ok || info.String == "" {
+			continue
+		}
+		text := info.String
+		lower := strings.ToLower(text)
+		switch {
+		case strings.HasPrefix(lower, "error") && (strings.Contains(lower, "network") || strings.Contains(lower, "nnue")):
+			if status != NNUEError {
+				status, message = NNUEError, text
+			}
+		case strings.Contains(lower, "nnue evaluation using"):
+			if status == "" {
+				status = NNUEEnabled
+			}
+			for _, network := range nnuePattern.FindAllString(text, -1) {
+				if !slices.Contains(networks, network) {
+					networks = append(networks, network)
+				}
+			}
+		case strings.Contains(lower, "classical evaluation enabled"):
+			if status == "" {
+				status, message = NNUEDisabled, text
+			}
+		}
+	}
+	return status, networks, message
+}
+
+// Status returns what is known about an engine without launching it: its
+// description, or the error of the last attempt. Both are nil for engines
+// not described yet.
+func (r *EngineRegistry) Status(name string) (*EngineInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		name = r.config.DefaultEngine
+	}
+	return r.info[name], r.failures[name]
+}
+
+// enginePlayer is a lazily started engine process that searches positions
+// on behalf of one side of a game. It restarts the engine after a failure.
+type enginePlayer struct {
+	config  EngineConfig
+	session *StockfishSession
+	logger  zerolog.Logger
+}
+
+func (e *enginePlayer) ensure() error {
+	if e.session != nil {
+		return nil
+	}
+	session, err := createEphemeralStockfishSession(e.config, e.logger)
+	if err != nil {
+		return err
+	}
+	e.session = session
+	return nil
+}
+
+func (e *enginePlayer) newGame(timeout time.Duration) error {
+	if err := e.ensure(); err != nil {
+		return err
+	}
+	if err := e.session.writeCommand(StockfishCmdUCINewGame); err != nil {
+		e.close()
+		return err
+	}
+	if _, err := e.session.executeCommand(context.Background(), StockfishCmdIsReady, timeout); err != nil {
+		e.close()
+		return err
+	}
+	return nil
+}
+
+func (e *enginePlayer) search(
+	ctx context.Context,
+	position string,
+	limits uci.Limits,
+	timeout time.Duration,
+) (*SearchInfo, error) {
+	lines, err := e.searchLines(ctx, position, limits, timeout)
+	if err != nil {
+		return nil, err
+	}
+	search := parseSearchOutput(lines)
+	if search.BestMove == "" {
+		return nil, fmt.Errorf("no bestmove in engine output")
+	}
+	return search, nil
+}
+
+// searchLines runs a search and returns the engine output unparsed, for
+// callers that follow the search as it deepens. Cancelling ctx stops the
+// search.
+func (e *enginePlayer) searchLines(
+	ctx context.Context,
+	position string,
+	limits uci.Limits,
+	timeout time.Duration,
+) ([]string, error) {
+	if err := e.ensure(); err != nil {
+		return nil, err
+	}
+	if err := e.session.syncVariant(position); err != nil {
+		e.close()
+		return nil, err
+	}
+	if err := e.session.writeCommand(position); err != nil {
+		e.close()
+		return nil, err
+	}
+	result, err := e.session.search(ctx, limits, timeout)
+	if err != nil {
+		// The engine may be stuck or gone; start afresh next time.
+		e.close()
+		return nil, err
+	}
+	return result.Lines, nil
+}
+
+func (e *enginePlayer) close() {
+	if e.session != nil {
+		e.session.close()
+		e.session = nil
+	}
+}
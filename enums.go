@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 type ServerMode string
 
 const (
@@ -7,6 +9,15 @@ const (
 	ServerModeStdio ServerMode = "stdio"
 )
 
+// Priority orders requests waiting for a session: interactive requests are
+// served before batch ones.
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive"
+	PriorityBatch       Priority = "batch"
+)
+
 type LogFormat string
 
 const (
@@ -22,18 +33,32 @@ const (
 )
 
 const (
-	StockfishCmdQuit      = "quit"
-	StockfishCmdUCI       = "uci"
-	StockfishCmdIsReady   = "isready"
-	StockfishCmdStop      = "stop"
-	StockfishCmdPosition  = "position"
-	StockfishCmdGo        = "go"
-	StockfishCmdSetOption = "setoption"
+	StockfishCmdQuit       = "quit"
+	StockfishCmdUCI        = "uci"
+	StockfishCmdIsReady    = "isready"
+	StockfishCmdStop       = "stop"
+	StockfishCmdPosition   = "position"
+	StockfishCmdGo         = "go"
+	StockfishCmdSetOption  = "setoption"
+	StockfishCmdUCINewGame = "ucinewgame"
 )
 
 const (
 	ComponentSessionManager = "session_manager"
+	ComponentEngineRegistry = "engine_registry"
+	ComponentMatchRunner    = "match_runner"
+	ComponentSuiteRunner    = "suite_runner"
+	ComponentGameManager    = "game_manager"
+	ComponentTablebase      = "tablebase"
+	ComponentOpeningBook    = "opening_book"
+	ComponentRenderer       = "renderer"
+	ComponentAnalyzer       = "analyzer"
 	ComponentHandler        = "handler"
+	ComponentTranscript     = "transcript"
+	ComponentAudit          = "audit"
+	ComponentAuth           = "auth"
+	ComponentRateLimiter    = "rate_limiter"
+	ComponentHealth         = "health"
 	ExecutorPersistent      = "persistent"
 	ExecutorEphemeral       = "ephemeral"
 )
@@ -41,3 +66,17 @@ const (
 const (
 	SessionIDStdioEphemeral = "stdio-ephemeral"
 )
+
+const (
+	DefaultEngineName = "stockfish"
+)
+
+// UCI options set by the server itself.
+const (
+	optionShowWDL  = "UCI_ShowWDL"
+	optionChess960 = "UCI_Chess960"
+)
+
+const (
+	defaultHandshakeTimeout = 10 * time.Second
+)
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EPDRecord is one line of an EPD file: a position plus its operations.
+// The bm, am, id and c0 opcodes are decoded; all operations are kept in Ops.
+type EPDRecord struct {
+	FEN        string              `json:"fen"`
+	ID         string              `json:"id,omitempty"`
+	Comment    string              `json:"comment,omitempty"`
+	BestMoves  []string            `json:"bm,omitempty"`
+	AvoidMoves []string            `json:"am,omitempty"`
+	Ops        map[string][]string `json:"ops,omitempty"`
+
+	position *Position
+	best     []Move
+	avoid    []Move
+}
+
+// parseEPD parses EPD text, one record per line. Blank lines and lines
+// starting with '#' are skipped.
+func parseEPD(text string) ([]EPDRecord, error) {
+	var records []EPDRecord
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record, err := parseEPDLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func parseEPDLine(line string) (EPDRecord, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return EPDRecord{}, fmt.Errorf("expected 4 position fields, got %d", len(fields))
+	}
+
+	// Skip the four position fields without losing the quoting of the
+	// operations that follow.
+	rest := line
+	for i := 0; i < 4; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		rest = rest[end:]
+	}
+
+	ops, err := parseEPDOperations(rest)
+	if err != nil {
+		return EPDRecord{}, err
+	}
+
+	halfmove, fullmove := "0", "1"
+	if v := ops["hmvc"]; len(v) == 1 {
+		halfmove = v[0]
+	}
+	if v := ops["fmvn"]; len(v) == 1 {
+		fullmove = v[0]
+	}
+	fen := strings.Join(append(fields[:4:4], halfmove, fullmove), " ")
+	pos, err := parseFEN(fen)
+	if err != nil {
+		return EPDRecord{}, err
+	}
+
+	record := EPDRecord{FEN: fen, Ops: ops, position: pos}
+	if v := ops["id"]; len(v) > 0 {
+		record.ID = v[0]
+	}
+	if v := ops["c0"]; len(v) > 0 {
+		record.Comment = v[0]
+	}
+	for opcode, dst := range map[string]*[]Move{"bm": &record.best, "am": &record.avoid} {
+		for _, s := range ops[opcode] {
+			m, err := pos.ParseMove(s)
+			if err != nil {
+				return EPDRecord{}, fmt.Errorf("%s %s: %w", opcode, s, err)
+			}
+			*dst = append(*dst, m)
+		}
+	}
+	record.BestMoves = ops["bm"]
+	record.AvoidMoves = ops["am"]
+	return record, nil
+}
+
+// parseEPDOperations splits "bm Nf3 Nc3; id \"x\";" into opcodes and
+// operands. Quoted operands may contain spaces and semicolons.
+func parseEPDOperations(s string) (map[string][]string, error) {
+	ops := map[string][]string{}
+	var (
+		tokens []string
+		token  strings.Builder
+		quoted bool
+		inTok  bool
+	)
+
+	endToken := func() {
+		if inTok {
+			tokens = append(tokens, token.String())
+			token.Reset()
+			inTok = false
+		}
+	}
+	endOperation := func() {
+		endToken()
+		if len(tokens) > 0 {
+			ops[tokens[0]] = append(ops[tokens[0]], tokens[1:]...)
+			tokens = nil
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quoted && c == '"':
+			quoted = false
+		case quoted:
+			token.WriteByte(c)
+		case c == '"':
+			quoted, inTok = true, true
+		case c == ';':
+			endOperation()
+		case c == ' ' || c == '\t' || c == '\r':
+			endToken()
+		default:
+			token.WriteByte(c)
+			inTok = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	endOperation()
+	return ops, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseEPD(t *testing.T) {
+	text := `# Bratko-Kopec, with comments
+1k1r4/pp1b1R2/3q2pp/4p3/2B5/4Q3/PPP2B2/2K5 b - - bm Qd1+; id "BK.01";
+
+r1bqk1nr/pppp1ppp/2n5/2b1p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - hmvc 4; fmvn 4; bm O-O d3; am Ng5; id "two best; one avoid"; c0 "Italian; castle or play d3";
+`
+	records, err := parseEPD(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("%d records", len(records))
+	}
+
+	bk := records[0]
+	if bk.ID != "BK.01" || !slices.Equal(bk.BestMoves, []string{"Qd1+"}) || bk.AvoidMoves != nil {
+		t.Errorf("first record = %+v", bk)
+	}
+	if bk.FEN != "1k1r4/pp1b1R2/3q2pp/4p3/2B5/4Q3/PPP2B2/2K5 b - - 0 1" {
+		t.Errorf("first FEN = %q", bk.FEN)
+	}
+
+	italian := records[1]
+	if italian.FEN != "r1bqk1nr/pppp1ppp/2n5/2b1p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4" {
+		t.Errorf("FEN with hmvc and fmvn = %q", italian.FEN)
+	}
+	if italian.ID != "two best; one avoid" {
+		t.Errorf("quoted id = %q", italian.ID)
+	}
+	if italian.Comment != "Italian; castle or play d3" {
+		t.Errorf("c0 = %q", italian.Comment)
+	}
+	if !slices.Equal(italian.BestMoves, []string{"O-O", "d3"}) || !slices.Equal(italian.AvoidMoves, []string{"Ng5"}) {
+		t.Errorf("bm %q, am %q", italian.BestMoves, italian.AvoidMoves)
+	}
+
+	pos := italian.position
+	for _, tt := range []struct {
+		move string
+		want bool
+	}{
+		{"e1g1", true},
+		{"d2d3", true},
+		{"f3g5", false},
+		{"c2c3", false},
+	} {
+		m, err := pos.ParseMove(tt.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := italian.accepts(m); got != tt.want {
+			t.Errorf("accepts %s = %v", tt.move, got)
+		}
+	}
+}
+
+func TestParseEPDErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"8/8/8/8 w", "expected 4 position fields"},
+		{`4k3/8/8/8/8/8/8/4K3 w - - id "open`, "unterminated string"},
+		{"4k3/8/8/8/8/8/8/4K3 w - - bm Qh5;", "bm Qh5"},
+		{"\n4k3/8/8/8/8/8/8/4K3 w - - am e1e3;", "line 2: am e1e3"},
+	}
+	for _, tt := range tests {
+		if _, err := parseEPD(tt.text); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseEPD(%q) = %v, want %q", tt.text, err, tt.want)
+		}
+	}
+}
@@ -1,44 +1,56 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
 type EphemeralSessionExecutor struct {
-	stockfishPath  string
+	engines        *EngineRegistry
 	commandTimeout time.Duration
 	logger         zerolog.Logger
 }
 
 func NewEphemeralSessionExecutor(
-	stockfishPath string,
+	engines *EngineRegistry,
 	commandTimeout time.Duration,
 	logger zerolog.Logger,
 ) *EphemeralSessionExecutor {
 	return &EphemeralSessionExecutor{
-		stockfishPath:  stockfishPath,
+		engines:        engines,
 		commandTimeout: commandTimeout,
 		logger:         logger.With().Str("executor", "ephemeral").Logger(),
 	}
 }
 
 func (e *EphemeralSessionExecutor) Execute(
+	ctx context.Context,
+	engineName string,
 	command string,
 	clientSessionID string,
+	priority Priority,
 	timeout time.Duration,
-) (string, []string, error) {
+) (string, []string, *QueueWait, error) {
 	ephemeralSessionID := "stdio-ephemeral"
 	e.logger.Debug().Str("command", command).Msg("Creating ephemeral session")
 
-	session, err := createEphemeralStockfishSession(e.stockfishPath, e.logger)
+	engine, err := e.engines.Get(engineName)
+	if err != nil {
+		return ephemeralSessionID, nil, nil, err
+	}
+
+	session, err := createEphemeralStockfishSession(engine, e.logger)
 	if err != nil {
 		e.logger.Error().Err(err).Msg("Failed to create ephemeral Stockfish session")
-		return ephemeralSessionID, nil, err
+		return ephemeralSessionID, nil, nil, err
 	}
 	defer session.close()
 
-	responses, err := session.executeCommand(command, e.commandTimeout)
-	return ephemeralSessionID, responses, err
+	if err := session.syncVariant(command); err != nil {
+		return ephemeralSessionID, nil, nil, err
+	}
+	responses, err := session.executeCommand(ctx, command, e.commandTimeout)
+	return ephemeralSessionID, responses, nil, err
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -23,27 +24,45 @@ func NewPersistentSessionExecutor(
 }
 
 func (e *PersistentSessionExecutor) Execute(
+	ctx context.Context,
+	engineName string,
 	command string,
 	clientSessionID string,
+	priority Priority,
 	timeout time.Duration,
-) (string, []string, error) {
-	session, err := e.sessionManager.getOrCreateSession(clientSessionID)
+) (string, []string, *QueueWait, error) {
+	session, wait, err := e.sessionManager.getOrCreateSession(ctx, clientSessionID, engineName, priority)
 	if err != nil {
 		e.logger.Error().
 			Err(err).
 			Str("client_session_id", clientSessionID).
+			Str("engine", engineName).
 			Msg("Failed to get or create session")
-		return clientSessionID, nil, err
+		return clientSessionID, nil, wait, err
+	}
+
+	if timeout <= 0 {
+		timeout = e.sessionManager.config.CommandTimeout
 	}
 
 	actualSessionID := session.ID
-	responses, err := session.executeCommand(command, timeout)
+	if err := session.syncVariant(command); err != nil {
+		return actualSessionID, nil, wait, err
+	}
+	responses, err := session.executeCommand(ctx, command, timeout)
 
-	if strings.TrimSpace(command) == "quit" {
+	switch {
+	case strings.TrimSpace(command) == "quit":
 		e.sessionManager.removeSession(actualSessionID)
 		e.logger.Info().
 			Str("session_id", actualSessionID).
 			Msg("Session quit and removed by persistent executor")
+	case session.exited():
+		// The engine crashed or was closed after ignoring "stop".
+		e.sessionManager.removeSession(actualSessionID)
+		e.logger.Warn().
+			Str("session_id", actualSessionID).
+			Msg("Engine exited, session removed by persistent executor")
 	}
-	return actualSessionID, responses, err
+	return actualSessionID, responses, wait, err
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ResultWhiteWins = "1-0"
+	ResultBlackWins = "0-1"
+	ResultDraw      = "1/2-1/2"
+	ResultOngoing   = "*"
+)
+
+const (
+	TerminationCheckmate            = "checkmate"
+	TerminationStalemate            = "stalemate"
+	TerminationRepetition           = "threefold repetition"
+	TerminationFiftyMoves           = "fifty-move rule"
+	TerminationInsufficientMaterial = "insufficient material"
+)
+
+// Game is a move history from a starting position, tracking what is needed
+// to detect the end of the game.
+type Game struct {
+	start *Position
+	pos   *Position
+	moves []Move
+	sans  []string
+	keys  []string
+}
+
+func newGame(start *Position) *Game {
+	return &Game{
+		start: start,
+		pos:   start,
+		keys:  []string{start.repetitionKey()},
+	}
+}
+
+// newGameFromMoves sets up a game from a FEN (or "startpos"/"") followed by
+// moves in UCI or SAN notation.
+func newGameFromMoves(fen string, moves []string) (*Game, error) {
+	start := startPosition()
+	if fen != "" && fen != "startpos" {
+		var err error
+		if start, err = parseFEN(fen); err != nil {
+			return nil, err
+		}
+	}
+
+	g := newGame(start)
+	for _, s := range moves {
+		m, err := g.pos.ParseMove(s)
+		if err != nil {
+			return nil, err
+		}
+		g.Play(m)
+	}
+	return g, nil
+}
+
+func (g *Game) Position() *Position {
+	return g.pos
+}
+
+func (g *Game) Start() *Position {
+	return g.start
+}
+
+func (g *Game) Moves() []Move {
+	return g.moves
+}
+
+func (g *Game) SANs() []string {
+	return g.sans
+}
+
+// Play appends a legal move to the game.
+func (g *Game) Play(m Move) {
+	g.sans = append(g.sans, g.pos.SAN(m))
+	g.pos = g.pos.Play(m)
+	g.moves = append(g.moves, m)
+	g.keys = append(g.keys, g.pos.repetitionKey())
+}
+
+// Undo takes back the last n moves by replaying the rest from the start.
+func (g *Game) Undo(n int) error {
+	if n <= 0 || n > len(g.moves) {
+		return fmt.Errorf("cannot take back %d move(s), %d played", n, len(g.moves))
+	}
+	moves := g.moves[:len(g.moves)-n]
+	*g = *newGame(g.start)
+	for _, m := range moves {
+		g.Play(m)
+	}
+	return nil
+}
+
+// UCIMoves returns the history in UCI notation.
+func (g *Game) UCIMoves() []string {
+	moves := make([]string, 0, len(g.moves))
+	pos := g.start
+	for _, m := range g.moves {
+		moves = append(moves, pos.UCI(m))
+		pos = pos.Play(m)
+	}
+	return moves
+}
+
+// PositionCommand builds the UCI "position" command for the current game.
+func (g *Game) PositionCommand() string {
+	var sb strings.Builder
+	sb.WriteString(StockfishCmdPosition)
+	if g.start.FEN() == startFEN {
+		sb.WriteString(" startpos")
+	} else {
+		sb.WriteString(" fen ")
+		sb.WriteString(g.start.FEN())
+	}
+	if len(g.moves) > 0 {
+		sb.WriteString(" moves ")
+		sb.WriteString(strings.Join(g.UCIMoves(), " "))
+	}
+	return sb.String()
+}
+
+// parsePositionCommand is the inverse of PositionCommand: it replays a UCI
+// "position startpos|fen <fen> [moves ...]" command.
+func parsePositionCommand(command string) (*Game, error) {
+	fields := strings.Fields(command)
+	if len(fields) < 2 || strings.ToLower(fields[0]) != StockfishCmdPosition {
+		return nil, fmt.Errorf("not a position command: %q", command)
+	}
+
+	var fen string
+	rest := fields[2:]
+	switch strings.ToLower(fields[1]) {
+	case "startpos":
+	case "fen":
+		end := len(rest)
+		for i, f := range rest {
+			if strings.ToLower(f) == "moves" {
+				end = i
+				break
+			}
+		}
+		fen, rest = strings.Join(rest[:end], " "), rest[end:]
+	default:
+		return nil, fmt.Errorf("not a position command: %q", command)
+	}
+
+	var moves []string
+	if len(rest) > 0 {
+		if strings.ToLower(rest[0]) != "moves" {
+			return nil, fmt.Errorf("unexpected %q in position command", rest[0])
+		}
+		moves = rest[1:]
+	}
+	return newGameFromMoves(fen, moves)
+}
+
+// Outcome reports the result of the game and why it ended, or
+// ResultOngoing if it has not.
+func (g *Game) Outcome() (string, string) {
+	pos := g.pos
+	if len(pos.LegalMoves()) == 0 {
+		if pos.InCheck() {
+			if pos.turn == White {
+				return ResultBlackWins, TerminationCheckmate
+			}
+			return ResultWhiteWins, TerminationCheckmate
+		}
+		return ResultDraw, TerminationStalemate
+	}
+	if pos.insufficientMaterial() {
+		return ResultDraw, TerminationInsufficientMaterial
+	}
+	if pos.halfmove >= 100 {
+		return ResultDraw, TerminationFiftyMoves
+	}
+	if g.repetitions() >= 3 {
+		return ResultDraw, TerminationRepetition
+	}
+	return ResultOngoing, ""
+}
+
+func (g *Game) repetitions() int {
+	current := g.keys[len(g.keys)-1]
+	count := 0
+	for _, key := range g.keys {
+		if key == current {
+			count++
+		}
+	}
+	return count
+}
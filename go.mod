@@ -9,6 +9,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/mark3labs/mcp-go v0.29.0
 	github.com/rs/zerolog v1.34.0
+	golang.org/x/sys v0.33.0
 )
 
 require (
@@ -16,5 +17,4 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/spf13/cast v1.8.0 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	golang.org/x/sys v0.33.0 // indirect
 )
@@ -12,25 +12,57 @@ import (
 )
 
 type commandExecutor interface {
-	Execute(command string, clientSessionID string, timeout time.Duration) (string, []string, error)
+	Execute(
+		ctx context.Context,
+		engine string,
+		command string,
+		clientSessionID string,
+		priority Priority,
+		timeout time.Duration,
+	) (string, []string, *QueueWait, error)
 }
 
 type StockfishHandler struct {
 	executor commandExecutor
+	engines  *EngineRegistry
+	book     *OpeningBook
+	useBook  bool
 	logger   zerolog.Logger
 }
 
 type CommandResult struct {
-	Status    string   `json:"status"`
-	SessionID string   `json:"session_id"`
-	Command   string   `json:"command"`
-	Response  []string `json:"response"`
-	Error     string   `json:"error,omitempty"`
+	Status    string      `json:"status"`
+	SessionID string      `json:"session_id"`
+	Engine    string      `json:"engine"`
+	Command   string      `json:"command"`
+	Response  []string    `json:"response"`
+	Analysis  *SearchInfo `json:"analysis,omitempty"`
+	Book      []BookMove  `json:"book,omitempty"`
+	Board     string      `json:"board,omitempty"`
+	// Queue is set when the request waited for a free session.
+	Queue *QueueWait `json:"queue,omitempty"`
+	Error string     `json:"error,omitempty"`
 }
 
-func newStockfishHandler(executor commandExecutor, logger zerolog.Logger) *StockfishHandler {
+type EngineListResult struct {
+	Status        string        `json:"status"`
+	DefaultEngine string        `json:"default_engine"`
+	Engines       []*EngineInfo `json:"engines"`
+	Errors        []string      `json:"errors,omitempty"`
+}
+
+func newStockfishHandler(
+	executor commandExecutor,
+	engines *EngineRegistry,
+	book *OpeningBook,
+	useBook bool,
+	logger zerolog.Logger,
+) *StockfishHandler {
 	return &StockfishHandler{
 		executor: executor,
+		engines:  engines,
+		book:     book,
+		useBook:  useBook,
 		logger:   logger.With().Str("component", "handler").Logger(),
 	}
 }
@@ -46,10 +78,12 @@ func (h *StockfishHandler) handle(
 	}
 
 	sessionID := request.GetString("session_id", "")
+	engineName := request.GetString("engine", "")
 
 	h.logger.Info().
 		Str("command", command).
 		Str("client_session_id", sessionID).
+		Str("engine", engineName).
 		Msg("Received Stockfish command request")
 
 	if err := h.validateCommand(command); err != nil {
@@ -60,12 +94,44 @@ func (h *StockfishHandler) handle(
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid command: %s", err.Error())), nil
 	}
 
-	actualSessionID, responses, execErr := h.executor.Execute(command, sessionID, 0)
+	useBook := request.GetBool("use_book", h.useBook)
+	render := request.GetBool("render", false)
+	if useBook && h.book == nil {
+		return mcp.NewToolResultError("No opening book configured (set MCP_STOCKFISH_BOOK_PATH)"), nil
+	}
+
+	if engineName == "" {
+		engineName = h.engines.DefaultName()
+	}
+
+	if err := h.validateSetOption(engineName, command); err != nil {
+		h.logger.Warn().
+			Err(err).
+			Str("command", command).
+			Str("engine", engineName).
+			Msg("Invalid option")
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid command: %s", err.Error())), nil
+	}
+
+	priority := Priority(request.GetString("priority", string(PriorityInteractive)))
+	if priority != PriorityInteractive && priority != PriorityBatch {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown priority %q: use interactive or batch", priority)), nil
+	}
+
+	actualSessionID, responses, wait, execErr := h.executor.Execute(ctx, engineName, command, sessionID, priority, 0)
 
 	result := CommandResult{
 		SessionID: actualSessionID,
+		Engine:    engineName,
 		Command:   command,
 		Response:  responses,
+		Queue:     wait,
+	}
+
+	// The book and the diagram do not depend on the engine, so they are
+	// reported even when the engine fails to acknowledge the position.
+	if isPositionCommand(command) && (useBook || render) {
+		h.describePosition(&result, command, useBook, render)
 	}
 
 	if execErr != nil {
@@ -78,6 +144,10 @@ func (h *StockfishHandler) handle(
 			Msg("Command execution failed")
 	} else {
 		result.Status = "success"
+		if isGoCommand(command) {
+			result.Analysis = parseSearchOutput(responses)
+			result.Analysis.fillWDL(nil)
+		}
 		h.logger.Debug().
 			Str("command", command).
 			Str("actual_session_id", actualSessionID).
@@ -85,15 +155,92 @@ func (h *StockfishHandler) handle(
 			Msg("Command executed successfully")
 	}
 
+	return h.jsonResult(result)
+}
+
+func (h *StockfishHandler) handleListEngines(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	result := EngineListResult{
+		Status:        "success",
+		DefaultEngine: h.engines.DefaultName(),
+		Engines:       []*EngineInfo{},
+	}
+
+	for _, name := range h.engines.Names() {
+		info, err := h.engines.Describe(name)
+		if err != nil {
+			h.logger.Warn().Err(err).Str("engine", name).Msg("Failed to describe engine")
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.Engines = append(result.Engines, info)
+	}
+
+	if len(result.Errors) > 0 {
+		result.Status = "partial"
+	}
+
+	return h.jsonResult(result)
+}
+
+// describePosition adds the book moves and the board diagram of the
+// position set by a "position" command. Failures are logged only.
+func (h *StockfishHandler) describePosition(
+	result *CommandResult,
+	command string,
+	useBook, render bool,
+) {
+	game, err := parsePositionCommand(command)
+	if err != nil {
+		h.logger.Warn().Err(err).Str("command", command).Msg("Cannot parse position command")
+		return
+	}
+	pos := game.Position()
+
+	if useBook {
+		moves, err := h.book.Moves(pos)
+		if err != nil {
+			h.logger.Warn().Err(err).Msg("Book lookup failed")
+		}
+		result.Book = moves
+	}
+
+	if render {
+		opts := RenderOptions{Coordinates: true}
+		if played := game.Moves(); len(played) > 0 {
+			opts.LastMove = &played[len(played)-1]
+			opts.LastMoveSAN = game.SANs()[len(played)-1]
+		}
+		result.Board = renderText(pos, opts, true)
+	}
+}
+
+func (h *StockfishHandler) jsonResult(result any) (*mcp.CallToolResult, error) {
+	return jsonToolResult(result, h.logger)
+}
+
+func jsonToolResult(result any, logger zerolog.Logger) (*mcp.CallToolResult, error) {
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Failed to marshal response")
+		logger.Error().Err(err).Msg("Failed to marshal response")
 		return mcp.NewToolResultError("Failed to marshal result to JSON"), nil
 	}
 
 	return mcp.NewToolResultText(string(jsonBytes)), nil
 }
 
+func isGoCommand(command string) bool {
+	fields := strings.Fields(command)
+	return len(fields) > 0 && strings.ToLower(fields[0]) == StockfishCmdGo
+}
+
+func isPositionCommand(command string) bool {
+	fields := strings.Fields(command)
+	return len(fields) > 0 && strings.ToLower(fields[0]) == StockfishCmdPosition
+}
+
 func (h *StockfishHandler) validateCommand(command string) error {
 	cmd := strings.TrimSpace(strings.ToLower(command))
 
@@ -123,3 +270,34 @@ func (h *StockfishHandler) validateCommand(command string) error {
 
 	return fmt.Errorf("unsupported command: %s", command)
 }
+
+// validateSetOption checks a "setoption" command against the options the
+// engine announced in its "uci" reply to the startup self-check. It never
+// launches an engine: until that reply is cached, the engine is left to
+// judge the option itself.
+func (h *StockfishHandler) validateSetOption(engineName, command string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || strings.ToLower(fields[0]) != StockfishCmdSetOption {
+		return nil
+	}
+	name, value, ok := parseSetOption(command)
+	if !ok {
+		return fmt.Errorf(`malformed setoption, use "setoption name <name> [value <value>]"`)
+	}
+
+	info, err := h.engines.Status(engineName)
+	if info == nil {
+		h.logger.Debug().Err(err).Str("engine", engineName).Msg("Option not validated, engine not identified")
+		return nil
+	}
+
+	opt, ok := info.Option(name)
+	if !ok {
+		names := make([]string, len(info.Options))
+		for i, opt := range info.Options {
+			names[i] = opt.Name
+		}
+		return fmt.Errorf("engine %q has no option %q (options: %s)", engineName, name, strings.Join(names, ", "))
+	}
+	return opt.Validate(value)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func newTestHandler(t *testing.T, config StockfishConfig, persistent bool) *StockfishHandler {
+	t.Helper()
+	engines := newEngineRegistry(config, zerolog.Nop())
+	var executor commandExecutor = NewEphemeralSessionExecutor(engines, config.CommandTimeout, zerolog.Nop())
+	if persistent {
+		executor = NewPersistentSessionExecutor(newTestSessionManager(t, config), zerolog.Nop())
+	}
+	return newStockfishHandler(executor, engines, nil, false, zerolog.Nop())
+}
+
+func TestHandlerGo(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{BestMove: "g1f3", Depth: 4})
+	handler := newTestHandler(t, testConfig(engine), false)
+
+	text, isError := callTool(t, handler.handle, map[string]any{"command": "go depth 4"})
+	if isError {
+		t.Fatalf("tool error: %s", text)
+	}
+	result := decode[CommandResult](t, text)
+	if result.Status != "success" || result.Engine != testEngine {
+		t.Fatalf("result = %+v", result)
+	}
+	if got := result.Response[len(result.Response)-1]; got != "bestmove g1f3" {
+		t.Errorf("last response line = %q", got)
+	}
+	if result.Analysis == nil || result.Analysis.BestMove != "g1f3" {
+		t.Fatalf("analysis = %+v", result.Analysis)
+	}
+	best := result.Analysis.Best()
+	if best == nil || best.Depth != 4 || *best.ScoreCP != 40 || best.WDL == nil {
+		t.Errorf("best line = %+v", best)
+	}
+}
+
+func TestHandlerCommands(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	handler := newTestHandler(t, testConfig(engine), false)
+
+	tests := []struct {
+		command  string
+		lastLine string
+	}{
+		{"uci", "uciok"},
+		{"isready", "readyok"},
+		{"position startpos moves e2e4", ""},
+		{"setoption name Hash value 32", ""},
+		{"stop", ""},
+	}
+	for _, tt := range tests {
+		started := time.Now()
+		result := decode[CommandResult](t, mustCall(t, handler, tt.command))
+		if result.Status != "success" {
+			t.Errorf("%s: %+v", tt.command, result)
+			continue
+		}
+		var last string
+		if n := len(result.Response); n > 0 {
+			last = result.Response[n-1]
+		}
+		if last != tt.lastLine {
+			t.Errorf("%s: last line %q, want %q", tt.command, last, tt.lastLine)
+		}
+		// Commands without a reply must not wait for the timeout.
+		if elapsed := time.Since(started); elapsed > time.Second {
+			t.Errorf("%s took %v", tt.command, elapsed)
+		}
+	}
+}
+
+func mustCall(t *testing.T, handler *StockfishHandler, command string) string {
+	t.Helper()
+	text, isError := callTool(t, handler.handle, map[string]any{"command": command})
+	if isError {
+		t.Fatalf("%s: tool error: %s", command, text)
+	}
+	return text
+}
+
+func TestHandlerRejectsUnsupportedCommands(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	handler := newTestHandler(t, testConfig(engine), false)
+
+	for _, command := range []string{"d", "eval", "quitnow", "position"} {
+		text, isError := callTool(t, handler.handle, map[string]any{"command": command})
+		if !isError || !strings.Contains(text, "unsupported command") {
+			t.Errorf("%s: %v %s", command, isError, text)
+		}
+	}
+	if got := commandLog(t, engine); got[0] != "" {
+		t.Errorf("engine received %q", got)
+	}
+}
+
+func TestHandlerEngineFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		script ucitest.Script
+		want   string
+	}{
+		{"crash", ucitest.Script{CrashOn: "go"}, "exit status 3"},
+		{"missing bestmove", ucitest.Script{NoBestMove: true, SearchTime: time.Minute}, "command timeout"},
+		{"slow search", ucitest.Script{SearchTime: time.Minute}, "command timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(fakeEngine(t, tt.script))
+			config.CommandTimeout = 200 * time.Millisecond
+			handler := newTestHandler(t, config, false)
+
+			result := decode[CommandResult](t, mustCall(t, handler, "go infinite"))
+			if result.Status != "error" || !strings.Contains(result.Error, tt.want) {
+				t.Errorf("result = %+v, want error containing %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerToleratesNoisyEngines(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{Garbage: true, StderrLines: 5000})
+	handler := newTestHandler(t, testConfig(engine), true)
+
+	result := decode[CommandResult](t, mustCall(t, handler, "uci"))
+	if result.Status != "success" || result.Response[len(result.Response)-1] != "uciok" {
+		t.Fatalf("uci: %+v", result)
+	}
+	text, _ := callTool(t, handler.handle, map[string]any{
+		"command":    "go depth 3",
+		"session_id": result.SessionID,
+	})
+	result = decode[CommandResult](t, text)
+	if result.Status != "success" || result.Analysis.BestMove != "e2e4" {
+		t.Errorf("go: %+v", result)
+	}
+}
+
+func TestHandlerListEngines(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{Name: "Fake 17"})
+	handler := newTestHandler(t, testConfig(engine), false)
+
+	text, _ := callTool(t, handler.handleListEngines, nil)
+	result := decode[EngineListResult](t, text)
+	if result.Status != "success" || len(result.Engines) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	info := result.Engines[0]
+	if info.Name != testEngine || info.IDName != "Fake 17" || len(info.Options) != len(ucitest.Options) {
+		t.Errorf("engine = %+v", info)
+	}
+}
+
+func TestHandlerValidatesOptions(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	handler := newTestHandler(t, testConfig(engine), true)
+
+	// Before the self-check has identified the engine, options are passed
+	// through unchecked instead of launching it.
+	if _, isError := callTool(t, handler.handle, map[string]any{"command": "setoption name Contempt value 10"}); isError {
+		t.Error("setoption on an unidentified engine was rejected")
+	}
+	if info, _ := handler.engines.Status(""); info != nil {
+		t.Error("validating an option identified the engine")
+	}
+	if _, err := handler.engines.Describe(""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command string
+		wantErr string
+	}{
+		{"setoption name hash value 64", ""},
+		{"setoption name Clear Hash", ""},
+		{"setoption name Hash value 0", `option "Hash" must be between 1 and 33554432, not 0`},
+		{"setoption name Threads value many", "takes an integer"},
+		{"setoption name UCI_ShowWDL value maybe", "true or false"},
+		{"setoption name Clear Hash value now", "takes no value"},
+		{"setoption name Contempt value 10", `engine "fake" has no option "Contempt" (options: Threads, Hash`},
+		{"setoption value 10", "malformed setoption"},
+	}
+	for _, tt := range tests {
+		text, isError := callTool(t, handler.handle, map[string]any{"command": tt.command})
+		if tt.wantErr == "" && isError || tt.wantErr != "" && (!isError || !strings.Contains(text, tt.wantErr)) {
+			t.Errorf("%s: %v %s", tt.command, isError, text)
+		}
+	}
+
+	// Only the unchecked and the valid options reach the engine, which logs
+	// them as it reads them.
+	waitFor(t, func() bool {
+		sent := 0
+		for _, line := range commandLog(t, engine) {
+			if strings.HasPrefix(line, "setoption") {
+				sent++
+			}
+		}
+		return sent == 3
+	})
+
+	description := newChessEngineTool(handler.engines).Description
+	for _, want := range []string{
+		"ENGINE OPTIONS of fake",
+		"┌─ Threads      → spin 1 to 1024, default 1",
+		"└─ Clear Hash   → button",
+	} {
+		if !strings.Contains(description, want) {
+			t.Errorf("description lacks %q:\n%s", want, description)
+		}
+	}
+	if strings.Contains(description, "Slow Mover") {
+		t.Error("description lists options the engine does not have")
+	}
+}
+
+func TestChessEngineToolDeclaresSessionID(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	schema := newChessEngineTool(newEngineRegistry(config, zerolog.Nop())).InputSchema
+	if _, ok := schema.Properties["session_id"]; !ok {
+		t.Errorf("properties = %v", schema.Properties)
+	}
+	if slices.Contains(schema.Required, "session_id") {
+		t.Error("session_id is required")
+	}
+}
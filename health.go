@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+)
+
+// healthCacheTTL lets probes arriving together share one round of checks
+// instead of each launching the engines.
+const healthCacheTTL = 2 * time.Second
+
+// Health statuses. A failing check of a non-default engine only degrades
+// the server; the default engine and the session pool must pass for it to
+// be ready.
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+)
+
+// HealthCheck is the outcome of one readiness check.
+type HealthCheck struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Required   bool   `json:"required"`
+	DurationMS int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// HealthReport is returned by /readyz and the health tool.
+type HealthReport struct {
+	Status    string        `json:"status"`
+	Ready     bool          `json:"ready"`
+	Version   string        `json:"version"`
+	Uptime    string        `json:"uptime"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Checks    []HealthCheck `json:"checks"`
+	Pool      *PoolStatus   `json:"pool,omitempty"`
+}
+
+// PoolStatus describes the persistent session pool.
+type PoolStatus struct {
+	Active int `json:"active"`
+	Max    int `json:"max"`
+	// Engines counts every engine process under the Max cap: the sessions
+	// plus those of running matches, batches and test suites.
+	Engines int `json:"engines"`
+	// Evictable sessions have been idle long enough to make room.
+	Evictable int `json:"evictable"`
+	// HasCapacity tells whether a new session could be created now or
+	// queued for one.
+	HasCapacity bool         `json:"has_capacity"`
+	Restorable  int          `json:"restorable"`
+	Queue       QueueMetrics `json:"queue"`
+}
+
+// SessionInfo is a persistent session as listed by /debug/sessions.
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	Engine    string    `json:"engine"`
+	Owner     string    `json:"owner,omitempty"`
+	PID       int       `json:"pid,omitempty"`
+	Busy      bool      `json:"busy"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+	IdleMS    int64     `json:"idle_ms"`
+	FEN       string    `json:"fen,omitempty"`
+	Moves     int       `json:"moves"`
+	Options   int       `json:"options"`
+}
+
+// HealthChecker runs the liveness and readiness checks behind the HTTP
+// probes and the health tool.
+type HealthChecker struct {
+	engines  *EngineRegistry
+	sessions *SessionManager // nil with ephemeral sessions
+	timeout  time.Duration
+	version  string
+	started  time.Time
+	logger   zerolog.Logger
+
+	mu      sync.Mutex
+	last    *HealthReport
+	checked time.Time
+}
+
+func newHealthChecker(
+	engines *EngineRegistry,
+	sessions *SessionManager,
+	timeout time.Duration,
+	version string,
+	logger zerolog.Logger,
+) *HealthChecker {
+	return &HealthChecker{
+		engines:  engines,
+		sessions: sessions,
+		timeout:  timeout,
+		version:  version,
+		started:  time.Now(),
+		logger:   logger.With().Str("component", ComponentHealth).Logger(),
+	}
+}
+
+// Check launches every configured engine, which must answer "uciok" and
+// "readyok" within the timeout, and checks the session pool has capacity.
+func (h *HealthChecker) Check(ctx context.Context) HealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.last != nil && time.Since(h.checked) < healthCacheTTL {
+		return *h.last
+	}
+
+	report := HealthReport{
+		Status:    HealthOK,
+		Ready:     true,
+		Version:   h.version,
+		Uptime:    time.Since(h.started).Round(time.Second).String(),
+		CheckedAt: time.Now().UTC(),
+	}
+
+	names := h.engines.Names()
+	checks := make([]HealthCheck, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checks[i] = h.checkEngine(ctx, name)
+		}()
+	}
+	wg.Wait()
+	report.Checks = checks
+
+	if h.sessions != nil {
+		pool := h.sessions.PoolStatus()
+		report.Pool = &pool
+		check := HealthCheck{
+			Name:     "session_pool",
+			OK:       pool.HasCapacity,
+			Required: true,
+			Detail:   fmt.Sprintf("%d/%d sessions, %d queued", pool.Active, pool.Max, pool.Queue.Length),
+		}
+		if !pool.HasCapacity {
+			check.Error = "no free session slot and the queue is full"
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	for _, check := range report.Checks {
+		switch {
+		case check.OK:
+		case check.Required:
+			report.Status, report.Ready = HealthUnavailable, false
+		case report.Status == HealthOK:
+			report.Status = HealthDegraded
+		}
+	}
+
+	if h.last == nil || h.last.Status != report.Status {
+		event := h.logger.Info()
+		if !report.Ready {
+			event = h.logger.Warn()
+		}
+		event.Str("status", report.Status).Msg("Health status changed")
+	}
+	h.last, h.checked = &report, time.Now()
+	return report
+}
+
+// checkEngine starts a fresh process of the engine and performs the
+// handshake. Configured options are left out: the check is about the
+// binary answering, not about option values.
+func (h *HealthChecker) checkEngine(ctx context.Context, name string) HealthCheck {
+	check := HealthCheck{
+		Name:     "engine:" + name,
+		Required: name == h.engines.DefaultName(),
+	}
+	started := time.Now()
+	defer func() { check.DurationMS = time.Since(started).Milliseconds() }()
+
+	engine, err := h.engines.Get(name)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	engine.Options, engine.ShowWDL = nil, false
+
+	session, err := startEngineSession("health-"+name, engine, h.logger)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	defer session.close()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	id, err := session.client.Handshake(ctx)
+	if err != nil {
+		check.Error = "no uciok: " + commandError(err, h.timeout).Error()
+		return check
+	}
+	if err := session.client.IsReady(ctx); err != nil {
+		check.Error = "no readyok: " + commandError(err, h.timeout).Error()
+		return check
+	}
+	check.OK = true
+	check.Detail = id.Name
+	return check
+}
+
+// handleHealthz answers as long as the process serves requests.
+func (h *HealthChecker) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": HealthOK,
+		"uptime": time.Since(h.started).Round(time.Second).String(),
+	})
+}
+
+func (h *HealthChecker) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.Check(r.Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// handleDebugSessions lists the persistent sessions of the caller, or all
+// of them for callers with every scope.
+func (h *HealthChecker) handleDebugSessions(w http.ResponseWriter, r *http.Request) {
+	reply := struct {
+		PersistentSessions bool          `json:"persistent_sessions"`
+		Sessions           []SessionInfo `json:"sessions"`
+		Pool               *PoolStatus   `json:"pool,omitempty"`
+	}{Sessions: []SessionInfo{}}
+
+	if h.sessions != nil {
+		principal := principalFromContext(r.Context())
+		reply.PersistentSessions = true
+		for _, info := range h.sessions.Sessions() {
+			if ownedBy(info.Owner, principal) || principal.HasScope(ScopeAll) {
+				reply.Sessions = append(reply.Sessions, info)
+			}
+		}
+		pool := h.sessions.PoolStatus()
+		reply.Pool = &pool
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+func (h *HealthChecker) handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	report := h.Check(ctx)
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode health report: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// PoolStatus reports the occupancy of the session pool.
+func (sm *SessionManager) PoolStatus() PoolStatus {
+	queue := sm.QueueMetrics()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	status := PoolStatus{
+		Active:     len(sm.sessions),
+		Max:        sm.config.MaxSessions,
+		Engines:    sm.engines.slots.inUse(),
+		Restorable: len(sm.restorable),
+		Queue:      queue,
+	}
+	if sm.config.EvictIdleAfter > 0 {
+		for _, session := range sm.sessions {
+			if !session.mu.TryLock() {
+				continue
+			}
+			idle := time.Since(session.lastUsed)
+			session.mu.Unlock()
+			if idle >= sm.config.EvictIdleAfter {
+				status.Evictable++
+			}
+		}
+	}
+	queueing := sm.config.QueueMaxLength > 0 && sm.config.QueueMaxWait > 0
+	status.HasCapacity = !sm.closed && (status.Engines < status.Max ||
+		status.Evictable > 0 ||
+		queueing && len(sm.waiters) < sm.config.QueueMaxLength)
+	return status
+}
+
+// Sessions lists the live sessions, oldest first.
+func (sm *SessionManager) Sessions() []SessionInfo {
+	sm.mu.RLock()
+	sessions := make([]*StockfishSession, 0, len(sm.sessions))
+	for _, session := range sm.sessions {
+		sessions = append(sessions, session)
+	}
+	sm.mu.RUnlock()
+
+	now := time.Now()
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		state := session.State()
+		info := SessionInfo{
+			ID:        session.ID,
+			Engine:    session.Engine,
+			Owner:     session.Owner,
+			Busy:      true,
+			CreatedAt: state.CreatedAt,
+			LastUsed:  state.LastUsed,
+			FEN:       state.FEN,
+			Moves:     len(state.Moves),
+			Options:   len(state.Options),
+		}
+		if process := session.client.Process(); process != nil {
+			info.PID = process.Pid
+		}
+		if session.mu.TryLock() {
+			info.Busy = false
+			info.LastUsed = session.lastUsed
+			info.IdleMS = now.Sub(session.lastUsed).Milliseconds()
+			session.mu.Unlock()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].CreatedAt.Before(infos[j].CreatedAt) })
+	return infos
+}
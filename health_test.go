@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestHealthCheck(t *testing.T) {
+	good := fakeEngine(t, ucitest.Script{Name: "FakeFish 1.0"})
+	broken := fakeEngine(t, ucitest.Script{CrashOn: "uci"})
+	broken.Name = "broken"
+
+	config := testConfig(good)
+	config.Engines[broken.Name] = broken
+	check := func(config StockfishConfig, sm *SessionManager) HealthReport {
+		return newHealthChecker(newEngineRegistry(config, zerolog.Nop()), sm, time.Second, "test", zerolog.Nop()).
+			Check(context.Background())
+	}
+
+	report := check(config, nil)
+	if report.Status != HealthDegraded || !report.Ready || len(report.Checks) != 2 {
+		t.Fatalf("broken secondary engine: %+v", report)
+	}
+	for _, c := range report.Checks {
+		switch c.Name {
+		case "engine:" + testEngine:
+			if !c.OK || c.Detail != "FakeFish 1.0" {
+				t.Errorf("good engine: %+v", c)
+			}
+		case "engine:broken":
+			if c.OK || !strings.Contains(c.Error, "uciok") {
+				t.Errorf("broken engine: %+v", c)
+			}
+		}
+	}
+
+	config.DefaultEngine = broken.Name
+	if report := check(config, nil); report.Status != HealthUnavailable || report.Ready {
+		t.Errorf("broken default engine: %+v", report)
+	}
+
+	missing := testConfig(good)
+	missing.Engines[testEngine] = EngineConfig{Name: testEngine, Path: "/nonexistent/stockfish", MaxSessions: 1}
+	if report := check(missing, nil); report.Ready || !strings.Contains(report.Checks[0].Error, "failed to start") {
+		t.Errorf("missing binary: %+v", report)
+	}
+
+	full := testConfig(good)
+	full.MaxSessions, full.QueueMaxLength = 1, 0
+	sm := newTestSessionManager(t, full)
+	if report := check(full, sm); !report.Ready || report.Pool == nil || !report.Pool.HasCapacity {
+		t.Errorf("empty pool: %+v", report)
+	}
+	if _, _, err := sm.getOrCreateSession(context.Background(), "", testEngine, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	if report := check(full, sm); report.Ready || report.Pool.Active != 1 {
+		t.Errorf("full pool: %+v", report)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	sm := newTestSessionManager(t, config)
+	health := newHealthChecker(sm.engines, sm, time.Second, "test", zerolog.Nop())
+	auth := newTestAuthenticator(t, []APIKey{
+		{Name: "alice", Key: "alice-key", Scopes: []string{ScopeUCI}},
+		{Name: "ops", Key: "ops-key", Scopes: []string{ScopeAll}},
+	})
+	handler := newHTTPHandler(http.NotFoundHandler(), auth, health)
+
+	for _, owner := range []string{"alice", "bob"} {
+		ctx := withPrincipal(context.Background(), &Principal{Name: owner})
+		if _, _, err := sm.getOrCreateSession(ctx, "", testEngine, PriorityInteractive); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(path, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := get("/healthz", ""); rec.Code != http.StatusOK {
+		t.Errorf("/healthz: %d", rec.Code)
+	}
+	rec := get("/readyz", "")
+	if report := decode[HealthReport](t, rec.Body.String()); rec.Code != http.StatusOK || !report.Ready {
+		t.Errorf("/readyz: %d %s", rec.Code, rec.Body)
+	}
+	if rec := get("/debug/sessions", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("/debug/sessions without credentials: %d", rec.Code)
+	}
+
+	type debugReply struct {
+		Sessions []SessionInfo `json:"sessions"`
+		Pool     PoolStatus    `json:"pool"`
+	}
+	reply := decode[debugReply](t, get("/debug/sessions", "alice-key").Body.String())
+	if len(reply.Sessions) != 1 || reply.Sessions[0].Owner != "alice" || reply.Sessions[0].Busy {
+		t.Errorf("alice's sessions: %+v", reply.Sessions)
+	}
+	reply = decode[debugReply](t, get("/debug/sessions", "ops-key").Body.String())
+	if len(reply.Sessions) != 2 || reply.Pool.Active != 2 {
+		t.Errorf("all sessions: %+v", reply)
+	}
+}
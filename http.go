@@ -0,0 +1,24 @@
+package main
+
+import "net/http"
+
+// newHTTPHandler puts the server's HTTP concerns in front of the MCP
+// transport: the health probes, which need no credentials, and the
+// diagnostics and transport, which must carry valid ones when auth is set.
+func newHTTPHandler(transport http.Handler, auth *Authenticator, health *HealthChecker) http.Handler {
+	protect := func(h http.Handler) http.Handler {
+		if auth == nil {
+			return h
+		}
+		return auth.Middleware(h)
+	}
+
+	mux := http.NewServeMux()
+	if health != nil {
+		mux.HandleFunc("GET /healthz", health.handleHealthz)
+		mux.HandleFunc("GET /readyz", health.handleReadyz)
+		mux.Handle("GET /debug/sessions", protect(http.HandlerFunc(health.handleDebugSessions)))
+	}
+	mux.Handle("/", protect(transport))
+	return mux
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+// startHTTPServer serves s on a free port until the test ends and returns
+// its URL and the error serveHTTP returns once stop is called.
+func startHTTPServer(
+	t *testing.T,
+	s *server.MCPServer,
+	auth *Authenticator,
+	health *HealthChecker,
+) (url string, stop func() error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveHTTP(ctx, listener, s, auth, health, zerolog.Nop())
+	}()
+	stop = func() error {
+		cancel()
+		return <-done
+	}
+	t.Cleanup(func() { cancel() })
+	return "http://" + listener.Addr().String(), stop
+}
+
+// connectMCP opens an initialized SSE client session with the given
+// headers.
+func connectMCP(t *testing.T, url string, headers map[string]string) *client.Client {
+	t.Helper()
+	mcpClient, err := client.NewSSEMCPClient(url+"/sse", client.WithHeaders(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mcpClient.Close() })
+	if err := mcpClient.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mcpClient.Initialize(context.Background(), mcp.InitializeRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	return mcpClient
+}
+
+func callRemoteTool(t *testing.T, mcpClient *client.Client, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	result, err := mcpClient.CallTool(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toolResultText(result), result.IsError
+}
+
+func TestServeHTTP(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	health := newHealthChecker(newEngineRegistry(config, zerolog.Nop()), nil, time.Second, "test", zerolog.Nop())
+	auth := newTestAuthenticator(t, []APIKey{{Name: "ci", Key: "plain-secret"}})
+
+	s := server.NewMCPServer("test", "test", server.WithToolHandlerMiddleware(auth.toolMiddleware))
+	s.AddTool(mcp.NewTool("whoami"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(clientIdentity(ctx)), nil
+	})
+	url, stop := startHTTPServer(t, s, auth, health)
+
+	response, err := http.Get(url + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("/healthz: %d", response.StatusCode)
+	}
+
+	anonymous, err := client.NewSSEMCPClient(url + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := anonymous.Start(context.Background()); err == nil {
+		t.Error("SSE stream opened without credentials")
+	}
+	anonymous.Close()
+
+	// Tool calls see the caller authenticated on the HTTP request.
+	mcpClient := connectMCP(t, url, map[string]string{"X-API-Key": "plain-secret"})
+	if text, _ := callRemoteTool(t, mcpClient, "whoami", nil); text != "ci" {
+		t.Errorf("whoami = %q", text)
+	}
+
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("serveHTTP after cancellation: %v", err)
+	}
+}
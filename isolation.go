@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+// ResourceLimits confine each process of an engine so that a misbehaving
+// engine or a huge Hash cannot starve the host. Apart from SessionDir they
+// are only supported on Linux.
+type ResourceLimits struct {
+	// CPUAffinity pins the process to cores, e.g. "0-3,6".
+	CPUAffinity string
+	// Nice is the scheduling niceness, from -20 (favored) to 19.
+	Nice int
+	// MemoryBytes caps memory: memory.max of the process's cgroup when
+	// CgroupParent is set, its address space (RLIMIT_AS) otherwise.
+	MemoryBytes int64
+	// CgroupParent is a cgroup v2 directory delegated to the server. Each
+	// process runs in its own child group.
+	CgroupParent string
+	// MaxOpenFiles is the RLIMIT_NOFILE of the process.
+	MaxOpenFiles uint64
+	// SessionDir gives each process a working directory of its own below
+	// it, removed when the process ends.
+	SessionDir string
+}
+
+// osLimits reports whether limits needing OS support are set.
+func (l ResourceLimits) osLimits() bool {
+	return l.CPUAffinity != "" || l.Nice != 0 || l.MemoryBytes > 0 ||
+		l.CgroupParent != "" || l.MaxOpenFiles > 0
+}
+
+func (l ResourceLimits) validate() error {
+	if _, err := parseCPUList(l.CPUAffinity); err != nil {
+		return err
+	}
+	if l.Nice < -20 || l.Nice > 19 {
+		return fmt.Errorf("nice must be between -20 and 19")
+	}
+	if l.MemoryBytes < 0 {
+		return fmt.Errorf("memory limit must not be negative")
+	}
+	return checkIsolationSupport(l)
+}
+
+// isolate prepares cmd to run within the limits. release undoes the
+// preparation and must be called once the process has exited; it may be
+// called more than once.
+func (l ResourceLimits) isolate(cmd *uci.Command) (release func(), err error) {
+	var releases []func()
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			for i := len(releases) - 1; i >= 0; i-- {
+				releases[i]()
+			}
+		})
+	}
+
+	if l.SessionDir != "" {
+		if err := os.MkdirAll(l.SessionDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create session directory: %w", err)
+		}
+		dir, err := os.MkdirTemp(l.SessionDir, "engine-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session directory: %w", err)
+		}
+		cmd.Dir = dir
+		releases = append(releases, func() { os.RemoveAll(dir) })
+	}
+
+	if l.osLimits() {
+		undo, err := l.confine(cmd)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, undo)
+	}
+	return release, nil
+}
+
+// parseCPUList parses a list of cores and core ranges such as "0-3,6".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(hi)
+		}
+		if err != nil || first < 0 || last < first {
+			return nil, fmt.Errorf("invalid CPU list %q", s)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+	"golang.org/x/sys/unix"
+)
+
+func checkIsolationSupport(ResourceLimits) error {
+	return nil
+}
+
+// confine runs the process in its own cgroup, if configured, and applies
+// the other limits as soon as it starts. Affinity and niceness are per
+// thread on Linux, so they are set on every thread the engine has by then;
+// threads it starts later inherit them.
+func (l ResourceLimits) confine(cmd *uci.Command) (func(), error) {
+	cpus, err := parseCPUList(l.CPUAffinity)
+	if err != nil {
+		return nil, err
+	}
+
+	release := func() {}
+	if l.CgroupParent != "" {
+		dir, err := os.MkdirTemp(l.CgroupParent, "engine-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cgroup: %w", err)
+		}
+		if l.MemoryBytes > 0 {
+			limit := []byte(strconv.FormatInt(l.MemoryBytes, 10))
+			if err := os.WriteFile(filepath.Join(dir, "memory.max"), limit, 0); err != nil {
+				os.Remove(dir)
+				return nil, fmt.Errorf("failed to set cgroup memory limit: %w", err)
+			}
+		}
+		fd, err := syscall.Open(dir, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			os.Remove(dir)
+			return nil, fmt.Errorf("failed to open cgroup: %w", err)
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: fd}
+		release = func() {
+			syscall.Close(fd)
+			// The group can only be removed once the process is gone.
+			os.Remove(dir)
+		}
+	}
+
+	cmd.Started = func(p *os.Process) error {
+		if len(cpus) > 0 || l.Nice != 0 {
+			tasks, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", p.Pid))
+			if err != nil {
+				return err
+			}
+			for _, task := range tasks {
+				tid, _ := strconv.Atoi(task.Name())
+				if err := setThreadLimits(tid, cpus, l.Nice); err != nil {
+					return err
+				}
+			}
+		}
+		if l.MemoryBytes > 0 && l.CgroupParent == "" {
+			if err := setRlimit(p.Pid, unix.RLIMIT_AS, uint64(l.MemoryBytes)); err != nil {
+				return fmt.Errorf("failed to limit memory: %w", err)
+			}
+		}
+		if l.MaxOpenFiles > 0 {
+			if err := setRlimit(p.Pid, unix.RLIMIT_NOFILE, l.MaxOpenFiles); err != nil {
+				return fmt.Errorf("failed to limit open files: %w", err)
+			}
+		}
+		return nil
+	}
+	return release, nil
+}
+
+func setThreadLimits(tid int, cpus []int, nice int) error {
+	if len(cpus) > 0 {
+		var set unix.CPUSet
+		for _, cpu := range cpus {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(tid, &set); err != nil {
+			return fmt.Errorf("failed to set CPU affinity: %w", err)
+		}
+	}
+	if nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, nice); err != nil {
+			return fmt.Errorf("failed to set nice level: %w", err)
+		}
+	}
+	return nil
+}
+
+func setRlimit(pid, resource int, limit uint64) error {
+	return unix.Prlimit(pid, resource, &unix.Rlimit{Cur: limit, Max: limit}, nil)
+}
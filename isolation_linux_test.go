@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+	"golang.org/x/sys/unix"
+)
+
+func TestEngineResourceLimits(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	engine.Limits = ResourceLimits{
+		CPUAffinity:  "0",
+		Nice:         5,
+		MemoryBytes:  8 << 30,
+		MaxOpenFiles: 64,
+		SessionDir:   t.TempDir(),
+	}
+
+	session, err := startEngineSession("limited", engine, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := session.client.Process().Pid
+
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(pid, &set); err != nil || set.Count() != 1 || !set.IsSet(0) {
+		t.Errorf("affinity = %v (%d CPUs), %v", set, set.Count(), err)
+	}
+	// getpriority returns 20 - nice.
+	if prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid); err != nil || 20-prio != 5 {
+		t.Errorf("nice = %d, %v", 20-prio, err)
+	}
+	limits, _ := os.ReadFile(fmt.Sprintf("/proc/%d/limits", pid))
+	for _, want := range []string{"Max open files            64", "Max address space         8589934592"} {
+		if !strings.Contains(string(limits), want) {
+			t.Errorf("limits lack %q:\n%s", want, limits)
+		}
+	}
+	cwd, _ := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	if filepath.Dir(cwd) != engine.Limits.SessionDir {
+		t.Errorf("working directory = %s", cwd)
+	}
+
+	session.close()
+	if _, err := os.Stat(cwd); !os.IsNotExist(err) {
+		t.Errorf("session directory left behind: %v", err)
+	}
+}
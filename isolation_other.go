@@ -0,0 +1,24 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+var errIsolationUnsupported = errors.New(
+	"CPU affinity, nice, memory, cgroup and open file limits are only supported on Linux",
+)
+
+func checkIsolationSupport(l ResourceLimits) error {
+	if l.osLimits() {
+		return errIsolationUnsupported
+	}
+	return nil
+}
+
+func (l ResourceLimits) confine(cmd *uci.Command) (func(), error) {
+	return nil, errIsolationUnsupported
+}
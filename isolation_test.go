@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("4, 0-2")
+	if err != nil || fmt.Sprint(cpus) != "[0 1 2 4]" {
+		t.Errorf("parseCPUList = %v, %v", cpus, err)
+	}
+	for _, bad := range []string{"a", "3-1", "-1"} {
+		if _, err := parseCPUList(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
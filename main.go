@@ -4,16 +4,45 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog"
 )
 
 var version = "dev"
 
+const (
+	httpReadHeaderTimeout = 10 * time.Second
+	httpShutdownTimeout   = 10 * time.Second
+)
+
 func main() {
+	if len(os.Args) > 1 {
+		var command func([]string) error
+		switch os.Args[1] {
+		case "match":
+			command = runMatchCommand
+		case "suite":
+			command = runSuiteCommand
+		case "health":
+			command = runHealthCommand
+		}
+		if command != nil {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	if err := run(); err != nil {
 		if errors.Is(err, context.Canceled) {
 			fmt.Fprintln(os.Stderr, "Server stopped gracefully.")
@@ -44,99 +73,116 @@ func run() error {
 		Str("server_name", cfg.Server.Name).
 		Str("version", cfg.Server.Version).
 		Str("stockfish_path", cfg.Stockfish.Path).
+		Str("default_engine", cfg.Stockfish.DefaultEngine).
+		Strs("engines", cfg.Stockfish.engineNames()).
 		Int("max_sessions", cfg.Stockfish.MaxSessions).
 		Dur("session_timeout", cfg.Stockfish.SessionTimeout).
+		Str("syzygy_path", cfg.Stockfish.SyzygyPath).
+		Str("book_path", cfg.Stockfish.BookPath).
+		Bool("persistent_sessions", cfg.Stockfish.PersistentSessions).
+		Str("snapshot_path", cfg.Stockfish.SnapshotPath).
+		Str("audit_log", cfg.Audit.Path).
 		Str("server_mode", cfg.Server.Mode).
 		Str("http_host", cfg.Server.Host).
 		Int("http_port", cfg.Server.Port).
 		Msg("Configuration loaded")
 
-	var executor commandExecutor
-	executor = NewEphemeralSessionExecutor(cfg.Stockfish.Path, cfg.Stockfish.CommandTimeout, log)
+	engines := newEngineRegistry(cfg.Stockfish, log)
+	if err := selfCheck(engines, cfg.Stockfish.AllowDegraded, log); err != nil {
+		return err
+	}
 
-	stockfishHandler := newStockfishHandler(executor, log)
+	var book *OpeningBook
+	if cfg.Stockfish.BookPath != "" {
+		if book, err = openOpeningBook(cfg.Stockfish.BookPath, log); err != nil {
+			return err
+		}
+		defer book.Close()
+	}
+
+	var (
+		executor       commandExecutor
+		sessionManager *SessionManager
+	)
+	if cfg.Stockfish.PersistentSessions {
+		sessionManager = newSessionManager(cfg.Stockfish, engines, log)
+		defer sessionManager.Close()
+		executor = NewPersistentSessionExecutor(sessionManager, log)
+	} else {
+		executor = NewEphemeralSessionExecutor(engines, cfg.Stockfish.CommandTimeout, log)
+	}
+
+	stockfishHandler := newStockfishHandler(executor, engines, book, cfg.Stockfish.UseBook, log)
+	matchRunner := newMatchRunner(engines, cfg.Stockfish.CommandTimeout, log)
+	suiteRunner := newSuiteRunner(engines, cfg.Stockfish.MaxSessions, cfg.Stockfish.CommandTimeout, log)
+	gameManager := newGameManager(cfg.Stockfish, engines, book, log)
+	defer gameManager.Close()
+	tablebaseProber := newTablebaseProber(cfg.Stockfish, engines, log)
+	boardRenderer := newBoardRenderer(log)
+	analyzer := newAnalyzer(engines, cfg.Stockfish.MaxSessions, cfg.Stockfish.CommandTimeout, log)
+	health := newHealthChecker(engines, sessionManager, cfg.Stockfish.ReadyTimeout, cfg.Server.Version, log)
+
+	var serverOptions []server.ServerOption
+	if cfg.Audit.Path != "" {
+		auditLog, err := newAuditLog(cfg.Audit, log)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+		serverOptions = append(serverOptions, server.WithToolHandlerMiddleware(auditLog.middleware))
+	}
+
+	var auth *Authenticator
+	if ServerMode(cfg.Server.Mode) == ServerModeHTTP {
+		if auth, err = newAuthenticator(cfg.Auth, log); err != nil {
+			return err
+		}
+		if auth != nil {
+			serverOptions = append(serverOptions, server.WithToolHandlerMiddleware(auth.toolMiddleware))
+		}
+	}
+
+	if cfg.RateLimit.Rate > 0 || cfg.RateLimit.EngineTime > 0 {
+		limiter := newRateLimiter(cfg.RateLimit, log)
+		serverOptions = append(serverOptions, server.WithToolHandlerMiddleware(limiter.middleware))
+	}
 
 	s := server.NewMCPServer(
 		cfg.Server.Name,
 		cfg.Server.Version,
+		serverOptions...,
 	)
 
-	stockfishTool := mcp.NewTool(
-		"chess_engine",
-		mcp.WithDescription(`
-Advanced chess analysis using Stockfish engine via UCI (Universal Chess Interface).
-Analyzes positions, finds best moves, evaluates positions. Returns structured results.
-
-MOVE NOTATION: Use algebraic notation (e2e4, g1f3, e1g1 for castling, e7e8q for promotion)
-EVALUATION: Centipawns (100 = 1 pawn), positive = White advantage, negative = Black advantage
-
-═══ UCI COMMAND REFERENCE ═══
-
-ENGINE CONTROL:
-┌─ uci           → Initialize engine, get info & options
-├─ isready       → Check if engine ready for commands  
-├─ quit          → Shutdown engine
-└─ stop          → Stop current analysis immediately
-
-POSITION SETUP:
-┌─ position startpos                    → Initial chess position
-├─ position startpos moves e2e4 e7e5    → Initial + move sequence
-├─ position fen [FEN]                   → Custom position from FEN
-└─ position fen [FEN] moves [MOVES]     → Custom FEN + additional moves
-
-ANALYSIS COMMANDS:
-┌─ go depth [N]       → Analyze N plies deep (typical: 15-25)
-├─ go movetime [MS]   → Analyze for N milliseconds (typical: 3000-10000)
-├─ go infinite       → Analyze until stopped (use 'stop' to end)
-├─ go wtime [MS] btime [MS]  → Analysis with time controls
-└─ go nodes [N]      → Analyze exactly N nodes
-
-ENGINE OPTIONS (setoption name [NAME] value [VALUE]):
-┌─ Hash [1-32768]         → Memory in MB (default: 16)
-├─ Threads [1-128]        → CPU threads (default: 1)  
-├─ MultiPV [1-500]        → Show N best lines (default: 1)
-├─ Skill Level [0-20]     → Engine strength (20=strongest)
-├─ Move Overhead [0-5000] → Time buffer in ms
-├─ Slow Mover [10-1000]   → Time usage factor
-└─ Contempt [-100-100]    → Draw tendency
-
-TYPICAL WORKFLOWS:
-1. Quick analysis:    "position startpos moves e2e4" → "go movetime 3000"
-2. Deep analysis:     "position startpos" → "go depth 25" 
-3. Custom position:   "position fen r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq -" → "go depth 20"
-4. Multi-line:        "setoption name MultiPV value 3" → "position startpos" → "go depth 18"
-5. Weaker play:       "setoption name Skill Level value 10" → "position startpos" → "go depth 15"
-
-EXAMPLES:
-• "uci" → Get engine info
-• "position startpos moves e2e4 e7e5 g1f3 b8c6" → Set position  
-• "go depth 18" → Deep analysis
-• "setoption name Hash value 512" → Increase memory
-• "setoption name MultiPV value 5" → Show top 5 moves
-		`),
-		mcp.WithString(
-			"command",
-			mcp.Required(),
-			mcp.Description(`
-Exact UCI command to execute. Use commands from the reference above.
-
-QUICK REFERENCE:
-• uci, isready, quit, stop
-• position startpos [moves MOVE_LIST]  
-• position fen FEN_STRING [moves MOVE_LIST]
-• go depth N | go movetime MS | go infinite
-• setoption name OPTION_NAME value VALUE
-
-MOVE FORMAT: e2e4 e7e5 g1f3 (algebraic notation)
-EXAMPLES: "position startpos moves e2e4", "go depth 15", "setoption name Hash value 256"
-			`),
-		),
-	)
-	s.AddTool(stockfishTool, stockfishHandler.handle)
+	s.AddTool(newChessEngineTool(engines), stockfishHandler.handle)
+	s.AddTool(newListEnginesTool(), stockfishHandler.handleListEngines)
+	s.AddTool(newRunMatchTool(), matchRunner.handle)
+	s.AddTool(newRunTestSuiteTool(), suiteRunner.handle)
+	s.AddTool(newNewGameTool(), gameManager.handleNewGame)
+	s.AddTool(newMakeMoveTool(), gameManager.handleMakeMove)
+	s.AddTool(newEngineMoveTool(), gameManager.handleEngineMove)
+	s.AddTool(newUndoMoveTool(), gameManager.handleUndo)
+	s.AddTool(newResignGameTool(), gameManager.handleResign)
+	s.AddTool(newGameStatusTool(), gameManager.handleStatus)
+	s.AddTool(newChess960PositionTool(), gameManager.handleChess960Position)
+	s.AddTool(newProbeTablebaseTool(cfg.Stockfish.SyzygyProbeLimit), tablebaseProber.handle)
+	s.AddTool(newBookMovesTool(), book.handle)
+	s.AddTool(newRenderBoardTool(), boardRenderer.handle)
+	s.AddTool(newCheckMoveTool(), analyzer.handleCheckMove)
+	s.AddTool(newDescribePositionTool(), analyzer.handleDescribePosition)
+	s.AddTool(newGeneratePuzzlesTool(), analyzer.handleGeneratePuzzles)
+	s.AddTool(newAnalyzeBatchTool(), analyzer.handleAnalyzeBatch)
+	s.AddTool(newHealthTool(), health.handle)
+
+	// Ephemeral sessions end with their command: there is nothing to show.
+	if sessionManager != nil && cfg.Stockfish.TranscriptSize > 0 {
+		transcripts := newTranscriptHandler(sessionManager, log)
+		s.AddTool(newSessionTranscriptTool(), transcripts.handle)
+		s.AddResourceTemplate(newSessionTranscriptResource(), transcripts.handleResource)
+	}
 
 	switch ServerMode(cfg.Server.Mode) {
 	case ServerModeHTTP:
-		return runHTTPServer(s, cfg, log)
+		return runHTTPServer(s, cfg, auth, health, log)
 	case ServerModeStdio:
 		return runStdioServer(s, log)
 	default:
@@ -144,41 +190,63 @@ EXAMPLES: "position startpos moves e2e4", "go depth 15", "setoption name Hash va
 	}
 }
 
-func runHTTPServer(s *server.MCPServer, cfg *Config, log zerolog.Logger) error {
-	// TODO: waiting for https://github.com/mark3labs/mcp-go/pull/331
-	return nil
-	//ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	//defer stop()
-
-	//addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	//log.Info().
-	//	Str("address", addr).
-	//	Bool("cors_enabled", cfg.Server.CORS).
-	//	Msg("MCP Stockfish HTTP server starting")
-
-	//httpServer := server.NewStreamableHTTPServer(s)
-	//errCh := make(chan error, 1)
-	//go func() {
-	//	errCh <- httpServer.Start(addr)
-	//}()
-
-	//select {
-	//case <-ctx.Done():
-	//	log.Info().Msg("Shutdown signal received, stopping HTTP server...")
-	//	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	//	defer cancel()
-	//	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-	//		log.Error().Err(err).Msg("HTTP server shutdown error")
-	//		return err
-	//	}
-	//	log.Info().Msg("HTTP server stopped gracefully.")
-	//	return context.Canceled
-	//case err := <-errCh:
-	//	if err != nil {
-	//		return fmt.Errorf("HTTP server error: %w", err)
-	//	}
-	//	return nil
-	//}
+func runHTTPServer(
+	s *server.MCPServer,
+	cfg *Config,
+	auth *Authenticator,
+	health *HealthChecker,
+	log zerolog.Logger,
+) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	log.Info().
+		Str("address", addr).
+		Bool("auth_enabled", auth != nil).
+		Msg("MCP Stockfish HTTP server starting")
+
+	return serveHTTP(ctx, listener, s, auth, health, log)
+}
+
+// serveHTTP serves the MCP SSE transport, behind newHTTPHandler, until ctx
+// ends. It then closes the client streams and gives the requests in flight
+// httpShutdownTimeout to finish.
+func serveHTTP(
+	ctx context.Context,
+	listener net.Listener,
+	s *server.MCPServer,
+	auth *Authenticator,
+	health *HealthChecker,
+	log zerolog.Logger,
+) error {
+	httpServer := &http.Server{ReadHeaderTimeout: httpReadHeaderTimeout}
+	transport := server.NewSSEServer(s, server.WithHTTPServer(httpServer))
+	httpServer.Handler = newHTTPHandler(transport, auth, health)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("Shutdown signal received, stopping HTTP server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := transport.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("HTTP server shutdown error")
+			return err
+		}
+		log.Info().Msg("HTTP server stopped gracefully.")
+		return context.Canceled
+	case err := <-errCh:
+		return fmt.Errorf("HTTP server error: %w", err)
+	}
 }
 
 func runStdioServer(s *server.MCPServer, log zerolog.Logger) error {
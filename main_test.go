@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+// The test binary doubles as the fake engine started by the sessions.
+func TestMain(m *testing.M) {
+	ucitest.Main()
+	os.Exit(m.Run())
+}
+
+const testEngine = "fake"
+
+// fakeEngine configures the fake engine running script, logging the
+// commands it receives to a file returned by commandLog.
+func fakeEngine(t *testing.T, script ucitest.Script) EngineConfig {
+	t.Helper()
+	if script.Log == "" {
+		script.Log = filepath.Join(t.TempDir(), "commands.log")
+	}
+	path, args := script.Command()
+	return EngineConfig{
+		Name:           testEngine,
+		Path:           path,
+		Args:           args,
+		MaxSessions:    4,
+		SessionTimeout: time.Minute,
+	}
+}
+
+// commandLog returns the commands the engine received, one per line.
+func commandLog(t *testing.T, engine EngineConfig) []string {
+	t.Helper()
+	script, err := ucitest.ParseScript(engine.Args[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(script.Log)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func testConfig(engine EngineConfig) StockfishConfig {
+	return StockfishConfig{
+		Path:           engine.Path,
+		MaxSessions:    engine.MaxSessions,
+		SessionTimeout: time.Minute,
+		CommandTimeout: 2 * time.Second,
+		DefaultEngine:  engine.Name,
+		Engines:        map[string]EngineConfig{engine.Name: engine},
+		QueueMaxWait:   5 * time.Second,
+		QueueMaxLength: 8,
+	}
+}
+
+func newTestSessionManager(t *testing.T, config StockfishConfig) *SessionManager {
+	t.Helper()
+	sm := newSessionManager(config, newEngineRegistry(config, zerolog.Nop()), zerolog.Nop())
+	t.Cleanup(sm.Close)
+	return sm
+}
+
+func callTool(
+	t *testing.T,
+	handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error),
+	args map[string]any,
+) (string, bool) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Arguments = args
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	return result.Content[0].(mcp.TextContent).Text, result.IsError
+}
+
+func decode[T any](t *testing.T, text string) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", text, err)
+	}
+	return v
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+const (
+	defaultMatchGames    = 2
+	defaultMatchMoveTime = 100 * time.Millisecond
+	defaultMatchMaxPlies = 400
+	maxMatchGames        = 1000
+)
+
+// MatchPlayer is one side of a match: an engine of the registry plus the
+// options that distinguish it, e.g. a lower Skill Level.
+type MatchPlayer struct {
+	Engine  string              `json:"engine"`
+	Options []EngineOptionValue `json:"options,omitempty"`
+}
+
+func (p MatchPlayer) Label() string {
+	if len(p.Options) == 0 {
+		return p.Engine
+	}
+	opts := make([]string, 0, len(p.Options))
+	for _, opt := range p.Options {
+		opts = append(opts, opt.Name+"="+opt.Value)
+	}
+	return fmt.Sprintf("%s (%s)", p.Engine, strings.Join(opts, ", "))
+}
+
+type MatchConfig struct {
+	PlayerA  MatchPlayer
+	PlayerB  MatchPlayer
+	Games    int
+	Openings []string
+
+	MoveTime time.Duration
+	Depth    int
+	Nodes    int
+	MaxPlies int
+
+	// Score adjudication, disabled when the move count is zero. Scores are
+	// in centipawns.
+	ResignScore int
+	ResignMoves int
+	DrawScore   int
+	DrawMoves   int
+	DrawMinPly  int
+}
+
+// MatchGame is the outcome of one game, from player A's point of view.
+type MatchGame struct {
+	Round       int    `json:"round"`
+	White       string `json:"white"`
+	Black       string `json:"black"`
+	Result      string `json:"result"`
+	Termination string `json:"termination"`
+	Plies       int    `json:"plies"`
+	PGN         string `json:"pgn,omitempty"`
+}
+
+type MatchResult struct {
+	Status   string      `json:"status"`
+	PlayerA  string      `json:"player_a"`
+	PlayerB  string      `json:"player_b"`
+	Games    int         `json:"games"`
+	Wins     int         `json:"wins"`
+	Draws    int         `json:"draws"`
+	Losses   int         `json:"losses"`
+	Score    float64     `json:"score"`
+	EloDiff  *float64    `json:"elo_diff,omitempty"`
+	EloError *float64    `json:"elo_error_95,omitempty"`
+	Results  []MatchGame `json:"results"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// MatchRunner plays engine-vs-engine matches on processes of its own,
+// outside of the session pool. The two engines take slots of the global
+// engine limit for the length of the match.
+type MatchRunner struct {
+	engines        *EngineRegistry
+	commandTimeout time.Duration
+	logger         zerolog.Logger
+}
+
+func newMatchRunner(
+	engines *EngineRegistry,
+	commandTimeout time.Duration,
+	logger zerolog.Logger,
+) *MatchRunner {
+	return &MatchRunner{
+		engines:        engines,
+		commandTimeout: commandTimeout,
+		logger:         logger.With().Str("component", ComponentMatchRunner).Logger(),
+	}
+}
+
+func (cfg *MatchConfig) applyDefaults() {
+	if cfg.Games <= 0 {
+		cfg.Games = defaultMatchGames
+	}
+	if cfg.MoveTime <= 0 && cfg.Depth <= 0 && cfg.Nodes <= 0 {
+		cfg.MoveTime = defaultMatchMoveTime
+	}
+	if cfg.MaxPlies <= 0 {
+		cfg.MaxPlies = defaultMatchMaxPlies
+	}
+}
+
+func (cfg *MatchConfig) limits() uci.Limits {
+	return uci.Limits{MoveTime: cfg.MoveTime, Depth: cfg.Depth, Nodes: int64(cfg.Nodes)}
+}
+
+// parseOpenings turns each opening into a starting game. An opening is
+// either a FEN or PGN movetext played from the initial position.
+func parseOpenings(openings []string) ([]*Game, error) {
+	if len(openings) == 0 {
+		return []*Game{newGame(startPosition())}, nil
+	}
+
+	games := make([]*Game, 0, len(openings))
+	for _, opening := range openings {
+		opening = strings.TrimSpace(opening)
+		if pos, err := parseFEN(opening); err == nil {
+			games = append(games, newGame(pos))
+			continue
+		}
+		pgn, err := parsePGN(opening)
+		if err != nil || len(pgn) == 0 {
+			return nil, fmt.Errorf("opening %q is neither a FEN nor a PGN line", opening)
+		}
+		for _, g := range pgn {
+			game, err := g.Replay()
+			if err != nil {
+				return nil, fmt.Errorf("opening %q: %w", opening, err)
+			}
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+// Run plays cfg.Games games, cycling through the openings and playing each
+// one twice with colors reversed.
+func (r *MatchRunner) Run(ctx context.Context, cfg MatchConfig) (*MatchResult, error) {
+	cfg.applyDefaults()
+	if cfg.Games > maxMatchGames {
+		return nil, fmt.Errorf("games must be at most %d", maxMatchGames)
+	}
+	if cfg.MoveTime >= r.commandTimeout {
+		return nil, fmt.Errorf("movetime must be below the command timeout (%v)", r.commandTimeout)
+	}
+
+	openings, err := parseOpenings(cfg.Openings)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := r.engines.reserve(ctx, 2, PriorityBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	players := [2]*enginePlayer{}
+	for i, player := range []MatchPlayer{cfg.PlayerA, cfg.PlayerB} {
+		engine, err := r.engines.Get(player.Engine)
+		if err != nil {
+			return nil, err
+		}
+		engine.Options = append(
+			append([]EngineOptionValue(nil), engine.Options...),
+			player.Options...,
+		)
+		players[i] = &enginePlayer{config: engine, logger: r.logger}
+		defer players[i].close()
+	}
+
+	result := &MatchResult{
+		Status:  "success",
+		PlayerA: cfg.PlayerA.Label(),
+		PlayerB: cfg.PlayerB.Label(),
+		Results: []MatchGame{},
+	}
+
+	r.logger.Info().
+		Str("player_a", result.PlayerA).
+		Str("player_b", result.PlayerB).
+		Int("games", cfg.Games).
+		Int("openings", len(openings)).
+		Msg("Match started")
+
+	for round := 0; round < cfg.Games; round++ {
+		if err := ctx.Err(); err != nil {
+			result.Status = "partial"
+			result.Error = err.Error()
+			break
+		}
+
+		opening := openings[(round/2)%len(openings)]
+		aIsWhite := round%2 == 0
+		white, black := players[0], players[1]
+		whiteLabel, blackLabel := result.PlayerA, result.PlayerB
+		if !aIsWhite {
+			white, black = black, white
+			whiteLabel, blackLabel = blackLabel, whiteLabel
+		}
+
+		game, outcome, termination := r.playGame(ctx, cfg, opening, white, black)
+		if outcome == ResultOngoing {
+			result.Status = "partial"
+			result.Error = ctx.Err().Error()
+			break
+		}
+
+		record := MatchGame{
+			Round:       round + 1,
+			White:       whiteLabel,
+			Black:       blackLabel,
+			Result:      outcome,
+			Termination: termination,
+			Plies:       len(game.Moves()) - len(opening.Moves()),
+			PGN: formatPGN(game, map[string]string{
+				"Event":       "mcp-stockfish match",
+				"Site":        "?",
+				"Date":        time.Now().Format("2006.01.02"),
+				"Round":       fmt.Sprint(round + 1),
+				"White":       whiteLabel,
+				"Black":       blackLabel,
+				"Result":      outcome,
+				"Termination": termination,
+			}, "Termination"),
+		}
+		result.Results = append(result.Results, record)
+		result.Games++
+
+		switch {
+		case outcome == ResultDraw:
+			result.Draws++
+		case (outcome == ResultWhiteWins) == aIsWhite:
+			result.Wins++
+		default:
+			result.Losses++
+		}
+
+		r.logger.Info().
+			Int("round", round+1).
+			Str("white", whiteLabel).
+			Str("black", blackLabel).
+			Str("result", outcome).
+			Str("termination", termination).
+			Msg("Match game finished")
+	}
+
+	if result.Games > 0 {
+		result.Score = (float64(result.Wins) + float64(result.Draws)/2) / float64(result.Games)
+		if elo, margin, ok := eloDifference(result.Wins, result.Draws, result.Losses); ok {
+			result.EloDiff, result.EloError = &elo, &margin
+		}
+	}
+
+	return result, nil
+}
+
+// playGame plays a single game. Unfinished games are adjudicated or scored
+// as a loss for the engine that failed; only a game cut short by ctx ends
+// with ResultOngoing, as "aborted".
+func (r *MatchRunner) playGame(
+	ctx context.Context,
+	cfg MatchConfig,
+	opening *Game,
+	white, black *enginePlayer,
+) (*Game, string, string) {
+	game := newGame(opening.Start())
+	for _, m := range opening.Moves() {
+		game.Play(m)
+	}
+
+	for _, player := range []*enginePlayer{white, black} {
+		if err := player.newGame(r.commandTimeout); err != nil {
+			if ctx.Err() != nil {
+				return game, ResultOngoing, "aborted"
+			}
+			return game, lossFor(player == white), "engine failure: " + err.Error()
+		}
+	}
+
+	// Scores reported by each side, from its own point of view.
+	var scores [2][]int
+	limits := cfg.limits()
+
+	for ply := 0; ply < cfg.MaxPlies; ply++ {
+		if result, reason := game.Outcome(); result != ResultOngoing {
+			return game, result, reason
+		}
+		if ctx.Err() != nil {
+			return game, ResultOngoing, "aborted"
+		}
+
+		turn := game.Position().Turn()
+		player := white
+		if turn == Black {
+			player = black
+		}
+
+		search, err := player.search(ctx, game.PositionCommand(), limits, r.commandTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return game, ResultOngoing, "aborted"
+			}
+			return game, lossFor(turn == White), "engine failure: " + err.Error()
+		}
+
+		move, err := game.Position().ParseUCI(search.BestMove)
+		if err != nil {
+			return game, lossFor(turn == White), "illegal move: " + search.BestMove
+		}
+
+		if best := search.Best(); best != nil {
+			scores[turn] = append(scores[turn], best.scoreValue())
+		}
+		game.Play(move)
+
+		if reason, ok := cfg.adjudicateResign(scores[turn]); ok {
+			return game, lossFor(turn == White), reason
+		}
+		if len(game.Moves()) >= cfg.DrawMinPly {
+			if reason, ok := cfg.adjudicateDraw(scores); ok {
+				return game, ResultDraw, reason
+			}
+		}
+	}
+
+	if result, reason := game.Outcome(); result != ResultOngoing {
+		return game, result, reason
+	}
+	return game, ResultDraw, "adjudication: maximum game length"
+}
+
+func lossFor(white bool) string {
+	if white {
+		return ResultBlackWins
+	}
+	return ResultWhiteWins
+}
+
+func (cfg *MatchConfig) adjudicateResign(own []int) (string, bool) {
+	if cfg.ResignMoves <= 0 || len(own) < cfg.ResignMoves {
+		return "", false
+	}
+	for _, score := range own[len(own)-cfg.ResignMoves:] {
+		if score > -cfg.ResignScore {
+			return "", false
+		}
+	}
+	return "adjudication: resign score", true
+}
+
+func (cfg *MatchConfig) adjudicateDraw(scores [2][]int) (string, bool) {
+	if cfg.DrawMoves <= 0 {
+		return "", false
+	}
+	for _, own := range scores {
+		if len(own) < cfg.DrawMoves {
+			return "", false
+		}
+		for _, score := range own[len(own)-cfg.DrawMoves:] {
+			if score > cfg.DrawScore || score < -cfg.DrawScore {
+				return "", false
+			}
+		}
+	}
+	return "adjudication: draw score", true
+}
+
+// eloDifference estimates the Elo difference from a W/D/L record with a
+// 95% confidence margin. It is undefined for a 0% or 100% score.
+func eloDifference(wins, draws, losses int) (float64, float64, bool) {
+	n := float64(wins + draws + losses)
+	if n == 0 {
+		return 0, 0, false
+	}
+	score := (float64(wins) + float64(draws)/2) / n
+	if score <= 0 || score >= 1 {
+		return 0, 0, false
+	}
+
+	variance := (float64(wins)*math.Pow(1-score, 2) +
+		float64(draws)*math.Pow(0.5-score, 2) +
+		float64(losses)*math.Pow(score, 2)) / n
+	stderr := math.Sqrt(variance / n)
+
+	elo := scoreToElo(score)
+	lo := scoreToElo(math.Max(score-1.96*stderr, 1e-9))
+	hi := scoreToElo(math.Min(score+1.96*stderr, 1-1e-9))
+
+	return round1(elo), round1((hi - lo) / 2), true
+}
+
+func scoreToElo(score float64) float64 {
+	return -400 * math.Log10(1/score-1)
+}
+
+func round1(v float64) float64 {
+	r := math.Round(v*10) / 10
+	if r == 0 {
+		return 0 // avoid "-0" in JSON
+	}
+	return r
+}
+
+func (r *MatchRunner) handle(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	cfg := MatchConfig{
+		PlayerA: MatchPlayer{
+			Engine:  request.GetString("engine_a", r.engines.DefaultName()),
+			Options: parseEngineOptions(request.GetString("options_a", "")),
+		},
+		PlayerB: MatchPlayer{
+			Engine:  request.GetString("engine_b", r.engines.DefaultName()),
+			Options: parseEngineOptions(request.GetString("options_b", "")),
+		},
+		Games:       request.GetInt("games", defaultMatchGames),
+		Openings:    request.GetStringSlice("openings", nil),
+		MoveTime:    time.Duration(request.GetInt("movetime", 0)) * time.Millisecond,
+		Depth:       request.GetInt("depth", 0),
+		Nodes:       request.GetInt("nodes", 0),
+		MaxPlies:    request.GetInt("max_plies", defaultMatchMaxPlies),
+		ResignScore: request.GetInt("resign_score", 0),
+		ResignMoves: request.GetInt("resign_moves", 0),
+		DrawScore:   request.GetInt("draw_score", 0),
+		DrawMoves:   request.GetInt("draw_moves", 0),
+		DrawMinPly:  request.GetInt("draw_min_ply", 0),
+	}
+
+	r.logger.Info().
+		Str("player_a", cfg.PlayerA.Label()).
+		Str("player_b", cfg.PlayerB.Label()).
+		Int("games", cfg.Games).
+		Msg("Received match request")
+
+	result, err := r.Run(ctx, cfg)
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("Match failed")
+		return mcp.NewToolResultError(fmt.Sprintf("Match failed: %s", err.Error())), nil
+	}
+
+	if !request.GetBool("include_pgn", true) {
+		for i := range result.Results {
+			result.Results[i].PGN = ""
+		}
+	}
+
+	return jsonToolResult(result, r.logger)
+}
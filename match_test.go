@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestMatchCountsAgainstSessionLimit(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{SearchTime: 300 * time.Millisecond})
+	config := testConfig(engine)
+	config.MaxSessions = 2
+	config.QueueMaxWait = time.Second
+	sm := newTestSessionManager(t, config)
+	runner := newMatchRunner(sm.engines, config.CommandTimeout, zerolog.Nop())
+	match := MatchConfig{Games: 1, Depth: 1, MaxPlies: 2}
+
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	_, err := runner.Run(context.Background(), match)
+	if err == nil || !strings.Contains(err.Error(), "maximum number of engines (2) running") {
+		t.Fatalf("match beside a session: %v", err)
+	}
+
+	sm.removeSession("a")
+	done := make(chan error, 1)
+	go func() {
+		_, err := runner.Run(context.Background(), match)
+		done <- err
+	}()
+	waitFor(t, func() bool { return sm.engines.slots.free() == 0 })
+
+	// A session request queues behind the match and gets a slot once the
+	// match ends.
+	if _, _, err := sm.getOrCreateSession(context.Background(), "b", "", PriorityInteractive); err != nil {
+		t.Errorf("session after the match: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchStopsWhenCancelled(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	config := testConfig(engine)
+	engines := newEngineRegistry(config, zerolog.Nop())
+	runner := newMatchRunner(engines, config.CommandTimeout, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := runner.Run(ctx, MatchConfig{Games: 10, Depth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "partial" || result.Games != 0 || result.Error != context.Canceled.Error() {
+		t.Errorf("result = %+v", result)
+	}
+	if free := engines.slots.free(); free != config.MaxSessions {
+		t.Errorf("%d of %d engine slots free after the match", free, config.MaxSessions)
+	}
+}
+
+func TestMatchCancelledDuringSearch(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{SearchTime: 10 * time.Second})
+	config := testConfig(engine)
+	engines := newEngineRegistry(config, zerolog.Nop())
+	runner := newMatchRunner(engines, time.Minute, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *MatchResult, 1)
+	go func() {
+		result, err := runner.Run(ctx, MatchConfig{Games: 2, Depth: 1})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+	waitFor(t, func() bool {
+		for _, line := range commandLog(t, engine) {
+			if strings.HasPrefix(line, "go") {
+				return true
+			}
+		}
+		return false
+	})
+	cancel()
+
+	result := <-done
+	if result.Status != "partial" || result.Games != 0 || result.Losses != 0 || len(result.Results) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+}
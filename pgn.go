@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PGNGame is one game read from PGN text: its tag pairs and its mainline
+// in SAN. Comments, NAGs and variations are dropped.
+type PGNGame struct {
+	Tags  map[string]string
+	Moves []string
+}
+
+// FEN returns the starting position of the game, honoring the FEN tag.
+func (g PGNGame) FEN() string {
+	if fen := g.Tags["FEN"]; fen != "" {
+		return fen
+	}
+	return startFEN
+}
+
+// Replay plays the mainline on a board, validating every move.
+func (g PGNGame) Replay() (*Game, error) {
+	return newGameFromMoves(g.FEN(), g.Moves)
+}
+
+var pgnTagOrder = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// formatPGN renders a game with its tags. Seven-tag-roster tags come first,
+// then the rest in the order given by extra.
+func formatPGN(g *Game, tags map[string]string, extra ...string) string {
+	var sb strings.Builder
+
+	result := tags["Result"]
+	if result == "" {
+		result = ResultOngoing
+	}
+
+	for _, name := range pgnTagOrder {
+		value := tags[name]
+		switch {
+		case name == "Result":
+			value = result
+		case value == "":
+			value = "?"
+		}
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", name, escapePGN(value))
+	}
+	if g.start.Chess960() {
+		sb.WriteString("[Variant \"Chess960\"]\n")
+	}
+	if fen := g.start.FEN(); fen != startFEN {
+		fmt.Fprintf(&sb, "[SetUp \"1\"]\n[FEN \"%s\"]\n", fen)
+	}
+	for _, name := range extra {
+		if value, ok := tags[name]; ok {
+			fmt.Fprintf(&sb, "[%s \"%s\"]\n", name, escapePGN(value))
+		}
+	}
+	sb.WriteByte('\n')
+
+	line := 0
+	write := func(token string) {
+		if line > 0 && line+1+len(token) > 79 {
+			sb.WriteByte('\n')
+			line = 0
+		} else if line > 0 {
+			sb.WriteByte(' ')
+			line++
+		}
+		sb.WriteString(token)
+		line += len(token)
+	}
+
+	moveNumber, turn := g.start.fullmove, g.start.turn
+	for i, san := range g.sans {
+		switch {
+		case turn == White:
+			write(fmt.Sprintf("%d. %s", moveNumber, san))
+		case i == 0:
+			write(fmt.Sprintf("%d... %s", moveNumber, san))
+		default:
+			write(san)
+		}
+		if turn == Black {
+			moveNumber++
+		}
+		turn = turn.Other()
+	}
+	write(result)
+	sb.WriteByte('\n')
+
+	return sb.String()
+}
+
+func escapePGN(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// parsePGN reads every game in a PGN text. Bare movetext without tags,
+// such as "1. e4 e5 2. Nf3", is read as a single game.
+func parsePGN(text string) ([]PGNGame, error) {
+	var (
+		games   []PGNGame
+		current = PGNGame{Tags: map[string]string{}}
+		inMoves bool
+	)
+
+	flush := func() {
+		if len(current.Tags) > 0 || len(current.Moves) > 0 {
+			games = append(games, current)
+		}
+		current = PGNGame{Tags: map[string]string{}}
+		inMoves = false
+	}
+
+	depth := 0 // variation nesting
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '[' && depth == 0:
+			if inMoves {
+				flush()
+			}
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated tag at offset %d", i)
+			}
+			name, value, ok := parsePGNTag(text[i+1 : i+end])
+			if !ok {
+				return nil, fmt.Errorf("malformed tag %q", text[i:i+end+1])
+			}
+			current.Tags[name] = value
+			i += end + 1
+		case c == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			i += end + 1
+		case c == ';':
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			i += end
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		default:
+			end := i
+			for end < len(text) && !strings.ContainsRune(" \t\r\n{}();[", rune(text[end])) {
+				end++
+			}
+			token := text[i:end]
+			i = end
+			if depth > 0 {
+				continue
+			}
+			inMoves = true
+			switch token {
+			case ResultWhiteWins, ResultBlackWins, ResultDraw, ResultOngoing:
+				if _, ok := current.Tags["Result"]; !ok {
+					current.Tags["Result"] = token
+				}
+				flush()
+				continue
+			}
+			if san := stripMoveNumber(token); san != "" && san[0] != '$' {
+				current.Moves = append(current.Moves, san)
+			}
+		}
+	}
+	flush()
+
+	return games, nil
+}
+
+func parsePGNTag(s string) (string, string, bool) {
+	name, rest, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return "", "", false
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) < 2 || rest[0] != '"' || rest[len(rest)-1] != '"' {
+		return "", "", false
+	}
+	value := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(rest[1 : len(rest)-1])
+	return name, value, true
+}
+
+// stripMoveNumber removes "12." or "12..." prefixes from a movetext token.
+func stripMoveNumber(token string) string {
+	i := 0
+	for i < len(token) && token[i] >= '0' && token[i] <= '9' {
+		i++
+	}
+	if i == 0 || i == len(token) || token[i] != '.' {
+		if i == len(token) {
+			return ""
+		}
+		return token
+	}
+	return strings.TrimLeft(token[i:], ".")
+}
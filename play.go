@@ -0,0 +1,690 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+const (
+	GameStatusActive   = "active"
+	GameStatusFinished = "finished"
+)
+
+const (
+	TerminationResignation = "resignation"
+	TerminationTimeForfeit = "time forfeit"
+)
+
+const defaultGameMoveTime = time.Second
+
+// GameSettings controls how the engine plays a game.
+type GameSettings struct {
+	Engine     string `json:"engine"`
+	SkillLevel *int   `json:"skill_level,omitempty"`
+	Elo        *int   `json:"elo,omitempty"`
+
+	// Clock in milliseconds. Without an initial time the engine searches
+	// for MoveTimeMS or Depth instead.
+	TimeMS      int64 `json:"time_ms,omitempty"`
+	IncrementMS int64 `json:"increment_ms,omitempty"`
+	MoveTimeMS  int64 `json:"movetime_ms,omitempty"`
+	Depth       int   `json:"depth,omitempty"`
+
+	// UseBook plays opening book moves while the position is in the book.
+	UseBook bool `json:"use_book"`
+}
+
+// engineOptions translates the strength settings into UCI options.
+func (s GameSettings) engineOptions() []EngineOptionValue {
+	var options []EngineOptionValue
+	if s.SkillLevel != nil {
+		options = append(options, EngineOptionValue{
+			Name:  "Skill Level",
+			Value: strconv.Itoa(*s.SkillLevel),
+		})
+	}
+	if s.Elo != nil {
+		options = append(options,
+			EngineOptionValue{Name: "UCI_LimitStrength", Value: "true"},
+			EngineOptionValue{Name: "UCI_Elo", Value: strconv.Itoa(*s.Elo)},
+		)
+	}
+	return options
+}
+
+// PlayedGame is a game between a client and an engine.
+type PlayedGame struct {
+	ID          string
+	UserColor   Color
+	Settings    GameSettings
+	game        *Game
+	engine      *enginePlayer
+	release     func() // frees the game's engine slot
+	book        *OpeningBook
+	fromBook    []bool     // per ply, whether the move came from the book
+	clock       [2]int64   // remaining milliseconds per color
+	clocks      [][2]int64 // per ply, the clock after the move
+	turnStarted time.Time
+	result      string
+	termination string
+	lastEval    *InfoLine
+	lastUsed    time.Time
+	mu          sync.Mutex
+}
+
+type GameMove struct {
+	UCI  string `json:"uci"`
+	SAN  string `json:"san"`
+	By   string `json:"by"`
+	Book bool   `json:"book,omitempty"`
+}
+
+type GameStatus struct {
+	Status      string    `json:"status"`
+	GameID      string    `json:"game_id"`
+	State       string    `json:"state"`
+	UserColor   string    `json:"user_color"`
+	Engine      string    `json:"engine"`
+	Turn        string    `json:"turn"`
+	FEN         string    `json:"fen"`
+	MovesSAN    []string  `json:"moves_san"`
+	MovesUCI    []string  `json:"moves_uci"`
+	LastMove    *GameMove `json:"last_move,omitempty"`
+	InCheck     bool      `json:"in_check"`
+	LegalMoves  []string  `json:"legal_moves,omitempty"`
+	EngineEval  *InfoLine `json:"engine_eval,omitempty"`
+	Outlook     string    `json:"outlook,omitempty"`
+	WhiteTimeMS *int64    `json:"white_time_ms,omitempty"`
+	BlackTimeMS *int64    `json:"black_time_ms,omitempty"`
+	Result      string    `json:"result"`
+	Termination string    `json:"termination,omitempty"`
+	PGN         string    `json:"pgn"`
+}
+
+// GameManager keeps games against the engine. Each game owns an engine
+// process configured with the game's strength settings, and holds one of
+// the engine slots shared with sessions, matches and batches until it is
+// removed.
+type GameManager struct {
+	games          map[string]*PlayedGame
+	engines        *EngineRegistry
+	book           *OpeningBook
+	config         StockfishConfig
+	logger         zerolog.Logger
+	mu             sync.Mutex
+	stopCleanupCh  chan struct{}
+	shutdownOnce   sync.Once
+	commandTimeout time.Duration
+}
+
+func newGameManager(
+	config StockfishConfig,
+	engines *EngineRegistry,
+	book *OpeningBook,
+	logger zerolog.Logger,
+) *GameManager {
+	gm := &GameManager{
+		games:          make(map[string]*PlayedGame),
+		engines:        engines,
+		book:           book,
+		config:         config,
+		logger:         logger.With().Str("component", ComponentGameManager).Logger(),
+		stopCleanupCh:  make(chan struct{}),
+		commandTimeout: config.CommandTimeout,
+	}
+
+	go gm.cleanupRoutine()
+	return gm
+}
+
+func (gm *GameManager) Close() {
+	gm.shutdownOnce.Do(func() {
+		close(gm.stopCleanupCh)
+
+		gm.mu.Lock()
+		defer gm.mu.Unlock()
+
+		for id, g := range gm.games {
+			g.close()
+			delete(gm.games, id)
+		}
+	})
+}
+
+func (gm *GameManager) cleanupRoutine() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			gm.cleanupIdleGames()
+		case <-gm.stopCleanupCh:
+			return
+		}
+	}
+}
+
+func (gm *GameManager) cleanupIdleGames() {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	now := time.Now()
+	for id, g := range gm.games {
+		g.mu.Lock()
+		idle := now.Sub(g.lastUsed) > gm.config.SessionTimeout
+		g.mu.Unlock()
+
+		if idle {
+			g.close()
+			delete(gm.games, id)
+			gm.logger.Info().Str("game_id", id).Msg("Cleaned up idle game")
+		}
+	}
+}
+
+func (gm *GameManager) newGame(
+	ctx context.Context,
+	start *Position,
+	userColor Color,
+	settings GameSettings,
+) (*PlayedGame, error) {
+	engine, err := gm.engines.Get(settings.Engine)
+	if err != nil {
+		return nil, err
+	}
+	settings.Engine = engine.Name
+	engine.Options = append(
+		append([]EngineOptionValue(nil), engine.Options...),
+		settings.engineOptions()...,
+	)
+
+	if settings.UseBook && gm.book == nil {
+		return nil, fmt.Errorf("no opening book configured (set MCP_STOCKFISH_BOOK_PATH)")
+	}
+
+	if settings.TimeMS <= 0 && settings.MoveTimeMS <= 0 && settings.Depth <= 0 {
+		settings.MoveTimeMS = defaultGameMoveTime.Milliseconds()
+	}
+
+	game := newGame(start)
+
+	if err := gm.checkRoom(); err != nil {
+		return nil, err
+	}
+	release, err := gm.engines.reserve(ctx, 1, PriorityInteractive)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &PlayedGame{
+		ID:          uuid.NewString(),
+		UserColor:   userColor,
+		Settings:    settings,
+		game:        game,
+		engine:      &enginePlayer{config: engine, logger: gm.logger},
+		release:     release,
+		book:        gm.book,
+		clock:       [2]int64{settings.TimeMS, settings.TimeMS},
+		turnStarted: time.Now(),
+		result:      ResultOngoing,
+		lastUsed:    time.Now(),
+	}
+	// The engine starts outside gm.mu, which every game operation takes.
+	if err := g.engine.newGame(gm.commandTimeout); err != nil {
+		g.close()
+		return nil, err
+	}
+
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	if err := gm.checkRoomLocked(); err != nil {
+		g.close()
+		return nil, err
+	}
+	gm.games[g.ID] = g
+
+	gm.logger.Info().
+		Str("game_id", g.ID).
+		Str("engine", engine.Name).
+		Str("user_color", userColor.String()).
+		Msg("Game started")
+
+	return g, nil
+}
+
+func (gm *GameManager) checkRoom() error {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	return gm.checkRoomLocked()
+}
+
+func (gm *GameManager) checkRoomLocked() error {
+	if len(gm.games) >= gm.config.MaxSessions {
+		return fmt.Errorf("maximum number of games (%d) reached", gm.config.MaxSessions)
+	}
+	return nil
+}
+
+// remove ends a game, closing its engine and freeing its slot.
+func (gm *GameManager) remove(id string) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	if g, ok := gm.games[id]; ok {
+		g.close()
+		delete(gm.games, id)
+	}
+}
+
+func (gm *GameManager) get(id string) (*PlayedGame, error) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+
+	g, ok := gm.games[id]
+	if !ok {
+		return nil, fmt.Errorf("game %s not found", id)
+	}
+	return g, nil
+}
+
+// close stops the game's engine for good and frees its slot. Finished
+// games only stop the engine, which undoing moves starts again.
+func (g *PlayedGame) close() {
+	g.engine.close()
+	g.release()
+}
+
+// The methods below must be called with g.mu held.
+
+func (g *PlayedGame) finished() bool {
+	return g.result != ResultOngoing
+}
+
+func (g *PlayedGame) engineToMove() bool {
+	return g.game.Position().Turn() != g.UserColor
+}
+
+func (g *PlayedGame) hasClock() bool {
+	return g.Settings.TimeMS > 0
+}
+
+// spendClock charges the side to move for the time since its turn began and
+// adds the increment. It reports false when the flag fell.
+func (g *PlayedGame) spendClock(elapsed time.Duration) bool {
+	if !g.hasClock() {
+		return true
+	}
+	turn := g.game.Position().Turn()
+	g.clock[turn] -= elapsed.Milliseconds()
+	if g.clock[turn] <= 0 {
+		g.clock[turn] = 0
+		g.result, g.termination = lossFor(turn == White), TerminationTimeForfeit
+		return false
+	}
+	g.clock[turn] += g.Settings.IncrementMS
+	return true
+}
+
+func (g *PlayedGame) play(m Move, fromBook bool) {
+	g.game.Play(m)
+	g.fromBook = append(g.fromBook, fromBook)
+	g.clocks = append(g.clocks, g.clock)
+	g.turnStarted = time.Now()
+	if result, reason := g.game.Outcome(); result != ResultOngoing {
+		g.result, g.termination = result, reason
+		g.engine.close()
+	}
+}
+
+func (g *PlayedGame) userMove(s string) error {
+	if g.finished() {
+		return fmt.Errorf("game is over: %s (%s)", g.result, g.termination)
+	}
+	if g.engineToMove() {
+		return fmt.Errorf("it is the engine's turn")
+	}
+	m, err := g.game.Position().ParseMove(s)
+	if err != nil {
+		return err
+	}
+	if g.spendClock(time.Since(g.turnStarted)) {
+		g.play(m, false)
+	}
+	return nil
+}
+
+func (g *PlayedGame) limits() uci.Limits {
+	s := g.Settings
+	if s.TimeMS > 0 {
+		ms := func(v int64) time.Duration { return time.Duration(v) * time.Millisecond }
+		return uci.Limits{
+			WTime: ms(g.clock[White]),
+			BTime: ms(g.clock[Black]),
+			WInc:  ms(s.IncrementMS),
+			BInc:  ms(s.IncrementMS),
+		}
+	}
+	if s.Depth > 0 {
+		return uci.Limits{Depth: s.Depth}
+	}
+	return uci.Limits{MoveTime: time.Duration(s.MoveTimeMS) * time.Millisecond}
+}
+
+func (g *PlayedGame) engineMove(ctx context.Context, timeout time.Duration) error {
+	if g.finished() {
+		return fmt.Errorf("game is over: %s (%s)", g.result, g.termination)
+	}
+	if !g.engineToMove() {
+		return fmt.Errorf("it is the user's turn")
+	}
+
+	if g.hasClock() {
+		remaining := time.Duration(g.clock[g.game.Position().Turn()]) * time.Millisecond
+		if remaining+time.Second > timeout {
+			timeout = remaining + time.Second
+		}
+	}
+
+	started := time.Now()
+	if g.Settings.UseBook {
+		bookMove, err := g.book.Pick(g.game.Position())
+		if err != nil {
+			return err
+		}
+		if bookMove != nil {
+			g.lastEval = nil
+			if g.spendClock(time.Since(started)) {
+				g.play(bookMove.move, true)
+			}
+			return nil
+		}
+	}
+
+	search, err := g.engine.search(ctx, g.game.PositionCommand(), g.limits(), timeout)
+	if err != nil {
+		return fmt.Errorf("engine failed to move: %w", err)
+	}
+	m, err := g.game.Position().ParseUCI(search.BestMove)
+	if err != nil {
+		return fmt.Errorf("engine played an illegal move: %w", err)
+	}
+	search.fillWDL(g.game.Position())
+	g.lastEval = search.Best()
+
+	if g.spendClock(time.Since(started)) {
+		g.play(m, false)
+	}
+	return nil
+}
+
+func (g *PlayedGame) status() GameStatus {
+	pos := g.game.Position()
+	status := GameStatus{
+		Status:      "success",
+		GameID:      g.ID,
+		State:       GameStatusActive,
+		UserColor:   g.UserColor.String(),
+		Engine:      g.Settings.Engine,
+		Turn:        pos.Turn().String(),
+		FEN:         pos.FEN(),
+		MovesSAN:    append([]string{}, g.game.SANs()...),
+		MovesUCI:    g.game.UCIMoves(),
+		InCheck:     pos.InCheck(),
+		EngineEval:  g.lastEval,
+		Result:      g.result,
+		Termination: g.termination,
+	}
+	// The last evaluation was made with the engine to move.
+	if g.lastEval != nil && g.lastEval.WDL != nil {
+		status.Outlook = g.lastEval.WDL.outlook(g.UserColor.Other())
+	}
+
+	if g.finished() {
+		status.State = GameStatusFinished
+	} else {
+		for _, m := range pos.LegalMoves() {
+			status.LegalMoves = append(status.LegalMoves, pos.SAN(m))
+		}
+	}
+
+	if n := len(status.MovesUCI); n > 0 {
+		by := "user"
+		if (n%2 == 1) == (g.game.Start().Turn() != g.UserColor) {
+			by = "engine"
+		}
+		status.LastMove = &GameMove{
+			UCI:  status.MovesUCI[n-1],
+			SAN:  status.MovesSAN[n-1],
+			By:   by,
+			Book: g.fromBook[n-1],
+		}
+	}
+
+	if g.hasClock() {
+		white, black := g.clock[White], g.clock[Black]
+		status.WhiteTimeMS, status.BlackTimeMS = &white, &black
+	}
+
+	players := map[Color]string{g.UserColor: "user", g.UserColor.Other(): g.Settings.Engine}
+	tags := map[string]string{
+		"Event":  "mcp-stockfish game",
+		"Site":   "?",
+		"Date":   time.Now().Format("2006.01.02"),
+		"Round":  "-",
+		"White":  players[White],
+		"Black":  players[Black],
+		"Result": g.result,
+	}
+	extra := []string{}
+	if g.termination != "" {
+		tags["Termination"] = g.termination
+		extra = append(extra, "Termination")
+	}
+	status.PGN = formatPGN(g.game, tags, extra...)
+
+	return status
+}
+
+// Tool handlers
+
+func (gm *GameManager) handleNewGame(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	var userColor Color
+	switch strings.ToLower(request.GetString("user_color", "white")) {
+	case "white", "w":
+		userColor = White
+	case "black", "b":
+		userColor = Black
+	case "random":
+		userColor = Color(rand.Intn(2))
+	default:
+		return mcp.NewToolResultError("user_color must be 'white', 'black' or 'random'"), nil
+	}
+
+	settings := GameSettings{
+		Engine:      request.GetString("engine", ""),
+		TimeMS:      int64(request.GetInt("time_ms", 0)),
+		IncrementMS: int64(request.GetInt("increment_ms", 0)),
+		MoveTimeMS:  int64(request.GetInt("movetime", 0)),
+		Depth:       request.GetInt("depth", 0),
+		UseBook:     request.GetBool("use_book", gm.config.UseBook),
+	}
+	args := request.GetArguments()
+	if _, ok := args["skill_level"]; ok {
+		level := request.GetInt("skill_level", 20)
+		settings.SkillLevel = &level
+	}
+	if _, ok := args["elo"]; ok {
+		elo := request.GetInt("elo", 0)
+		settings.Elo = &elo
+	}
+
+	// The start position is passed on as is, so that a requested Chess960
+	// game keeps its rules even where its FEN looks standard.
+	start := startPosition()
+	fen := request.GetString("fen", "")
+	if _, ok := args["chess960_index"]; ok {
+		if fen != "" {
+			return mcp.NewToolResultError("Give either fen or chess960_index, not both"), nil
+		}
+		pos, err := chess960Position(request.GetInt("chess960_index", chess960StandardIndex))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		start = pos
+	} else if fen != "" && fen != "startpos" {
+		pos, err := parseFEN(fen)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to start game: %s", err.Error())), nil
+		}
+		start = pos
+	}
+
+	g, err := gm.newGame(ctx, start, userColor, settings)
+	if err != nil {
+		gm.logger.Warn().Err(err).Msg("Failed to start game")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to start game: %s", err.Error())), nil
+	}
+
+	g.mu.Lock()
+	if g.engineToMove() && !g.finished() {
+		err = g.engineMove(ctx, gm.commandTimeout)
+	}
+	status := g.status()
+	g.mu.Unlock()
+
+	if err != nil {
+		// The client never got the game_id: drop the game rather than
+		// leave it holding an engine until it is cleaned up as idle.
+		gm.remove(g.ID)
+		gm.logger.Warn().Err(err).Str("game_id", g.ID).Msg("Failed to start game")
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to start game: %s", err.Error())), nil
+	}
+	return jsonToolResult(status, gm.logger)
+}
+
+func (gm *GameManager) withGame(
+	request mcp.CallToolRequest,
+	fn func(g *PlayedGame) error,
+) (*mcp.CallToolResult, error) {
+	id, err := request.RequireString("game_id")
+	if err != nil {
+		return mcp.NewToolResultError("Missing 'game_id' parameter"), nil
+	}
+	g, err := gm.get(id)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lastUsed = time.Now()
+
+	if err := fn(g); err != nil {
+		gm.logger.Debug().Err(err).Str("game_id", id).Msg("Game action rejected")
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return jsonToolResult(g.status(), gm.logger)
+}
+
+func (gm *GameManager) handleMakeMove(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	move, err := request.RequireString("move")
+	if err != nil {
+		return mcp.NewToolResultError("Missing 'move' parameter"), nil
+	}
+	autoReply := request.GetBool("auto_reply", true)
+
+	return gm.withGame(request, func(g *PlayedGame) error {
+		if err := g.userMove(move); err != nil {
+			return err
+		}
+		if autoReply && !g.finished() {
+			return g.engineMove(ctx, gm.commandTimeout)
+		}
+		return nil
+	})
+}
+
+func (gm *GameManager) handleEngineMove(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	return gm.withGame(request, func(g *PlayedGame) error {
+		return g.engineMove(ctx, gm.commandTimeout)
+	})
+}
+
+func (gm *GameManager) handleUndo(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	return gm.withGame(request, func(g *PlayedGame) error {
+		// A resignation or a fallen flag is not undone by taking back
+		// moves; only the end of the game on the board is.
+		switch g.termination {
+		case TerminationResignation, TerminationTimeForfeit:
+			return fmt.Errorf("game is over: %s (%s)", g.result, g.termination)
+		}
+
+		// By default take back until it is the user's turn again.
+		plies := 2
+		if g.engineToMove() {
+			plies = 1
+		}
+		plies = request.GetInt("plies", plies)
+
+		if err := g.game.Undo(plies); err != nil {
+			return err
+		}
+		n := len(g.game.Moves())
+		g.fromBook = g.fromBook[:n]
+		g.clocks = g.clocks[:n]
+		g.clock = [2]int64{g.Settings.TimeMS, g.Settings.TimeMS}
+		if n > 0 {
+			g.clock = g.clocks[n-1]
+		}
+		g.result, g.termination = ResultOngoing, ""
+		g.lastEval = nil
+		g.turnStarted = time.Now()
+		return nil
+	})
+}
+
+func (gm *GameManager) handleResign(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	return gm.withGame(request, func(g *PlayedGame) error {
+		if g.finished() {
+			return fmt.Errorf("game is over: %s (%s)", g.result, g.termination)
+		}
+		g.result, g.termination = lossFor(g.UserColor == White), TerminationResignation
+		g.engine.close()
+		return nil
+	})
+}
+
+func (gm *GameManager) handleStatus(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	return gm.withGame(request, func(g *PlayedGame) error {
+		return nil
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestUndoRestoresClocks(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{BestMove: "e7e5"}))
+	gm := newGameManager(config, newEngineRegistry(config, zerolog.Nop()), nil, zerolog.Nop())
+	t.Cleanup(gm.Close)
+
+	text, isErr := callTool(t, gm.handleNewGame, map[string]any{"time_ms": 60000, "depth": 1})
+	if isErr {
+		t.Fatal(text)
+	}
+	id := decode[GameStatus](t, text).GameID
+
+	text, isErr = callTool(t, gm.handleMakeMove, map[string]any{"game_id": id, "move": "e4"})
+	if isErr {
+		t.Fatal(text)
+	}
+	before := decode[GameStatus](t, text)
+	if before.LastMove == nil || before.LastMove.UCI != "e7e5" {
+		t.Fatalf("engine reply: %+v", before.LastMove)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	text, isErr = callTool(t, gm.handleMakeMove, map[string]any{"game_id": id, "move": "Nf3", "auto_reply": false})
+	if isErr {
+		t.Fatal(text)
+	}
+	if after := decode[GameStatus](t, text); *after.WhiteTimeMS >= *before.WhiteTimeMS {
+		t.Fatalf("white clock not charged: %d -> %d", *before.WhiteTimeMS, *after.WhiteTimeMS)
+	}
+
+	text, isErr = callTool(t, gm.handleUndo, map[string]any{"game_id": id, "plies": 1})
+	if isErr {
+		t.Fatal(text)
+	}
+	undone := decode[GameStatus](t, text)
+	if *undone.WhiteTimeMS != *before.WhiteTimeMS || *undone.BlackTimeMS != *before.BlackTimeMS {
+		t.Errorf("clocks after undo: %d/%d, want %d/%d",
+			*undone.WhiteTimeMS, *undone.BlackTimeMS, *before.WhiteTimeMS, *before.BlackTimeMS)
+	}
+}
+
+func TestUndoAfterResignation(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{BestMove: "e7e5"}))
+	gm := newGameManager(config, newEngineRegistry(config, zerolog.Nop()), nil, zerolog.Nop())
+	t.Cleanup(gm.Close)
+
+	text, _ := callTool(t, gm.handleNewGame, map[string]any{"depth": 1})
+	id := decode[GameStatus](t, text).GameID
+	if text, isErr := callTool(t, gm.handleMakeMove, map[string]any{"game_id": id, "move": "e4"}); isErr {
+		t.Fatal(text)
+	}
+	if text, isErr := callTool(t, gm.handleResign, map[string]any{"game_id": id}); isErr {
+		t.Fatal(text)
+	}
+
+	text, isErr := callTool(t, gm.handleUndo, map[string]any{"game_id": id})
+	if !isErr || !strings.Contains(text, TerminationResignation) {
+		t.Errorf("undo after resigning: %s", text)
+	}
+	text, _ = callTool(t, gm.handleStatus, map[string]any{"game_id": id})
+	if status := decode[GameStatus](t, text); status.Result != ResultBlackWins || len(status.MovesUCI) != 2 {
+		t.Errorf("status after rejected undo: %s %v", status.Result, status.MovesUCI)
+	}
+}
+
+func TestNewGameDroppedWhenEngineFails(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{CrashOn: "go"}))
+	gm := newGameManager(config, newEngineRegistry(config, zerolog.Nop()), nil, zerolog.Nop())
+	t.Cleanup(gm.Close)
+
+	text, isErr := callTool(t, gm.handleNewGame, map[string]any{"user_color": "black", "depth": 1})
+	if !isErr || !strings.Contains(text, "Failed to start game") {
+		t.Errorf("new game with a crashing engine: %s", text)
+	}
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	if len(gm.games) != 0 {
+		t.Errorf("%d games kept", len(gm.games))
+	}
+}
+
+func TestNewChess960GameKeepsChess960Rules(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{BestMove: "e7e5"})
+	config := testConfig(engine)
+	gm := newGameManager(config, newEngineRegistry(config, zerolog.Nop()), nil, zerolog.Nop())
+	t.Cleanup(gm.Close)
+
+	// Index 518 is the standard setup, played with Chess960 castling.
+	text, isErr := callTool(t, gm.handleNewGame, map[string]any{"chess960_index": 518, "depth": 1})
+	if isErr {
+		t.Fatal(text)
+	}
+	status := decode[GameStatus](t, text)
+	if !strings.Contains(status.PGN, `[Variant "Chess960"]`) {
+		t.Errorf("PGN of a Chess960 game:\n%s", status.PGN)
+	}
+
+	if text, isErr := callTool(t, gm.handleMakeMove, map[string]any{"game_id": status.GameID, "move": "e4"}); isErr {
+		t.Fatal(text)
+	}
+	if !slices.Contains(commandLog(t, engine), "setoption name UCI_Chess960 value true") {
+		t.Errorf("engine not switched to Chess960:\n%s", strings.Join(commandLog(t, engine), "\n"))
+	}
+}
+
+func TestGamesHoldEngineSlots(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{BestMove: "e7e5"}))
+	config.MaxSessions = 2
+	config.QueueMaxWait = 0
+	engines := newEngineRegistry(config, zerolog.Nop())
+	gm := newGameManager(config, engines, nil, zerolog.Nop())
+	t.Cleanup(gm.Close)
+
+	release, err := engines.reserve(context.Background(), 1, PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	text, isErr := callTool(t, gm.handleNewGame, map[string]any{"depth": 1})
+	if isErr {
+		t.Fatal(text)
+	}
+	id := decode[GameStatus](t, text).GameID
+	if free := engines.slots.free(); free != 0 {
+		t.Errorf("%d engine slots free with a game running", free)
+	}
+
+	// A finished game keeps its slot, since undoing moves restarts the
+	// engine; only removing the game frees it.
+	if text, isErr := callTool(t, gm.handleResign, map[string]any{"game_id": id}); isErr {
+		t.Fatal(text)
+	}
+	text, isErr = callTool(t, gm.handleNewGame, map[string]any{"depth": 1})
+	if !isErr || !strings.Contains(text, "maximum number of engines (2) running") {
+		t.Errorf("game beside a game and a batch: %s", text)
+	}
+	gm.remove(id)
+	if text, isErr := callTool(t, gm.handleNewGame, map[string]any{"depth": 1}); isErr {
+		t.Errorf("game after removing the first: %s", text)
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	defaultPuzzleDepth    = 14
+	defaultPuzzleMaxMoves = 4
+	defaultMaxPuzzles     = 20
+)
+
+// A position is a puzzle when the best move keeps at least
+// puzzleMinWinPercent winning chances and the second best move drops them by
+// puzzleUniqueGap or more. The same gap decides whether later solution moves
+// are still unique.
+const (
+	puzzleMinWinPercent = 70.0
+	puzzleUniqueGap     = 30.0
+)
+
+// Puzzle themes, besides "mate-in-N".
+const (
+	ThemeFork      = "fork"
+	ThemePin       = "pin"
+	ThemePromotion = "promotion"
+)
+
+const (
+	PuzzleFormatJSON = "json"
+	PuzzleFormatEPD  = "epd"
+)
+
+type Puzzle struct {
+	ID   string `json:"id"`
+	Game int    `json:"game"`
+	// Ply is the number of half-moves played in the game before the puzzle
+	// position.
+	Ply         int      `json:"ply"`
+	FEN         string   `json:"fen"`
+	Solver      string   `json:"solver"`
+	Solution    []string `json:"solution"`
+	SolutionSAN []string `json:"solution_san"`
+	// PlayedSAN is the move actually played in the game.
+	PlayedSAN string   `json:"played_san,omitempty"`
+	Themes    []string `json:"themes"`
+	Best      MoveEval `json:"best"`
+	// SecondBest is the strongest alternative, absent for forced positions.
+	SecondBest *MoveEval `json:"second_best,omitempty"`
+	White      string    `json:"white,omitempty"`
+	Black      string    `json:"black,omitempty"`
+	Event      string    `json:"event,omitempty"`
+}
+
+type PuzzleResult struct {
+	Status    string   `json:"status"`
+	Games     int      `json:"games"`
+	Positions int      `json:"positions_scanned"`
+	Puzzles   []Puzzle `json:"puzzles"`
+	Errors    []string `json:"errors,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type PuzzleConfig struct {
+	Engine     string
+	Depth      int
+	MoveTimeMS int
+	// MaxMoves is the longest solution, counted in the solver's moves.
+	MaxMoves   int
+	MaxPuzzles int
+}
+
+func (cfg *PuzzleConfig) applyDefaults() {
+	if cfg.Depth <= 0 && cfg.MoveTimeMS <= 0 {
+		cfg.Depth = defaultPuzzleDepth
+	}
+	if cfg.MaxMoves <= 0 {
+		cfg.MaxMoves = defaultPuzzleMaxMoves
+	}
+	if cfg.MaxPuzzles <= 0 {
+		cfg.MaxPuzzles = defaultMaxPuzzles
+	}
+}
+
+// GeneratePuzzles scans every position of the games with a two-line search
+// and turns those with a single winning move into puzzles. The search stops
+// early, with the puzzles found so far, when ctx is cancelled.
+func (a *Analyzer) GeneratePuzzles(
+	ctx context.Context,
+	games []PGNGame,
+	cfg PuzzleConfig,
+) (*PuzzleResult, error) {
+	cfg.applyDefaults()
+
+	engine, err := a.engines.Get(cfg.Engine)
+	if err != nil {
+		return nil, err
+	}
+	engine.Options = append(
+		append([]EngineOptionValue(nil), engine.Options...),
+		EngineOptionValue{Name: "MultiPV", Value: "2"},
+	)
+	release, err := a.engines.reserve(ctx, 1, PriorityBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	player := &enginePlayer{config: engine, logger: a.logger}
+	defer player.close()
+
+	result := &PuzzleResult{Status: "success", Games: len(games), Puzzles: []Puzzle{}}
+	search := func(pos *Position) (*SearchInfo, error) {
+		info, err := player.search(
+			ctx,
+			newGame(pos).PositionCommand(),
+			analysisLimits(cfg.Depth, cfg.MoveTimeMS),
+			a.timeout(cfg.MoveTimeMS),
+		)
+		if err != nil {
+			return nil, err
+		}
+		info.fillWDL(pos)
+		return info, nil
+	}
+
+	for gi, pgn := range games {
+		game, err := pgn.Replay()
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("game %d: %s", gi+1, err))
+			continue
+		}
+
+		pos := game.Start()
+		for ply, played := range game.Moves() {
+			if err := ctx.Err(); err != nil {
+				result.Status = "partial"
+				result.Error = err.Error()
+				return result, nil
+			}
+			if len(result.Puzzles) >= cfg.MaxPuzzles {
+				return result, nil
+			}
+
+			if len(pos.LegalMoves()) > 1 {
+				result.Positions++
+				puzzle, err := a.findPuzzle(pos, search, cfg.MaxMoves)
+				if err != nil {
+					result.Errors = append(result.Errors,
+						fmt.Sprintf("game %d, ply %d: %s", gi+1, ply, err))
+				} else if puzzle != nil {
+					puzzle.ID = fmt.Sprintf("g%d-p%d", gi+1, ply)
+					puzzle.Game = gi + 1
+					puzzle.Ply = ply
+					puzzle.PlayedSAN = pos.SAN(played)
+					puzzle.White = pgn.Tags["White"]
+					puzzle.Black = pgn.Tags["Black"]
+					puzzle.Event = pgn.Tags["Event"]
+					result.Puzzles = append(result.Puzzles, *puzzle)
+				}
+			}
+			pos = pos.Play(played)
+		}
+	}
+	return result, nil
+}
+
+// findPuzzle returns the puzzle starting at pos, or nil if the position has
+// no unique winning move. The solution follows the engine's line while each
+// solver move stays unique, and always ends with a solver move.
+func (a *Analyzer) findPuzzle(
+	start *Position,
+	search func(*Position) (*SearchInfo, error),
+	maxMoves int,
+) (*Puzzle, error) {
+	info, err := search(start)
+	if err != nil {
+		return nil, err
+	}
+	best, second, ok := uniqueBest(info)
+	if !ok || winPercent(best) < puzzleMinWinPercent {
+		return nil, nil
+	}
+
+	puzzle := &Puzzle{
+		FEN:         start.FEN(),
+		Solver:      start.Turn().String(),
+		Solution:    []string{},
+		SolutionSAN: []string{},
+	}
+	bestMove, err := start.ParseUCI(info.BestMove)
+	if err != nil {
+		return nil, fmt.Errorf("engine returned an illegal move: %w", err)
+	}
+	puzzle.Best = moveEval(start, bestMove, best)
+	if second != nil && len(second.PV) > 0 {
+		if m, err := start.ParseUCI(second.PV[0]); err == nil {
+			eval := moveEval(start, m, second)
+			puzzle.SecondBest = &eval
+		}
+	}
+
+	var (
+		themes = map[string]bool{}
+		pos    = start
+		line   = best
+	)
+	for moves := 0; ; {
+		m, err := pos.ParseUCI(line.PV[0])
+		if err != nil {
+			break
+		}
+		puzzle.addMove(pos, m)
+		collectThemes(themes, pos, m)
+		pos = pos.Play(m)
+		moves++
+
+		if moves >= maxMoves || len(pos.LegalMoves()) == 0 {
+			break
+		}
+
+		// The opponent answers with the engine's expected reply.
+		reply := ""
+		if len(line.PV) > 1 {
+			reply = line.PV[1]
+		} else {
+			info, err := search(pos)
+			if err != nil {
+				return nil, err
+			}
+			reply = info.BestMove
+		}
+		r, err := pos.ParseUCI(reply)
+		if err != nil {
+			break
+		}
+		next := pos.Play(r)
+		if len(next.LegalMoves()) == 0 {
+			break
+		}
+
+		info, err := search(next)
+		if err != nil {
+			return nil, err
+		}
+		nextBest, _, ok := uniqueBest(info)
+		if !ok || winPercent(nextBest) < puzzleMinWinPercent {
+			break
+		}
+		puzzle.addMove(pos, r)
+		pos, line = next, nextBest
+	}
+
+	if pos.InCheck() && len(pos.LegalMoves()) == 0 {
+		puzzle.Themes = append(puzzle.Themes, fmt.Sprintf("mate-in-%d", (len(puzzle.Solution)+1)/2))
+	}
+	for _, theme := range []string{ThemeFork, ThemePin, ThemePromotion} {
+		if themes[theme] {
+			puzzle.Themes = append(puzzle.Themes, theme)
+		}
+	}
+	if puzzle.Themes == nil {
+		puzzle.Themes = []string{}
+	}
+	return puzzle, nil
+}
+
+func (p *Puzzle) addMove(pos *Position, m Move) {
+	p.Solution = append(p.Solution, pos.UCI(m))
+	p.SolutionSAN = append(p.SolutionSAN, pos.SAN(m))
+}
+
+// uniqueBest returns the two best lines of a MultiPV 2 search and whether
+// the best one is clearly better than the alternative. A position with a
+// single scored line counts as unique.
+func uniqueBest(info *SearchInfo) (*InfoLine, *InfoLine, bool) {
+	best := info.Best()
+	if best == nil || len(best.PV) == 0 {
+		return nil, nil, false
+	}
+	var second *InfoLine
+	for i := range info.Lines {
+		if info.Lines[i].MultiPV == 2 {
+			second = &info.Lines[i]
+		}
+	}
+	if second == nil {
+		return best, nil, true
+	}
+	return best, second, winPercent(best)-winPercent(second) >= puzzleUniqueGap
+}
+
+// collectThemes records the tactical motifs of the solver's move m.
+func collectThemes(themes map[string]bool, pos *Position, m Move) {
+	if m.Promotion != NoPieceType {
+		themes[ThemePromotion] = true
+	}
+
+	next := pos.Play(m)
+	solver, opponent := pos.Turn(), pos.Turn().Other()
+	mover := next.PieceAt(m.To)
+
+	// A fork attacks two or more pieces that are either worth more than
+	// the forking piece, undefended, or the king.
+	targets := 0
+	for i, piece := range next.board {
+		sq := Square(i)
+		if piece == NoPiece || piece.Color() != opponent || piece.Type() == Pawn {
+			continue
+		}
+		if !containsSquare(next.attackers(sq, solver), m.To) {
+			continue
+		}
+		if piece.Type() == King ||
+			pieceValues[piece.Type()] > pieceValues[mover.Type()] ||
+			!next.isAttacked(sq, opponent) {
+			targets++
+		}
+	}
+	if targets >= 2 {
+		themes[ThemeFork] = true
+	}
+
+	before := map[Pin]bool{}
+	for _, pin := range pos.pins() {
+		before[pin] = true
+	}
+	solverPrefix := solver.String() + " "
+	for _, pin := range next.pins() {
+		if !before[pin] && strings.HasPrefix(pin.By, solverPrefix) {
+			themes[ThemePin] = true
+		}
+	}
+}
+
+func containsSquare(squares []Square, sq Square) bool {
+	for _, s := range squares {
+		if s == sq {
+			return true
+		}
+	}
+	return false
+}
+
+// formatPuzzlesEPD writes one EPD record per puzzle: the first solution move
+// as "bm", the full line as "pv" and the themes as the "c0" comment.
+func formatPuzzlesEPD(puzzles []Puzzle) string {
+	var sb strings.Builder
+	for _, p := range puzzles {
+		fields := strings.Fields(p.FEN)
+		sb.WriteString(strings.Join(fields[:4], " "))
+		fmt.Fprintf(&sb, " bm %s;", p.SolutionSAN[0])
+		fmt.Fprintf(&sb, " pv %s;", strings.Join(p.SolutionSAN, " "))
+		fmt.Fprintf(&sb, " id %s;", strconv.Quote(p.ID))
+		if len(p.Themes) > 0 {
+			fmt.Fprintf(&sb, " c0 %s;", strconv.Quote(strings.Join(p.Themes, " ")))
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func (a *Analyzer) handleGeneratePuzzles(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	text, err := request.RequireString("pgn")
+	if err != nil {
+		return mcp.NewToolResultError("Missing 'pgn' parameter"), nil
+	}
+	format := request.GetString("format", PuzzleFormatJSON)
+	if format != PuzzleFormatJSON && format != PuzzleFormatEPD {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown format %q: use json or epd", format)), nil
+	}
+
+	games, err := parsePGN(text)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid PGN: %s", err.Error())), nil
+	}
+	if len(games) == 0 {
+		return mcp.NewToolResultError("No games in PGN"), nil
+	}
+
+	cfg := PuzzleConfig{
+		Engine:     request.GetString("engine", ""),
+		Depth:      request.GetInt("depth", 0),
+		MoveTimeMS: request.GetInt("movetime", 0),
+		MaxMoves:   request.GetInt("max_moves", 0),
+		MaxPuzzles: request.GetInt("max_puzzles", 0),
+	}
+
+	a.logger.Info().
+		Int("games", len(games)).
+		Str("engine", cfg.Engine).
+		Int("depth", cfg.Depth).
+		Str("format", format).
+		Msg("Received puzzle generation request")
+
+	result, err := a.GeneratePuzzles(ctx, games, cfg)
+	if err != nil {
+		a.logger.Warn().Err(err).Msg("Puzzle generation failed")
+		return mcp.NewToolResultError(fmt.Sprintf("Puzzle generation failed: %s", err.Error())), nil
+	}
+
+	a.logger.Info().
+		Int("positions", result.Positions).
+		Int("puzzles", len(result.Puzzles)).
+		Msg("Puzzle generation finished")
+
+	if format == PuzzleFormatEPD {
+		return mcp.NewToolResultText(formatPuzzlesEPD(result.Puzzles)), nil
+	}
+	return jsonToolResult(result, a.logger)
+}
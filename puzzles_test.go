@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestGeneratePuzzlesRequiresUniqueMove(t *testing.T) {
+	games, err := parsePGN(`[White "A"]
+[Black "B"]
+
+1. e4 *`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		info   string
+		puzzle bool
+	}{
+		{
+			name:   "only move that wins",
+			info:   "info depth 4 multipv 1 score cp 900 pv e2e4;info depth 4 multipv 2 score cp 0 pv d2d4",
+			puzzle: true,
+		},
+		{
+			name: "second move wins too",
+			info: "info depth 4 multipv 1 score cp 900 pv e2e4;info depth 4 multipv 2 score cp 700 pv d2d4",
+		},
+		{
+			name: "best move does not win",
+			info: "info depth 4 multipv 1 score cp 100 pv e2e4;info depth 4 multipv 2 score cp -500 pv d2d4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(fakeEngine(t, ucitest.Script{Info: tt.info}))
+			analyzer := newAnalyzer(newEngineRegistry(config, zerolog.Nop()), config.MaxSessions, config.CommandTimeout, zerolog.Nop())
+
+			result, err := analyzer.GeneratePuzzles(context.Background(), games, PuzzleConfig{Depth: 4, MaxMoves: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Positions != 1 || len(result.Errors) != 0 {
+				t.Fatalf("result = %+v", result)
+			}
+			if !tt.puzzle {
+				if len(result.Puzzles) != 0 {
+					t.Errorf("puzzles = %+v", result.Puzzles)
+				}
+				return
+			}
+			if len(result.Puzzles) != 1 {
+				t.Fatalf("puzzles = %+v", result.Puzzles)
+			}
+			p := result.Puzzles[0]
+			if p.Solver != "white" || len(p.SolutionSAN) != 1 || p.SolutionSAN[0] != "e4" ||
+				p.SecondBest == nil || p.SecondBest.SAN != "d4" || p.White != "A" {
+				t.Errorf("puzzle = %+v", p)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// queuePollInterval makes waiting requests re-check for idle sessions that
+// became evictable, which no session event announces.
+const queuePollInterval = time.Second
+
+// QueueWait tells a caller how it got its session out of the wait queue.
+type QueueWait struct {
+	Priority Priority `json:"priority"`
+	// Position is the 1-based place the request took when it joined.
+	Position int   `json:"position"`
+	WaitMS   int64 `json:"wait_ms"`
+}
+
+// QueueMetrics are the session queue counters since startup, plus its
+// current length.
+type QueueMetrics struct {
+	Length      int     `json:"length"`
+	Interactive int     `json:"interactive"`
+	Batch       int     `json:"batch"`
+	Enqueued    int64   `json:"enqueued"`
+	Served      int64   `json:"served"`
+	TimedOut    int64   `json:"timed_out"`
+	Rejected    int64   `json:"rejected"`
+	Evicted     int64   `json:"evicted"`
+	MaxWaitMS   int64   `json:"max_wait_ms"`
+	AvgWaitMS   float64 `json:"avg_wait_ms"`
+	totalWait   time.Duration
+	waitSamples int64
+}
+
+// sessionWaiter is a queued request for engine slots: one for a session
+// of engine, or need of them for engines started outside the pool, whose
+// engine is the zero value.
+type sessionWaiter struct {
+	engine   EngineConfig
+	need     int
+	priority Priority
+	// ready is signalled, without blocking, whenever a slot may have freed.
+	ready chan struct{}
+}
+
+func priorityRank(p Priority) int {
+	if p == PriorityBatch {
+		return 1
+	}
+	return 0
+}
+
+// The helpers below must be called with sm.mu held.
+
+func (sm *SessionManager) hasRoom(engine EngineConfig, need int) bool {
+	return sm.engines.slots.free() >= need && !sm.engineFull(engine)
+}
+
+// engineFull reports whether engine has all the sessions it may have.
+// Requests outside the pool have no engine limit.
+func (sm *SessionManager) engineFull(engine EngineConfig) bool {
+	return engine.Name != "" && sm.countEngineSessions(engine.Name) >= engine.MaxSessions
+}
+
+// enqueue inserts w after every waiter of the same or a higher priority and
+// returns its 1-based position.
+func (sm *SessionManager) enqueue(w *sessionWaiter) int {
+	i := len(sm.waiters)
+	for i > 0 && priorityRank(sm.waiters[i-1].priority) > priorityRank(w.priority) {
+		i--
+	}
+	sm.waiters = append(sm.waiters, nil)
+	copy(sm.waiters[i+1:], sm.waiters[i:])
+	sm.waiters[i] = w
+	return i + 1
+}
+
+func (sm *SessionManager) dequeue(w *sessionWaiter) {
+	for i, v := range sm.waiters {
+		if v == w {
+			sm.waiters = append(sm.waiters[:i], sm.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// waiterAhead reports whether a queued request before w (any queued request
+// when w is nil) must be served first. Waiters held back only by their own
+// engine's session limit do not block requests for other engines.
+func (sm *SessionManager) waiterAhead(w *sessionWaiter, engine EngineConfig) bool {
+	globalRoom := sm.engines.slots.free() > 0
+	for _, v := range sm.waiters {
+		if v == w {
+			return false
+		}
+		if !(globalRoom && sm.engineFull(v.engine) && v.engine.Name != engine.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyWaiters wakes every waiter to re-check for room in queue order.
+func (sm *SessionManager) notifyWaiters() {
+	for _, w := range sm.waiters {
+		select {
+		case w.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// evictIdle closes the least recently used session that has been idle for
+// EvictIdleAfter and whose removal makes room for engine. Sessions running
+// a command are never evicted.
+func (sm *SessionManager) evictIdle(engine EngineConfig) bool {
+	if sm.config.EvictIdleAfter <= 0 {
+		return false
+	}
+	sameEngineOnly := engine.Name != "" && sm.engines.slots.free() > 0
+
+	var (
+		victim   *StockfishSession
+		lastUsed time.Time
+	)
+	for _, session := range sm.sessions {
+		if sameEngineOnly && session.Engine != engine.Name {
+			continue
+		}
+		if !session.mu.TryLock() {
+			continue // busy
+		}
+		used := session.lastUsed
+		session.mu.Unlock()
+
+		if time.Since(used) < sm.config.EvictIdleAfter {
+			continue
+		}
+		if victim == nil || used.Before(lastUsed) {
+			victim, lastUsed = session, used
+		}
+	}
+	if victim == nil {
+		return false
+	}
+
+	victim.close()
+	delete(sm.sessions, victim.ID)
+	sm.queueMetrics.Evicted++
+	sm.logger.Info().
+		Str("session_id", victim.ID).
+		Str("engine", victim.Engine).
+		Dur("idle", time.Since(lastUsed)).
+		Msg("Evicted idle session to make room")
+	return true
+}
+
+func (sm *SessionManager) recordWait(wait time.Duration) {
+	m := &sm.queueMetrics
+	m.totalWait += wait
+	m.waitSamples++
+	m.MaxWaitMS = max(m.MaxWaitMS, wait.Milliseconds())
+}
+
+// acquireSlots waits until need engine slots may be taken for engine,
+// queueing the request behind those of the same or a higher priority when
+// the limits are reached, then takes as many free slots as it can, up to
+// n. It returns how many it took, with sm.mu held as it was called; the
+// caller must hand the slots on or release them.
+func (sm *SessionManager) acquireSlots(
+	ctx context.Context,
+	engine EngineConfig,
+	need, n int,
+	priority Priority,
+) (int, *QueueWait, error) {
+	if capacity := sm.config.MaxSessions; need > capacity {
+		return 0, nil, fmt.Errorf("%d engines needed, but at most %d may run at once", need, capacity)
+	}
+
+	var (
+		waiter  *sessionWaiter
+		wait    *QueueWait
+		started time.Time
+		timeout *time.Timer
+		taken   int
+	)
+
+	for {
+		if sm.closed {
+			if waiter != nil {
+				sm.dequeue(waiter)
+			}
+			return 0, wait, fmt.Errorf("session manager is shut down")
+		}
+
+		if !sm.waiterAhead(waiter, engine) {
+			if !sm.hasRoom(engine, need) {
+				sm.evictIdle(engine)
+			}
+			if sm.hasRoom(engine, need) {
+				if taken = sm.engines.slots.take(need, n); taken > 0 {
+					break
+				}
+			}
+		}
+
+		if waiter == nil {
+			if sm.config.QueueMaxLength == 0 || sm.config.QueueMaxWait == 0 {
+				return 0, nil, sm.capacityError(engine)
+			}
+			if len(sm.waiters) >= sm.config.QueueMaxLength {
+				sm.queueMetrics.Rejected++
+				return 0, nil, fmt.Errorf(
+					"%w; session queue is full (%d waiting)",
+					sm.capacityError(engine),
+					len(sm.waiters),
+				)
+			}
+
+			waiter = &sessionWaiter{
+				engine:   engine,
+				need:     need,
+				priority: priority,
+				ready:    make(chan struct{}, 1),
+			}
+			wait = &QueueWait{Priority: priority, Position: sm.enqueue(waiter)}
+			started = time.Now()
+			timeout = time.NewTimer(sm.config.QueueMaxWait)
+			defer timeout.Stop()
+			sm.queueMetrics.Enqueued++
+
+			sm.logger.Info().
+				Str("engine", engine.Name).
+				Int("slots", need).
+				Str("priority", string(priority)).
+				Int("position", wait.Position).
+				Int("queue_length", len(sm.waiters)).
+				Msg("Session limit reached, request queued")
+		}
+
+		// Engines outside the pool free their slots without notifying
+		// waiters.
+		slotFreed := sm.engines.slots.wait()
+		sm.mu.Unlock()
+		var timedOut, cancelled bool
+		select {
+		case <-waiter.ready:
+		case <-slotFreed:
+		case <-time.After(queuePollInterval):
+		case <-timeout.C:
+			timedOut = true
+		case <-ctx.Done():
+			cancelled = true
+		}
+		sm.mu.Lock()
+
+		if timedOut || cancelled {
+			sm.dequeue(waiter)
+			waited := time.Since(started)
+			wait.WaitMS = waited.Milliseconds()
+			sm.recordWait(waited)
+			sm.notifyWaiters()
+			if cancelled {
+				return 0, wait, ctx.Err()
+			}
+			sm.queueMetrics.TimedOut++
+			what := "session"
+			if engine.Name == "" {
+				what = "engine"
+			}
+			return 0, wait, fmt.Errorf(
+				"%w; no %s freed up within %v (queued at position %d)",
+				sm.capacityError(engine),
+				what,
+				sm.config.QueueMaxWait,
+				wait.Position,
+			)
+		}
+	}
+
+	if waiter != nil {
+		sm.dequeue(waiter)
+		waited := time.Since(started)
+		wait.WaitMS = waited.Milliseconds()
+		sm.queueMetrics.Served++
+		sm.recordWait(waited)
+		// Several slots may have freed at once.
+		sm.notifyWaiters()
+
+		sm.logger.Info().
+			Str("engine", engine.Name).
+			Int("slots", taken).
+			Int("position", wait.Position).
+			Int64("wait_ms", wait.WaitMS).
+			Msg("Queued request got a session")
+	}
+	return taken, wait, nil
+}
+
+// reserveSlots takes between need and n engine slots for engines started
+// outside the session pool, waiting in the session queue at the given
+// priority.
+func (sm *SessionManager) reserveSlots(ctx context.Context, need, n int, priority Priority) (int, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	taken, _, err := sm.acquireSlots(ctx, EngineConfig{}, need, n, priority)
+	return taken, err
+}
+
+func (sm *SessionManager) capacityError(engine EngineConfig) error {
+	if engine.Name == "" {
+		return sm.engines.slots.fullError()
+	}
+	if sm.engines.slots.free() <= 0 {
+		return fmt.Errorf("maximum number of sessions (%d) reached", sm.config.MaxSessions)
+	}
+	return fmt.Errorf(
+		"maximum number of sessions (%d) reached for engine %q",
+		engine.MaxSessions,
+		engine.Name,
+	)
+}
+
+// QueueMetrics returns a snapshot of the session queue counters.
+func (sm *SessionManager) QueueMetrics() QueueMetrics {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	m := sm.queueMetrics
+	m.Length = len(sm.waiters)
+	for _, w := range sm.waiters {
+		if w.priority == PriorityBatch {
+			m.Batch++
+		} else {
+			m.Interactive++
+		}
+	}
+	if m.waitSamples > 0 {
+		m.AvgWaitMS = float64(m.totalWait.Milliseconds()) / float64(m.waitSamples)
+	}
+	return m
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+// QuotaStatus is reported in the "_meta.quota" of every tool result when
+// limits are enabled.
+type QuotaStatus struct {
+	Client string `json:"client"`
+	// RequestsRemaining is the number of calls that may be made right away.
+	RequestsRemaining *int `json:"requests_remaining,omitempty"`
+	// EngineSecondsRemaining is what is left of the engine time budget in
+	// the current window; EngineResetMS is when the oldest use leaves it.
+	EngineSecondsRemaining *float64 `json:"engine_seconds_remaining,omitempty"`
+	EngineResetMS          *int64   `json:"engine_reset_ms,omitempty"`
+}
+
+// engineUse is engine time charged to a client.
+type engineUse struct {
+	at       time.Time
+	duration time.Duration
+}
+
+type clientQuota struct {
+	tokens float64
+	filled time.Time
+	usage  []engineUse
+}
+
+// RateLimiter enforces per-client limits on tool calls: a token bucket on
+// the number of calls and a rolling budget of engine time. Engine time is
+// the search time of the engines a call used, added up over engines that
+// searched in parallel; calls that did not search cost none.
+type RateLimiter struct {
+	config RateLimitConfig
+	now    func() time.Time
+	logger zerolog.Logger
+
+	mu        sync.Mutex
+	clients   map[string]*clientQuota
+	lastSweep time.Time
+}
+
+func newRateLimiter(config RateLimitConfig, logger zerolog.Logger) *RateLimiter {
+	return &RateLimiter{
+		config:  config,
+		now:     time.Now,
+		logger:  logger.With().Str("component", ComponentRateLimiter).Logger(),
+		clients: make(map[string]*clientQuota),
+	}
+}
+
+func (l *RateLimiter) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client := clientIdentity(ctx)
+		if err := l.admit(ctx, client); err != nil {
+			l.logger.Warn().Err(err).Str("client", client).Str("tool", request.Params.Name).Msg("Tool call rejected")
+			result := mcp.NewToolResultError(err.Error())
+			result.Meta = map[string]any{"quota": l.status(client)}
+			return result, nil
+		}
+
+		started := l.now()
+		ctx, meter := withEngineMeter(ctx)
+		result, err := next(ctx, request)
+		l.charge(client, started, meter.total())
+
+		if result != nil {
+			if result.Meta == nil {
+				result.Meta = make(map[string]any)
+			}
+			result.Meta["quota"] = l.status(client)
+		}
+		return result, err
+	}
+}
+
+// admit takes a request token and checks the engine time budget. A client
+// out of tokens waits up to MaxWait for the next one.
+func (l *RateLimiter) admit(ctx context.Context, client string) error {
+	l.mu.Lock()
+	q := l.quota(client)
+
+	if budget := l.config.EngineTime; budget > 0 {
+		if used := l.used(q); used >= budget {
+			reset := l.resetIn(q)
+			l.mu.Unlock()
+			return fmt.Errorf(
+				"engine time quota exhausted: %s used of %s per %s, retry in %s",
+				used.Round(time.Second),
+				budget,
+				l.config.EngineTimeWindow,
+				reset.Round(time.Second),
+			)
+		}
+	}
+
+	if l.config.Rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	l.refill(q)
+	if q.tokens >= 1 {
+		q.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+	wait := time.Duration((1 - q.tokens) / l.config.Rate * float64(time.Second))
+	if wait > l.config.MaxWait {
+		l.mu.Unlock()
+		return fmt.Errorf(
+			"rate limit exceeded: %g requests per minute, retry in %s",
+			l.config.Rate*60,
+			wait.Round(time.Millisecond),
+		)
+	}
+	// Take the token now so concurrent requests queue behind this one.
+	q.tokens--
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		q.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (l *RateLimiter) charge(client string, at time.Time, d time.Duration) {
+	if l.config.EngineTime <= 0 || d <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	q := l.quota(client)
+	q.usage = append(q.usage, engineUse{at: at, duration: d})
+}
+
+func (l *RateLimiter) status(client string) QuotaStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	q := l.quota(client)
+	status := QuotaStatus{Client: client}
+	if l.config.Rate > 0 {
+		l.refill(q)
+		remaining := max(int(math.Floor(q.tokens)), 0)
+		status.RequestsRemaining = &remaining
+	}
+	if budget := l.config.EngineTime; budget > 0 {
+		remaining := max(budget-l.used(q), 0).Seconds()
+		reset := l.resetIn(q).Milliseconds()
+		status.EngineSecondsRemaining = &remaining
+		status.EngineResetMS = &reset
+	}
+	return status
+}
+
+// quota returns the state of a client, dropping idle clients from time to
+// time. It must be called with l.mu held.
+func (l *RateLimiter) quota(client string) *clientQuota {
+	now := l.now()
+	if now.Sub(l.lastSweep) > l.idleAfter() {
+		for name, q := range l.clients {
+			l.refill(q)
+			if l.used(q) == 0 && (l.config.Rate <= 0 || q.tokens >= float64(l.config.Burst)) {
+				delete(l.clients, name)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	q, ok := l.clients[client]
+	if !ok {
+		q = &clientQuota{tokens: float64(l.config.Burst), filled: now}
+		l.clients[client] = q
+	}
+	return q
+}
+
+func (l *RateLimiter) idleAfter() time.Duration {
+	if l.config.EngineTimeWindow > 0 {
+		return l.config.EngineTimeWindow
+	}
+	return time.Minute
+}
+
+func (l *RateLimiter) refill(q *clientQuota) {
+	now := l.now()
+	q.tokens = math.Min(float64(l.config.Burst), q.tokens+now.Sub(q.filled).Seconds()*l.config.Rate)
+	q.filled = now
+}
+
+// used sums the engine time charged within the window, forgetting older
+// uses.
+func (l *RateLimiter) used(q *clientQuota) time.Duration {
+	since := l.now().Add(-l.config.EngineTimeWindow)
+	for len(q.usage) > 0 && !q.usage[0].at.After(since) {
+		q.usage = q.usage[1:]
+	}
+	var total time.Duration
+	for _, use := range q.usage {
+		total += use.duration
+	}
+	return total
+}
+
+// resetIn is the time until the oldest use in the window expires.
+func (l *RateLimiter) resetIn(q *clientQuota) time.Duration {
+	if len(q.usage) == 0 {
+		return 0
+	}
+	return q.usage[0].at.Add(l.config.EngineTimeWindow).Sub(l.now())
+}
+
+// engineMeter adds up the engine time spent on behalf of one tool call.
+type engineMeter struct {
+	mu   sync.Mutex
+	used time.Duration
+}
+
+type engineMeterKey struct{}
+
+func withEngineMeter(ctx context.Context) (context.Context, *engineMeter) {
+	meter := &engineMeter{}
+	return context.WithValue(ctx, engineMeterKey{}, meter), meter
+}
+
+func (m *engineMeter) total() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used
+}
+
+// chargeSearch adds a search to the meter of the tool call in ctx, if any.
+// The search takes the time its engine reported last, or elapsed for
+// engines that report none.
+func chargeSearch(ctx context.Context, lines []string, elapsed time.Duration) {
+	meter, ok := ctx.Value(engineMeterKey{}).(*engineMeter)
+	if !ok {
+		return
+	}
+	var reported time.Duration
+	for _, line := range lines {
+		if info, ok := uci.ParseInfo(line); ok && info.Time > reported {
+			reported = info.Time
+		}
+	}
+	if reported == 0 {
+		reported = elapsed
+	}
+	meter.mu.Lock()
+	meter.used += reported
+	meter.mu.Unlock()
+}
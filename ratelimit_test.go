@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func callLimited(t *testing.T, handle func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), client string) (*mcp.CallToolResult, QuotaStatus) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Name = "chess_engine"
+	ctx := withPrincipal(context.Background(), &Principal{Name: client})
+	result, err := handle(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _ := result.Meta["quota"].(QuotaStatus)
+	return result, status
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	limiter := newRateLimiter(RateLimitConfig{Rate: 1, Burst: 2}, zerolog.Nop())
+	limiter.now = clock.now
+	handle := limiter.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	for i, want := range []int{1, 0} {
+		result, status := callLimited(t, handle, "alice")
+		if result.IsError || *status.RequestsRemaining != want {
+			t.Fatalf("call %d: %+v, %d remaining", i, result, *status.RequestsRemaining)
+		}
+	}
+	result, _ := callLimited(t, handle, "alice")
+	if text := toolResultText(result); !result.IsError || !strings.Contains(text, "rate limit exceeded") {
+		t.Errorf("over the limit: %s", text)
+	}
+	if result, _ := callLimited(t, handle, "bob"); result.IsError {
+		t.Error("bob limited by alice's calls")
+	}
+
+	clock.advance(time.Second)
+	if result, _ := callLimited(t, handle, "alice"); result.IsError {
+		t.Errorf("after refill: %s", toolResultText(result))
+	}
+}
+
+func TestRateLimitQueuesShortWaits(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{Rate: 20, Burst: 1, MaxWait: time.Second}, zerolog.Nop())
+	handle := limiter.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		if result, _ := callLimited(t, handle, "alice"); result.IsError {
+			t.Fatalf("call %d: %s", i, toolResultText(result))
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Errorf("3 calls at 20/s took %v", elapsed)
+	}
+}
+
+func TestEngineTimeQuota(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	limiter := newRateLimiter(RateLimitConfig{EngineTime: time.Minute, EngineTimeWindow: time.Hour}, zerolog.Nop())
+	limiter.now = clock.now
+	handle := limiter.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		clock.advance(40 * time.Second)
+		chargeSearch(ctx, []string{"info depth 30 time 40000 pv e2e4", "bestmove e2e4"}, 0)
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	_, status := callLimited(t, handle, "alice")
+	if *status.EngineSecondsRemaining != 20 || *status.EngineResetMS != 3600_000-40_000 {
+		t.Errorf("after one call: %+v", status)
+	}
+	callLimited(t, handle, "alice")
+	result, status := callLimited(t, handle, "alice")
+	if text := toolResultText(result); !result.IsError || !strings.Contains(text, "engine time quota exhausted") {
+		t.Errorf("over budget: %s", text)
+	}
+	if *status.EngineSecondsRemaining != 0 {
+		t.Errorf("remaining = %v", *status.EngineSecondsRemaining)
+	}
+
+	clock.advance(time.Hour)
+	if result, _ := callLimited(t, handle, "alice"); result.IsError {
+		t.Errorf("after the window: %s", toolResultText(result))
+	}
+}
+
+func TestEngineTimeChargesSearches(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{SearchTime: 100 * time.Millisecond})
+	config := testConfig(engine)
+	sm := newTestSessionManager(t, config)
+	analyzer := newAnalyzer(sm.engines, config.MaxSessions, config.CommandTimeout, zerolog.Nop())
+	limiter := newRateLimiter(RateLimitConfig{EngineTime: time.Hour, EngineTimeWindow: time.Hour}, zerolog.Nop())
+
+	remaining := func(handle server.ToolHandlerFunc) time.Duration {
+		t.Helper()
+		result, status := callLimited(t, limiter.middleware(handle), "alice")
+		if result.IsError {
+			t.Fatal(toolResultText(result))
+		}
+		return time.Hour - time.Duration(*status.EngineSecondsRemaining*float64(time.Second))
+	}
+
+	// Waiting without searching costs nothing.
+	used := remaining(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		time.Sleep(50 * time.Millisecond)
+		return mcp.NewToolResultText("ok"), nil
+	})
+	if used != 0 {
+		t.Errorf("call without a search charged %v", used)
+	}
+
+	// Four engines searching in parallel are each charged their search
+	// time, which adds up to more than the call took.
+	var elapsed time.Duration
+	used = remaining(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		items := make([]BatchItem, 4)
+		for i := range items {
+			items[i] = BatchItem{FEN: startFEN}
+		}
+		started := time.Now()
+		result, err := analyzer.AnalyzeBatch(ctx, items, BatchConfig{Depth: 1})
+		elapsed = time.Since(started)
+		if err != nil || result.Workers != 4 || result.Succeeded != 4 {
+			t.Errorf("batch: %+v, %v", result, err)
+		}
+		return mcp.NewToolResultText("ok"), nil
+	})
+	if used < 400*time.Millisecond || used <= elapsed {
+		t.Errorf("batch of 4 searches of 100ms in %v charged %v", elapsed, used)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+)
+
+const (
+	RenderASCII   = "ascii"
+	RenderUnicode = "unicode"
+	RenderSVG     = "svg"
+)
+
+// RenderOptions controls how a board diagram is drawn.
+type RenderOptions struct {
+	Flipped     bool // Black at the bottom
+	Coordinates bool
+	LastMove    *Move
+	LastMoveSAN string // used in text diagrams when known
+	BestMove    *Move
+}
+
+var unicodePieces = map[byte]string{
+	'K': "♔", 'Q': "♕", 'R': "♖", 'B': "♗", 'N': "♘", 'P': "♙",
+	'k': "♚", 'q': "♛", 'r': "♜", 'b': "♝", 'n': "♞", 'p': "♟",
+}
+
+// boardSquares returns the squares in drawing order, top-left first.
+func boardSquares(flipped bool) [8][8]Square {
+	var rows [8][8]Square
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if flipped {
+				rows[row][col] = newSquare(7-col, row)
+			} else {
+				rows[row][col] = newSquare(col, 7-row)
+			}
+		}
+	}
+	return rows
+}
+
+func fileLabels(flipped bool) []string {
+	labels := strings.Split("abcdefgh", "")
+	if flipped {
+		for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+			labels[i], labels[j] = labels[j], labels[i]
+		}
+	}
+	return labels
+}
+
+// renderText draws the position as a text diagram. Arrows cannot be drawn
+// in text, so the last and best moves and any check are listed below it.
+func renderText(p *Position, opts RenderOptions, unicode bool) string {
+	var sb strings.Builder
+	if opts.Coordinates {
+		sb.WriteString("  +-----------------+\n")
+	}
+	for _, row := range boardSquares(opts.Flipped) {
+		if opts.Coordinates {
+			fmt.Fprintf(&sb, "%d | ", row[0].Rank()+1)
+		}
+		cells := make([]string, 0, 8)
+		for _, sq := range row {
+			piece := p.PieceAt(sq)
+			switch {
+			case piece == NoPiece && unicode:
+				cells = append(cells, "·")
+			case piece == NoPiece:
+				cells = append(cells, ".")
+			case unicode:
+				cells = append(cells, unicodePieces[piece.FENChar()])
+			default:
+				cells = append(cells, string(piece.FENChar()))
+			}
+		}
+		sb.WriteString(strings.Join(cells, " "))
+		if opts.Coordinates {
+			sb.WriteString(" |")
+		}
+		sb.WriteString("\n")
+	}
+	if opts.Coordinates {
+		sb.WriteString("  +-----------------+\n")
+		sb.WriteString("    " + strings.Join(fileLabels(opts.Flipped), " ") + "\n")
+	}
+
+	side := strings.ToUpper(p.Turn().String()[:1]) + p.Turn().String()[1:]
+	notes := []string{side + " to move"}
+	if p.InCheck() {
+		notes = append(notes, "in check")
+	}
+	switch {
+	case opts.LastMoveSAN != "":
+		notes = append(notes, "last move "+opts.LastMoveSAN)
+	case opts.LastMove != nil:
+		notes = append(notes, "last move "+opts.LastMove.From.String()+opts.LastMove.To.String())
+	}
+	if opts.BestMove != nil {
+		notes = append(notes, "best move "+p.SAN(*opts.BestMove))
+	}
+	sb.WriteString(strings.Join(notes, ", ") + "\n")
+	return sb.String()
+}
+
+const (
+	svgSquare = 45
+	svgMargin = 20
+
+	svgLight     = "#f0d9b5"
+	svgDark      = "#b58863"
+	svgLastMove  = "#cdd26a"
+	svgCheck     = "#e74c3c"
+	svgBestArrow = "#15781b"
+	svgLastArrow = "#003088"
+)
+
+// renderSVG draws the position as a standalone SVG image. Pieces are
+// Unicode glyphs so that no piece artwork has to be embedded.
+func renderSVG(p *Position, opts RenderOptions) string {
+	margin := 0
+	if opts.Coordinates {
+		margin = svgMargin
+	}
+	size := 8*svgSquare + 2*margin
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d">`,
+		size, size, size, size)
+	sb.WriteString(`<defs>`)
+	for _, color := range []string{svgBestArrow, svgLastArrow} {
+		fmt.Fprintf(&sb,
+			`<marker id="arrow-%s" markerWidth="4" markerHeight="4" refX="2" refY="2" orient="auto">`+
+				`<path d="M0,0 L4,2 L0,4 z" fill="%s"/></marker>`,
+			color[1:], color)
+	}
+	sb.WriteString(`</defs>`)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#312e2b"/>`, size, size)
+
+	center := map[Square][2]float64{}
+	checked := NoSquare
+	if p.InCheck() {
+		checked = p.kingSquare(p.Turn())
+	}
+
+	for row, squares := range boardSquares(opts.Flipped) {
+		for col, sq := range squares {
+			x, y := margin+col*svgSquare, margin+row*svgSquare
+			center[sq] = [2]float64{float64(x) + svgSquare/2.0, float64(y) + svgSquare/2.0}
+
+			fill := svgDark
+			if (sq.File()+sq.Rank())%2 == 1 {
+				fill = svgLight
+			}
+			if opts.LastMove != nil && (sq == opts.LastMove.From || sq == opts.LastMove.To) {
+				fill = svgLastMove
+			}
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+				x, y, svgSquare, svgSquare, fill)
+			if sq == checked {
+				fmt.Fprintf(&sb, `<circle cx="%d" cy="%d" r="%d" fill="%s" fill-opacity="0.7"/>`,
+					x+svgSquare/2, y+svgSquare/2, svgSquare/2, svgCheck)
+			}
+
+			if piece := p.PieceAt(sq); piece != NoPiece {
+				fmt.Fprintf(&sb,
+					`<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%s</text>`,
+					x+svgSquare/2, y+svgSquare/2, svgSquare*4/5, unicodePieces[piece.FENChar()])
+			}
+		}
+	}
+
+	if opts.Coordinates {
+		for i, label := range fileLabels(opts.Flipped) {
+			x := margin + i*svgSquare + svgSquare/2
+			fmt.Fprintf(&sb,
+				`<text x="%d" y="%d" font-size="12" fill="#e0e0e0" text-anchor="middle">%s</text>`,
+				x, size-6, label)
+		}
+		for row, squares := range boardSquares(opts.Flipped) {
+			y := margin + row*svgSquare + svgSquare/2 + 4
+			fmt.Fprintf(&sb,
+				`<text x="%d" y="%d" font-size="12" fill="#e0e0e0" text-anchor="middle">%d</text>`,
+				margin/2, y, squares[0].Rank()+1)
+		}
+	}
+
+	arrow := func(m Move, color string) {
+		from, to := center[m.From], center[m.To]
+		// Stop short of the target so the arrow head stays inside the square.
+		dx, dy := to[0]-from[0], to[1]-from[1]
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			// A Chess960 king may castle without moving.
+			return
+		}
+		shorten := svgSquare / 3.0
+		to[0] -= dx / length * shorten
+		to[1] -= dy / length * shorten
+		fmt.Fprintf(&sb,
+			`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%d" `+
+				`stroke-opacity="0.8" stroke-linecap="round" marker-end="url(#arrow-%s)"/>`,
+			from[0], from[1], to[0], to[1], color, svgSquare/5, color[1:])
+	}
+	if opts.LastMove != nil {
+		arrow(*opts.LastMove, svgLastArrow)
+	}
+	if opts.BestMove != nil {
+		arrow(kingDestination(p, *opts.BestMove), svgBestArrow)
+	}
+
+	sb.WriteString(`</svg>`)
+	return sb.String()
+}
+
+// BoardRenderer implements the render_board tool.
+type BoardRenderer struct {
+	logger zerolog.Logger
+}
+
+func newBoardRenderer(logger zerolog.Logger) *BoardRenderer {
+	return &BoardRenderer{
+		logger: logger.With().Str("component", ComponentRenderer).Logger(),
+	}
+}
+
+func (r *BoardRenderer) handle(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	fen := request.GetString("fen", startFEN)
+	moves := request.GetStringSlice("moves", nil)
+	format := strings.ToLower(request.GetString("format", RenderUnicode))
+
+	r.logger.Info().Str("fen", fen).Int("moves", len(moves)).Str("format", format).Msg("Received render request")
+
+	game, err := newGameFromMoves(fen, moves)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid position: %s", err.Error())), nil
+	}
+	pos := game.Position()
+
+	opts := RenderOptions{Coordinates: request.GetBool("coordinates", true)}
+	switch strings.ToLower(request.GetString("orientation", "white")) {
+	case "white", "w":
+	case "black", "b":
+		opts.Flipped = true
+	default:
+		return mcp.NewToolResultError("orientation must be 'white' or 'black'"), nil
+	}
+
+	if played := game.Moves(); len(played) > 0 {
+		before := game.Start()
+		for _, m := range played[:len(played)-1] {
+			before = before.Play(m)
+		}
+		last := kingDestination(before, played[len(played)-1])
+		opts.LastMove = &last
+		opts.LastMoveSAN = game.SANs()[len(played)-1]
+	}
+	if s := request.GetString("last_move", ""); s != "" {
+		m, err := lastMoveFromSquares(s)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		opts.LastMove, opts.LastMoveSAN = &m, ""
+	}
+	if s := request.GetString("best_move", ""); s != "" {
+		m, err := pos.ParseMove(s)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid best_move: %s", err.Error())), nil
+		}
+		opts.BestMove = &m
+	}
+
+	switch format {
+	case RenderASCII, RenderUnicode:
+		return mcp.NewToolResultText(renderText(pos, opts, format == RenderUnicode)), nil
+	case RenderSVG:
+		svg := renderSVG(pos, opts)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.NewTextContent(renderText(pos, opts, true)),
+				mcp.NewImageContent(base64.StdEncoding.EncodeToString([]byte(svg)), "image/svg+xml"),
+				mcp.NewEmbeddedResource(mcp.TextResourceContents{
+					URI:      "stockfish://board.svg?fen=" + url.QueryEscape(pos.FEN()),
+					MIMEType: "image/svg+xml",
+					Text:     svg,
+				}),
+			},
+		}, nil
+	}
+	return mcp.NewToolResultError("format must be 'ascii', 'unicode' or 'svg'"), nil
+}
+
+// kingDestination returns m with a castle, encoded king-takes-rook, made
+// to point at the square the king lands on, as a player would draw it.
+func kingDestination(p *Position, m Move) Move {
+	if p.isCastle(m) {
+		m.To, _ = castleTargets(m.From, m.To)
+	}
+	return m
+}
+
+// lastMoveFromSquares parses a last move given as two squares (e2e4). The
+// move was played before the position, so it cannot be checked for legality.
+func lastMoveFromSquares(s string) (Move, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 4 {
+		return Move{}, fmt.Errorf("invalid last_move %q: use UCI notation such as e2e4", s)
+	}
+	from, err := parseSquare(s[0:2])
+	if err != nil {
+		return Move{}, fmt.Errorf("invalid last_move %q: %w", s, err)
+	}
+	to, err := parseSquare(s[2:4])
+	if err != nil {
+		return Move{}, fmt.Errorf("invalid last_move %q: %w", s, err)
+	}
+	if from == to {
+		return Move{}, fmt.Errorf("invalid last_move %q: a move must change square", s)
+	}
+	return Move{From: from, To: to}, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+)
+
+var svgLinePattern = regexp.MustCompile(`<line x1="([^"]+)" y1="([^"]+)" x2="([^"]+)" y2="([^"]+)" stroke="([^"]+)"`)
+
+// renderArrows renders a board as SVG and returns its arrows as
+// "x1,y1 x2,y2 color".
+func renderArrows(t *testing.T, args map[string]any) []string {
+	t.Helper()
+	args["format"] = RenderSVG
+	args["coordinates"] = false
+	var request mcp.CallToolRequest
+	request.Params.Arguments = args
+	result, err := newBoardRenderer(zerolog.Nop()).handle(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError {
+		t.Fatalf("render error: %s", toolResultText(result))
+	}
+	svg := result.Content[2].(mcp.EmbeddedResource).Resource.(mcp.TextResourceContents).Text
+	if strings.Contains(svg, "NaN") {
+		t.Errorf("NaN in SVG:\n%s", svg)
+	}
+	var arrows []string
+	for _, m := range svgLinePattern.FindAllStringSubmatch(svg, -1) {
+		arrows = append(arrows, m[1]+","+m[2]+" "+m[3]+","+m[4]+" "+m[5])
+	}
+	return arrows
+}
+
+func TestRenderArrows(t *testing.T) {
+	// Squares are 45 pixels, so e1 is centred at (202.5, 337.5) and the
+	// head stops 15 pixels short of the target's centre.
+	tests := []struct {
+		name string
+		args map[string]any
+		want []string
+	}{
+		{
+			name: "last and best move",
+			args: map[string]any{"moves": []any{"e2e4"}, "best_move": "g8f6"},
+			want: []string{
+				"202.5,292.5 202.5,217.5 " + svgLastArrow,
+				"292.5,22.5 254.2,99.1 " + svgBestArrow,
+			},
+		},
+		{
+			name: "castling best move points at the king's square",
+			args: map[string]any{
+				"fen":       "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+				"best_move": "e1h1",
+			},
+			want: []string{"202.5,337.5 277.5,337.5 " + svgBestArrow},
+		},
+		{
+			name: "castling last move points at the king's square",
+			args: map[string]any{
+				"fen":   "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+				"moves": []any{"O-O-O"},
+			},
+			want: []string{"202.5,337.5 127.5,337.5 " + svgLastArrow},
+		},
+		{
+			name: "Chess960 king castling in place",
+			args: map[string]any{
+				"fen":       "4k3/8/8/8/8/8/8/6KR w H - 0 1",
+				"best_move": "g1h1",
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderArrows(t, tt.args)
+			if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("arrows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderRejectsNullLastMove(t *testing.T) {
+	text, isError := callTool(t, newBoardRenderer(zerolog.Nop()).handle, map[string]any{"last_move": "e2e2"})
+	if !isError || !strings.Contains(text, "must change square") {
+		t.Errorf("last_move e2e2: %q", text)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SAN formats m in Standard Algebraic Notation, including check and mate
+// suffixes.
+func (p *Position) SAN(m Move) string {
+	san := p.sanWithoutSuffix(m)
+
+	next := p.Play(m)
+	if next.InCheck() {
+		if len(next.LegalMoves()) == 0 {
+			return san + "#"
+		}
+		return san + "+"
+	}
+	return san
+}
+
+func (p *Position) sanWithoutSuffix(m Move) string {
+	piece := p.board[m.From]
+
+	if p.isCastle(m) {
+		if m.To.File() > m.From.File() {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+
+	var sb strings.Builder
+	if piece.Type() == Pawn {
+		if p.isCapture(m) {
+			sb.WriteByte(byte('a' + m.From.File()))
+			sb.WriteByte('x')
+		}
+		sb.WriteString(m.To.String())
+		if m.Promotion != NoPieceType {
+			sb.WriteByte('=')
+			sb.WriteByte(makePiece(White, m.Promotion).FENChar())
+		}
+		return sb.String()
+	}
+
+	sb.WriteByte(piece.FENChar() &^ 0x20)
+
+	sameFile, sameRank, ambiguous := false, false, false
+	for _, other := range p.LegalMoves() {
+		if other.To != m.To || other.From == m.From || p.board[other.From] != piece {
+			continue
+		}
+		ambiguous = true
+		if other.From.File() == m.From.File() {
+			sameFile = true
+		}
+		if other.From.Rank() == m.From.Rank() {
+			sameRank = true
+		}
+	}
+	if ambiguous {
+		switch {
+		case !sameFile:
+			sb.WriteByte(byte('a' + m.From.File()))
+		case !sameRank:
+			sb.WriteByte(byte('1' + m.From.Rank()))
+		default:
+			sb.WriteString(m.From.String())
+		}
+	}
+
+	if p.isCapture(m) {
+		sb.WriteByte('x')
+	}
+	sb.WriteString(m.To.String())
+	return sb.String()
+}
+
+// ParseSAN parses a move in SAN. Check/mate suffixes and annotations are
+// optional, "0-0" is accepted for castling, and a UCI move is accepted as
+// a fallback so callers can take either notation.
+func (p *Position) ParseSAN(s string) (Move, error) {
+	want := normalizeSAN(s)
+	if want == "" {
+		return Move{}, fmt.Errorf("empty move")
+	}
+
+	for _, m := range p.LegalMoves() {
+		if normalizeSAN(p.sanWithoutSuffix(m)) == want {
+			return m, nil
+		}
+	}
+	// Tolerate a missing promotion "=" (e8Q) and a missing capture "x".
+	for _, m := range p.LegalMoves() {
+		san := normalizeSAN(p.sanWithoutSuffix(m))
+		if strings.ReplaceAll(strings.ReplaceAll(san, "=", ""), "x", "") ==
+			strings.ReplaceAll(strings.ReplaceAll(want, "=", ""), "x", "") {
+			return m, nil
+		}
+	}
+
+	if m, err := p.ParseUCI(s); err == nil {
+		return m, nil
+	}
+	return Move{}, fmt.Errorf("illegal or ambiguous move %q in position %s", s, p.FEN())
+}
+
+// ParseMove accepts a move in either UCI or SAN notation.
+func (p *Position) ParseMove(s string) (Move, error) {
+	if m, err := p.ParseUCI(s); err == nil {
+		return m, nil
+	}
+	return p.ParseSAN(s)
+}
+
+func normalizeSAN(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, "+#!?")
+	s = strings.ReplaceAll(s, "0", "O")
+	s = strings.TrimSuffix(s, "e.p.")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		fen string
+		uci string
+		san string
+	}{
+		{startFEN, "g1f3", "Nf3"},
+		{startFEN, "e2e4", "e4"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/1R3KR1 w KQkq - 0 1", "f1g1", "O-O"},
+		{"1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/1R3KR1 b KQkq - 0 1", "f8b8", "O-O-O"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", "exd6"},
+		{"7k/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8q", "a8=Q+"},
+		{"7k/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8n", "a8=N"},
+		{"4k3/8/8/8/8/8/8/R4RK1 w - - 0 1", "a1d1", "Rad1"},
+		{"4k3/8/8/8/R7/8/8/R3K3 w - - 0 1", "a1a2", "R1a2"},
+		{"k7/8/8/8/8/2Q1Q3/8/2Q1K3 w - - 0 1", "c3d2", "Qc3d2"},
+		{"6k1/5ppp/8/8/8/8/8/R3K3 w - - 0 1", "a1a8", "Ra8#"},
+		{"rnbqkbnr/pppp1ppp/8/4p3/3P4/8/PPP1PPPP/RNBQKBNR w KQkq - 0 2", "d4e5", "dxe5"},
+	}
+
+	for _, tt := range tests {
+		pos, err := parseFEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := pos.ParseUCI(tt.uci)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pos.SAN(m); got != tt.san {
+			t.Errorf("%s %s: SAN %q, want %q", tt.fen, tt.uci, got, tt.san)
+		}
+		if parsed, err := pos.ParseSAN(tt.san); err != nil || parsed != m {
+			t.Errorf("%s: ParseSAN(%q) = %s, %v", tt.fen, tt.san, pos.UCI(parsed), err)
+		}
+	}
+}
+
+// TestSANRoundTrip formats every legal move of positions rich in
+// ambiguities and parses it back.
+func TestSANRoundTrip(t *testing.T) {
+	for _, fen := range []string{
+		startFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1",
+		"k7/8/8/8/8/2Q1Q3/8/2Q1K3 w - - 0 1",
+		"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9",
+	} {
+		pos, err := parseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := map[string]bool{}
+		for _, m := range pos.LegalMoves() {
+			san := pos.SAN(m)
+			if seen[san] {
+				t.Errorf("%s: SAN %q is ambiguous", fen, san)
+			}
+			seen[san] = true
+			if parsed, err := pos.ParseSAN(san); err != nil || parsed != m {
+				t.Errorf("%s: %s formats as %q, which parses as %s (%v)", fen, pos.UCI(m), san, pos.UCI(parsed), err)
+			}
+		}
+	}
+}
+
+func TestPGNRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves []string
+	}{
+		{"opening", "", []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Ba4", "Nf6", "O-O", "Be7"}},
+		{"scholar's mate", "", []string{"e2e4", "e7e5", "f1c4", "b8c6", "d1h5", "g8f6", "h5f7"}},
+		{"black to move", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 3 20", []string{"O-O-O", "O-O", "Rd2", "Rf2"}},
+		{"chess960", "1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/1R3KR1 w KQkq - 0 1", []string{"O-O", "O-O-O", "d4", "d5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game, err := newGameFromMoves(tt.fen, tt.moves)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, _ := game.Outcome()
+			text := formatPGN(game, map[string]string{
+				"Event":  `Test "quoted" event`,
+				"Result": result,
+			})
+
+			games, err := parsePGN(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(games) != 1 {
+				t.Fatalf("%d games in\n%s", len(games), text)
+			}
+			if got := games[0].Tags["Event"]; got != `Test "quoted" event` {
+				t.Errorf("Event tag %q", got)
+			}
+			if !slices.Equal(games[0].Moves, game.SANs()) {
+				t.Errorf("moves %q, want %q", games[0].Moves, game.SANs())
+			}
+
+			replayed, err := games[0].Replay()
+			if err != nil {
+				t.Fatalf("replay: %v\n%s", err, text)
+			}
+			if !slices.Equal(replayed.UCIMoves(), game.UCIMoves()) ||
+				replayed.Position().FEN() != game.Position().FEN() {
+				t.Errorf("replayed to %s, want %s", replayed.Position().FEN(), game.Position().FEN())
+			}
+		})
+	}
+}
+
+func TestParsePGN(t *testing.T) {
+	text := `[Event "First"]
+[Result "1-0"]
+
+1. e4 {best by test} e5 (1... c5 2. Nf3) 2. Nf3 $1 Nc6; a comment
+3. Bb5 1-0
+
+[Event "Second"]
+
+1. d4 d5 *
+
+1. c4 e5
+`
+	games, err := parsePGN(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"e4", "e5", "Nf3", "Nc6", "Bb5"},
+		{"d4", "d5"},
+		{"c4", "e5"},
+	}
+	if len(games) != len(want) {
+		t.Fatalf("%d games", len(games))
+	}
+	for i, g := range games {
+		if !slices.Equal(g.Moves, want[i]) {
+			t.Errorf("game %d: moves %q", i+1, g.Moves)
+		}
+	}
+	if games[0].Tags["Result"] != ResultWhiteWins || games[1].Tags["Result"] != ResultOngoing {
+		t.Errorf("results %q, %q", games[0].Tags["Result"], games[1].Tags["Result"])
+	}
+
+	if _, err := parsePGN(`[Event "Broken`); err == nil || !strings.Contains(err.Error(), "unterminated") {
+		t.Errorf("unterminated tag: %v", err)
+	}
+}
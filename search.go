@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+// SearchInfo summarizes the output of a "go" command: the last "info" line
+// reported for each PV plus the "bestmove" line.
+type SearchInfo struct {
+	BestMove string     `json:"bestmove,omitempty"`
+	Ponder   string     `json:"ponder,omitempty"`
+	Lines    []InfoLine `json:"lines,omitempty"`
+}
+
+// InfoLine is one parsed "info" line. Scores are from the point of view of
+// the side to move, as in UCI.
+type InfoLine struct {
+	MultiPV  int      `json:"multipv"`
+	Depth    int      `json:"depth"`
+	SelDepth int      `json:"seldepth,omitempty"`
+	ScoreCP  *int     `json:"score_cp,omitempty"`
+	Mate     *int     `json:"mate,omitempty"`
+	Bound    string   `json:"bound,omitempty"`
+	Nodes    int64    `json:"nodes,omitempty"`
+	NPS      int64    `json:"nps,omitempty"`
+	TBHits   int64    `json:"tbhits,omitempty"`
+	TimeMS   int64    `json:"time_ms,omitempty"`
+	WDL      *WDL     `json:"wdl,omitempty"`
+	PV       []string `json:"pv,omitempty"`
+}
+
+// WDL is the win/draw/loss outlook in permille for the side to move, as
+// reported with UCI_ShowWDL or estimated from the score.
+type WDL struct {
+	Win  int `json:"win"`
+	Draw int `json:"draw"`
+	Loss int `json:"loss"`
+	// ExpectedScore is win + draw/2 as a fraction, e.g. 0.63.
+	ExpectedScore float64 `json:"expected_score"`
+	Estimated     bool    `json:"estimated,omitempty"`
+}
+
+func newWDL(win, draw, loss int, estimated bool) *WDL {
+	return &WDL{
+		Win:           win,
+		Draw:          draw,
+		Loss:          loss,
+		ExpectedScore: math.Round(float64(2*win+draw)/2) / 1000,
+		Estimated:     estimated,
+	}
+}
+
+// Best returns the principal line of the search, or nil if the engine
+// reported no scored line.
+func (s *SearchInfo) Best() *InfoLine {
+	for i := range s.Lines {
+		if s.Lines[i].MultiPV == 1 {
+			return &s.Lines[i]
+		}
+	}
+	return nil
+}
+
+// parseSearchOutput parses the lines of a "go" command. Later info lines
+// for the same multipv index replace earlier ones; lines without a score
+// (currmove, string, ...) are ignored.
+func parseSearchOutput(lines []string) *SearchInfo {
+	info := &SearchInfo{}
+	byPV := map[int]int{}
+
+	for _, line := range lines {
+		if move, ponder, ok := uci.ParseBestMove(line); ok {
+			info.BestMove, info.Ponder = move, ponder
+			continue
+		}
+		parsed, ok := parseInfoLine(line)
+		if !ok {
+			continue
+		}
+		if idx, seen := byPV[parsed.MultiPV]; seen {
+			info.Lines[idx] = parsed
+			continue
+		}
+		byPV[parsed.MultiPV] = len(info.Lines)
+		info.Lines = append(info.Lines, parsed)
+	}
+	return info
+}
+
+// parseInfoLine converts a scored "info" line; other lines report false.
+func parseInfoLine(line string) (InfoLine, bool) {
+	info, ok := uci.ParseInfo(line)
+	if !ok || info.Score == nil || info.String != "" {
+		return InfoLine{}, false
+	}
+
+	parsed := InfoLine{
+		MultiPV:  info.MultiPV,
+		Depth:    info.Depth,
+		SelDepth: info.SelDepth,
+		Bound:    info.Score.Bound,
+		Nodes:    info.Nodes,
+		NPS:      info.NPS,
+		TBHits:   info.TBHits,
+		TimeMS:   info.Time.Milliseconds(),
+		PV:       info.PV,
+	}
+	score := info.Score.Value
+	if info.Score.Mate {
+		parsed.Mate = &score
+	} else {
+		parsed.ScoreCP = &score
+	}
+	if info.WDL != nil {
+		parsed.WDL = newWDL(info.WDL.Win, info.WDL.Draw, info.WDL.Loss, false)
+	}
+	return parsed, true
+}
+
+// whitePOV converts a side-to-move score to White's point of view.
+func whitePOV(score int, turn Color) int {
+	if turn == Black {
+		return -score
+	}
+	return score
+}
+
+// scoreValue folds mate scores into a single centipawn-like scale so that
+// they compare correctly against ordinary evaluations.
+func (l *InfoLine) scoreValue() int {
+	const mateValue = 100000
+	switch {
+	case l.Mate != nil && *l.Mate > 0:
+		return mateValue - *l.Mate
+	case l.Mate != nil:
+		return -mateValue - *l.Mate
+	case l.ScoreCP != nil:
+		return *l.ScoreCP
+	}
+	return 0
+}
+
+// Win-rate model with coefficients close to those of Stockfish 16: a and b
+// are cubics in the game phase (material). Stockfish normalizes scores so
+// that +100 cp means a 50% chance of winning whatever the material, which
+// is why the score is rescaled by a before applying the logistic.
+var (
+	wdlModelA = [4]float64{-1.06249702, 7.42016937, 0.89425629, 348.60356174}
+	wdlModelB = [4]float64{-5.33122190, 39.57831533, -90.84473771, 123.40620748}
+)
+
+// unknownMaterial is the game phase assumed when the position is unknown.
+const unknownMaterial = 58
+
+// winRate returns the chance in permille that the side to move wins with
+// the given centipawn score and total material on the board.
+func winRate(cp, material int) float64 {
+	m := float64(min(max(material, 17), 78)) / 58
+	a := ((wdlModelA[0]*m+wdlModelA[1])*m+wdlModelA[2])*m + wdlModelA[3]
+	b := ((wdlModelB[0]*m+wdlModelB[1])*m+wdlModelB[2])*m + wdlModelB[3]
+	v := float64(cp) * a / 100
+	return 1000 / (1 + math.Exp((a-v)/b))
+}
+
+// estimateWDL derives a WDL outlook from a centipawn or mate score.
+func estimateWDL(line *InfoLine, material int) *WDL {
+	switch {
+	case line.Mate != nil && *line.Mate > 0:
+		return newWDL(1000, 0, 0, true)
+	case line.Mate != nil:
+		return newWDL(0, 0, 1000, true)
+	case line.ScoreCP == nil:
+		return nil
+	}
+	win := int(math.Round(winRate(*line.ScoreCP, material)))
+	loss := int(math.Round(winRate(-*line.ScoreCP, material)))
+	return newWDL(win, 1000-win-loss, loss, true)
+}
+
+// outlook describes a WDL from the point of view of the side to move,
+// e.g. "White wins ~63%, draws ~25%, loses ~12%".
+func (w *WDL) outlook(turn Color) string {
+	side := strings.ToUpper(turn.String()[:1]) + turn.String()[1:]
+	return fmt.Sprintf("%s wins ~%d%%, draws ~%d%%, loses ~%d%%",
+		side, permilleToPercent(w.Win), permilleToPercent(w.Draw), permilleToPercent(w.Loss))
+}
+
+func permilleToPercent(v int) int {
+	return int(math.Round(float64(v) / 10))
+}
+
+// fillWDL estimates the WDL of every line the engine reported without one.
+// pos may be nil when the searched position is not known.
+func (s *SearchInfo) fillWDL(pos *Position) {
+	material := unknownMaterial
+	if pos != nil {
+		material = pos.material()
+	}
+	for i := range s.Lines {
+		if s.Lines[i].WDL == nil {
+			s.Lines[i].WDL = estimateWDL(&s.Lines[i], material)
+		}
+	}
+}
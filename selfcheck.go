@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// selfCheck identifies every configured engine before the server accepts
+// requests, so that a wrong path or a missing network file shows up at
+// startup instead of on the first tool call. A failure is fatal unless
+// allowDegraded is set; the server then starts with the engine marked as
+// unavailable in the tool description.
+func selfCheck(engines *EngineRegistry, allowDegraded bool, logger zerolog.Logger) error {
+	var failed []string
+	for _, name := range engines.Names() {
+		info, err := engines.Describe(name)
+		if err != nil {
+			engine, _ := engines.Get(name)
+			hint := engineErrorHint(engine, err)
+			logger.Error().
+				Err(err).
+				Str("engine", name).
+				Str("path", engine.Path).
+				Str("hint", hint).
+				Msg("Engine check failed")
+			failed = append(failed, fmt.Sprintf("engine %q (%s): %s: %v", name, engine.Path, hint, err))
+			continue
+		}
+
+		event := logger.Info()
+		if info.NNUE == NNUEError {
+			event = logger.Warn()
+		}
+		event.
+			Str("engine", name).
+			Str("path", info.Path).
+			Str("id_name", info.IDName).
+			Str("id_author", info.IDAuthor).
+			Int("options", len(info.Options)).
+			Str("nnue", defaultString(info.NNUE, "unknown")).
+			Strs("networks", info.Networks).
+			Str("nnue_message", info.NNUEMessage).
+			Msg("Engine check passed")
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	if allowDegraded {
+		logger.Warn().
+			Int("failed_engines", len(failed)).
+			Msg("Starting in degraded mode")
+		return nil
+	}
+	return fmt.Errorf(
+		"engine startup check failed:\n  %s\nSet MCP_STOCKFISH_ALLOW_DEGRADED=true to start anyway",
+		strings.Join(failed, "\n  "),
+	)
+}
+
+// engineErrorHint names the usual cause of an engine failing to start or
+// answer.
+func engineErrorHint(engine EngineConfig, err error) string {
+	pathVar := "MCP_STOCKFISH_PATH"
+	if engine.Name != DefaultEngineName {
+		pathVar = "MCP_STOCKFISH_ENGINE_" + engineEnvKey(engine.Name) + "_PATH"
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist) || errors.Is(err, exec.ErrNotFound):
+		return "the binary does not exist; check " + pathVar
+	case errors.Is(err, fs.ErrPermission):
+		return "the binary is not executable by this user"
+	case strings.Contains(err.Error(), "exec format error"):
+		return "the binary is not built for this platform"
+	case strings.Contains(err.Error(), "handshake"):
+		return `the program does not answer "uci" with "uciok"; is it a UCI engine?`
+	case strings.Contains(err.Error(), "test search"):
+		return "the engine cannot search; check its network file (EvalFile) and options"
+	default:
+		return "the engine could not be started"
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestSelfCheck(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{Name: "FakeFish 2", Network: "nn-0123456789ab.nnue"}))
+	engines := newEngineRegistry(config, zerolog.Nop())
+	if err := selfCheck(engines, false, zerolog.Nop()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := engines.Status(testEngine)
+	if err != nil || info.NNUE != NNUEEnabled || !slices.Equal(info.Networks, []string{"nn-0123456789ab.nnue"}) {
+		t.Errorf("status = %+v, %v", info, err)
+	}
+	if got := describeEngines(engines); !strings.Contains(got, "• fake (default): FakeFish 2 by ucitest, NNUE enabled (nn-0123456789ab.nnue), 6 options") {
+		t.Errorf("description:\n%s", got)
+	}
+
+	config.Engines[testEngine] = EngineConfig{Name: testEngine, Path: "/nonexistent/stockfish", MaxSessions: 1}
+	engines = newEngineRegistry(config, zerolog.Nop())
+	err = selfCheck(engines, false, zerolog.Nop())
+	if err == nil || !strings.Contains(err.Error(), "does not exist; check MCP_STOCKFISH_ENGINE_FAKE_PATH") {
+		t.Errorf("missing binary: %v", err)
+	}
+	if err := selfCheck(engines, true, zerolog.Nop()); err != nil {
+		t.Errorf("degraded start: %v", err)
+	}
+	if got := describeEngines(engines); !strings.Contains(got, "UNAVAILABLE") {
+		t.Errorf("degraded description:\n%s", got)
+	}
+
+	broken := fakeEngine(t, ucitest.Script{CrashOn: "uci"})
+	config.Engines[testEngine] = broken
+	err = selfCheck(newEngineRegistry(config, zerolog.Nop()), false, zerolog.Nop())
+	if err == nil || !strings.Contains(err.Error(), "is it a UCI engine?") {
+		t.Errorf("no handshake: %v", err)
+	}
+}
+
+func TestStatusDoesNotWaitForDescribe(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{SearchTime: time.Second}))
+	engines := newEngineRegistry(config, zerolog.Nop())
+
+	described := make(chan *EngineInfo, 2)
+	for range 2 {
+		go func() {
+			info, err := engines.Describe(testEngine)
+			if err != nil {
+				t.Error(err)
+			}
+			described <- info
+		}()
+	}
+	waitFor(t, func() bool {
+		engines.mu.Lock()
+		defer engines.mu.Unlock()
+		return engines.describing[testEngine] != nil
+	})
+
+	start := time.Now()
+	if info, err := engines.Status(testEngine); info != nil || err != nil {
+		t.Errorf("status while describing = %+v, %v", info, err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Status waited %v for Describe", elapsed)
+	}
+	// Both callers share the one launch.
+	if first, second := <-described, <-described; first == nil || first != second {
+		t.Errorf("descriptions %p and %p", first, second)
+	}
+}
+
+func TestParseNNUEStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		status   string
+		networks []string
+	}{
+		{"silent", []string{"info depth 1 score cp 20 pv e2e4", "bestmove e2e4"}, "", nil},
+		{
+			"stockfish 16",
+			[]string{"info string NNUE evaluation using nn-5af11540bbfe.nnue enabled", "bestmove e2e4"},
+			NNUEEnabled,
+			[]string{"nn-5af11540bbfe.nnue"},
+		},
+		{
+			"stockfish 17",
+			[]string{
+				"info string NNUE evaluation using nn-1111cefa1111.nnue (133MiB, (22528, 3072, 15, 32, 1))",
+				"info string NNUE evaluation using nn-37f18f62d772.nnue (6MiB, (22528, 128, 15, 32, 1))",
+				"bestmove e2e4",
+			},
+			NNUEEnabled,
+			[]string{"nn-1111cefa1111.nnue", "nn-37f18f62d772.nnue"},
+		},
+		{
+			"missing network",
+			[]string{
+				"info string ERROR: Network evaluation parameters compatible with the engine must be available.",
+				"info string ERROR: The network file nn-1111cefa1111.nnue was not loaded successfully.",
+			},
+			NNUEError,
+			nil,
+		},
+		{"classical", []string{"info string classical evaluation enabled"}, NNUEDisabled, nil},
+	}
+	for _, tt := range tests {
+		status, networks, _ := parseNNUEStatus(tt.lines)
+		if status != tt.status || !slices.Equal(networks, tt.networks) {
+			t.Errorf("%s: %q %q", tt.name, status, networks)
+		}
+	}
+}
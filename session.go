@@ -1,94 +1,200 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
 )
 
 type StockfishSession struct {
-	ID         string
-	cmd        *exec.Cmd
-	stdin      *bufio.Writer
-	stdout     *bufio.Scanner
-	stderr     *bufio.Scanner
-	lastUsed   time.Time
-	mu         sync.RWMutex
-	logger     zerolog.Logger
-	cancelFunc context.CancelFunc
+	ID     string
+	Engine string
+	// Owner is the authenticated client that created the session, if any.
+	Owner    string
+	engine   EngineConfig
+	client   *uci.Client
+	lastUsed time.Time
+	mu       sync.RWMutex
+	logger   zerolog.Logger
+	// chess960 mirrors the UCI_Chess960 option last sent to the engine.
+	chess960 bool
+
+	// state is kept apart from mu, which is held for whole searches.
+	stateMu sync.Mutex
+	state   SessionState
+
+	// transcript records the client's commands; nil for ephemeral sessions.
+	transcript *transcript
+	// release frees what was set up to isolate the engine process.
+	release func()
 }
 
 type SessionManager struct {
 	sessions      map[string]*StockfishSession
 	config        StockfishConfig
+	engines       *EngineRegistry
 	logger        zerolog.Logger
 	mu            sync.RWMutex
 	stopCleanupCh chan struct{}
 	shutdownOnce  sync.Once
+	closed        bool
+
+	// Requests waiting for a free session slot, in service order.
+	waiters      []*sessionWaiter
+	queueMetrics QueueMetrics
+
+	// Sessions of a previous run, recreated when their clients return.
+	restorable map[string]SessionState
+	// Sessions whose engine is starting, by ID.
+	pending map[string]*pendingSession
 }
 
-func newSessionManager(config StockfishConfig, logger zerolog.Logger) *SessionManager {
+func newSessionManager(
+	config StockfishConfig,
+	engines *EngineRegistry,
+	logger zerolog.Logger,
+) *SessionManager {
 	sm := &SessionManager{
 		sessions:      make(map[string]*StockfishSession),
 		config:        config,
+		engines:       engines,
 		logger:        logger.With().Str("component", ComponentSessionManager).Logger(),
 		stopCleanupCh: make(chan struct{}),
+		restorable:    make(map[string]SessionState),
+		pending:       make(map[string]*pendingSession),
+	}
+	// Engines of matches, batches and the other tools queue with the
+	// sessions for their slots.
+	engines.queue = sm
+
+	if err := sm.loadSnapshot(); err != nil {
+		sm.logger.Warn().Err(err).Msg("Ignoring session snapshot")
 	}
 
 	go sm.cleanupRoutine()
+	if config.SnapshotPath != "" && config.SnapshotInterval > 0 {
+		go sm.snapshotRoutine()
+	}
 	return sm
 }
 
 func createEphemeralStockfishSession(
-	stockfishPath string,
+	engine EngineConfig,
 	logger zerolog.Logger,
 ) (*StockfishSession, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	cmd := exec.CommandContext(ctx, stockfishPath)
+	sessionLogger := logger.With().
+		Str("session_id", "ephemeral").
+		Str("engine", engine.Name).
+		Logger()
 
-	stdin, err := cmd.StdinPipe()
+	session, err := startEngineSession("ephemeral-"+uuid.NewString()[:8], engine, sessionLogger)
 	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("ephemeral: failed to create stdin pipe: %w", err)
+		return nil, fmt.Errorf("ephemeral: %w", err)
 	}
+	sessionLogger.Debug().Msg("Created ephemeral Stockfish instance")
+	return session, nil
+}
 
-	stdout, err := cmd.StdoutPipe()
+// startEngineSession spawns the engine process with its configured arguments
+// and working directory.
+func startEngineSession(
+	sessionID string,
+	engine EngineConfig,
+	logger zerolog.Logger,
+) (*StockfishSession, error) {
+	command := uci.Command{
+		Path: engine.Path,
+		Args: engine.Args,
+		Dir:  engine.WorkDir,
+	}
+	release, err := engine.Limits.isolate(&command)
 	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("ephemeral: failed to create stdout pipe: %w", err)
+		return nil, fmt.Errorf("failed to isolate engine %q: %w", engine.Name, err)
+	}
+	client, err := uci.Start(context.Background(), command)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to start engine %q: %w", engine.Name, err)
+	}
+
+	now := time.Now()
+	session := &StockfishSession{
+		ID:       sessionID,
+		Engine:   engine.Name,
+		engine:   engine,
+		client:   client,
+		lastUsed: now,
+		logger:   logger,
+		release:  release,
+		state: SessionState{
+			ID:        sessionID,
+			Engine:    engine.Name,
+			CreatedAt: now,
+			LastUsed:  now,
+		},
+	}
+
+	if err := session.applyOptions(engine.Options, engine.ShowWDL); err != nil {
+		session.close()
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// applyOptions sends the engine's default options. Options can only be set
+// once the engine is in UCI mode, so the handshake is done first. With
+// showWDL, UCI_ShowWDL is enabled when the engine announces it and the
+// configured options don't set it.
+func (s *StockfishSession) applyOptions(options []EngineOptionValue, showWDL bool) error {
+	if len(options) == 0 && !showWDL {
+		return nil
 	}
 
-	stderr, err := cmd.StderrPipe()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHandshakeTimeout)
+	defer cancel()
+
+	id, err := s.client.Handshake(ctx)
 	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("ephemeral: failed to create stderr pipe: %w", err)
+		return fmt.Errorf("engine %q: uci handshake failed: %w", s.Engine, err)
 	}
 
-	if err := cmd.Start(); err != nil {
-		cancel()
-		return nil, fmt.Errorf("ephemeral: failed to start stockfish: %w", err)
+	if _, ok := id.Option(optionShowWDL); showWDL && ok && !hasOption(options, optionShowWDL) {
+		options = append([]EngineOptionValue{{Name: optionShowWDL, Value: "true"}}, options...)
+	}
+	if len(options) == 0 {
+		return nil
 	}
 
-	sessionLogger := logger.With().Str("session_id", "ephemeral").Logger()
+	for _, opt := range options {
+		if err := s.client.SetOption(ctx, opt.Name, opt.Value); err != nil {
+			return err
+		}
+	}
 
-	session := &StockfishSession{
-		ID:         "ephemeral-" + uuid.NewString()[:8],
-		cmd:        cmd,
-		stdin:      bufio.NewWriter(stdin),
-		stdout:     bufio.NewScanner(stdout),
-		stderr:     bufio.NewScanner(stderr),
-		lastUsed:   time.Now(),
-		logger:     sessionLogger,
-		cancelFunc: cancel,
+	if err := s.client.IsReady(ctx); err != nil {
+		return fmt.Errorf("engine %q: not ready after applying options: %w", s.Engine, err)
 	}
-	sessionLogger.Debug().Msg("Created ephemeral Stockfish instance")
-	return session, nil
+	return nil
+}
+
+func hasOption(options []EngineOptionValue, name string) bool {
+	for _, opt := range options {
+		if strings.EqualFold(opt.Name, name) {
+			return true
+		}
+	}
+	return false
 }
 
 func (sm *SessionManager) Close() {
@@ -97,90 +203,298 @@ func (sm *SessionManager) Close() {
 
 		close(sm.stopCleanupCh)
 
+		if err := sm.saveSnapshot(); err != nil {
+			sm.logger.Error().Err(err).Msg("Failed to save session snapshot")
+		}
+
 		sm.mu.Lock()
 		defer sm.mu.Unlock()
 
+		sm.closed = true
 		for sessionID, session := range sm.sessions {
 			session.close()
 			delete(sm.sessions, sessionID)
 			sm.logger.Debug().Str("session_id", sessionID).Msg("Closed session during shutdown")
 		}
 		sm.sessions = make(map[string]*StockfishSession)
+		sm.notifyWaiters()
 
 		sm.logger.Info().Msg("Session manager shutdown complete")
 	})
 }
 
-func (sm *SessionManager) getOrCreateSession(sessionID string) (*StockfishSession, error) {
+// getOrCreateSession returns the client's session, or starts a new one. A
+// session saved by a previous run is recreated under its ID. When the
+// session limits are reached the request waits in the queue; the returned
+// QueueWait is non-nil if it did. Engines are started and saved sessions
+// replayed outside sm.mu, so a slow start holds up only the requests for
+// the same session.
+func (sm *SessionManager) getOrCreateSession(
+	ctx context.Context,
+	sessionID string,
+	engineName string,
+	priority Priority,
+) (*StockfishSession, *QueueWait, error) {
+	principal := principalFromContext(ctx)
+	for {
+		session, r, err := sm.reserveSession(ctx, principal, sessionID, engineName, priority)
+		if err != nil || session != nil {
+			return session, r.wait, err
+		}
+		if r.inFlight != nil {
+			select {
+			case <-r.inFlight:
+				continue
+			case <-ctx.Done():
+				return nil, r.wait, ctx.Err()
+			}
+		}
+
+		if r.saved != nil {
+			session, err = sm.restoreSession(*r.saved, r.engine)
+		} else {
+			session, err = sm.createSession(r.id, r.engine)
+		}
+		return sm.addSession(r, session, principal, err)
+	}
+}
+
+// sessionReservation is a session being started by one request, holding
+// an engine slot for it.
+type sessionReservation struct {
+	id     string
+	engine EngineConfig
+	// saved is the state to replay when restoring a saved session.
+	saved *SessionState
+	wait  *QueueWait
+	// inFlight is set instead when another request is starting the
+	// session; it is closed once that request is done.
+	inFlight chan struct{}
+}
+
+// pendingSession marks a session ID while its engine starts, so that it
+// counts against the limits and other requests for it wait.
+type pendingSession struct {
+	owner  string
+	engine string
+	done   chan struct{}
+}
+
+// reserveSession returns the existing session, or takes an engine slot and
+// marks the session as pending for the caller to start.
+func (sm *SessionManager) reserveSession(
+	ctx context.Context,
+	principal *Principal,
+	sessionID string,
+	engineName string,
+	priority Priority,
+) (*StockfishSession, sessionReservation, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	if sessionID != "" {
 		if session, exists := sm.sessions[sessionID]; exists {
+			if err := sm.checkSession(session, principal, engineName); err != nil {
+				return nil, sessionReservation{}, err
+			}
 			session.mu.Lock()
 			session.lastUsed = time.Now()
 			session.mu.Unlock()
-			return session, nil
+			return session, sessionReservation{}, nil
+		}
+		if p, ok := sm.pending[sessionID]; ok {
+			return nil, sessionReservation{inFlight: p.done}, nil
 		}
 	}
 
-	if len(sm.sessions) >= sm.config.MaxSessions {
-		return nil, fmt.Errorf("maximum number of sessions (%d) reached", sm.config.MaxSessions)
+	saved, restoring := sm.restorable[sessionID]
+	if restoring {
+		if !ownedBy(saved.Owner, principal) {
+			return nil, sessionReservation{}, fmt.Errorf("session %s not found", sessionID)
+		}
+		if engineName != "" && engineName != saved.Engine {
+			return nil, sessionReservation{}, fmt.Errorf(
+				"session %s is bound to engine %q, not %q",
+				sessionID,
+				saved.Engine,
+				engineName,
+			)
+		}
+		engineName = saved.Engine
 	}
 
-	if sessionID == "" {
-		sessionID = uuid.New().String()
+	engine, err := sm.engines.Get(engineName)
+	if err != nil {
+		if restoring {
+			delete(sm.restorable, sessionID)
+		}
+		return nil, sessionReservation{}, err
+	}
+
+	if err := sm.checkQuota(principal); err != nil {
+		return nil, sessionReservation{}, err
 	}
 
-	session, err := sm.createSession(sessionID)
+	_, wait, err := sm.acquireSlots(ctx, engine, 1, 1, priority)
+	r := sessionReservation{engine: engine, wait: wait}
 	if err != nil {
-		return nil, err
+		return nil, r, err
 	}
 
-	sm.sessions[sessionID] = session
-	sm.logger.Info().Str("session_id", sessionID).Msg("Created new Stockfish session")
+	// The lock was released while queueing: a request for the same session
+	// may have started it meanwhile, or other sessions of the client.
+	if sessionID != "" {
+		if session, exists := sm.sessions[sessionID]; exists {
+			sm.engines.slots.release(1)
+			sm.notifyWaiters()
+			if err := sm.checkSession(session, principal, engineName); err != nil {
+				return nil, r, err
+			}
+			return session, r, nil
+		}
+		if p, ok := sm.pending[sessionID]; ok {
+			sm.engines.slots.release(1)
+			sm.notifyWaiters()
+			r.inFlight = p.done
+			return nil, r, nil
+		}
+	}
+	if err := sm.checkQuota(principal); err != nil {
+		sm.engines.slots.release(1)
+		sm.notifyWaiters()
+		return nil, r, err
+	}
 
-	return session, nil
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	}
+	r.id = sessionID
+	if saved, ok := sm.restorable[sessionID]; ok {
+		delete(sm.restorable, sessionID)
+		r.saved = &saved
+	}
+
+	p := &pendingSession{engine: engine.Name, done: make(chan struct{})}
+	if principal != nil {
+		p.owner = principal.Name
+	}
+	sm.pending[sessionID] = p
+	return nil, r, nil
 }
 
-func (sm *SessionManager) createSession(sessionID string) (*StockfishSession, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	cmd := exec.CommandContext(ctx, sm.config.Path)
+// addSession completes a reservation with the session started for it, or
+// gives its slot back when starting failed.
+func (sm *SessionManager) addSession(
+	r sessionReservation,
+	session *StockfishSession,
+	principal *Principal,
+	err error,
+) (*StockfishSession, *QueueWait, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	close(sm.pending[r.id].done)
+	delete(sm.pending, r.id)
 
-	stdin, err := cmd.StdinPipe()
+	if err == nil && sm.closed {
+		session.close()
+		err = fmt.Errorf("session manager is shut down")
+	}
 	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+		sm.engines.slots.release(1)
+		sm.notifyWaiters()
+		return nil, r.wait, err
 	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	session.holdSlot(sm.engines.slots)
+	if principal != nil {
+		session.setOwner(principal.Name)
 	}
+	sm.sessions[r.id] = session
+	sm.logger.Info().
+		Str("session_id", r.id).
+		Str("engine", r.engine.Name).
+		Str("owner", session.Owner).
+		Msg("Created new Stockfish session")
+
+	return session, r.wait, nil
+}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+// checkSession fails when the caller may not use an existing session, or
+// asks for it on another engine.
+func (sm *SessionManager) checkSession(session *StockfishSession, p *Principal, engineName string) error {
+	if !ownedBy(session.Owner, p) {
+		return fmt.Errorf("session %s not found", session.ID)
 	}
+	if engineName != "" && engineName != session.Engine {
+		return fmt.Errorf(
+			"session %s is bound to engine %q, not %q",
+			session.ID,
+			session.Engine,
+			engineName,
+		)
+	}
+	return nil
+}
+
+// ownedBy reports whether a session of owner may be used by the caller.
+// Without authentication every session may.
+func ownedBy(owner string, p *Principal) bool {
+	return p == nil || owner == p.Name
+}
 
-	if err := cmd.Start(); err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to start stockfish: %w", err)
+// checkQuota fails when the caller already has as many sessions as its
+// credentials allow. It must be called with sm.mu held.
+func (sm *SessionManager) checkQuota(p *Principal) error {
+	if p == nil || p.MaxSessions == 0 {
+		return nil
 	}
+	count := 0
+	for _, session := range sm.sessions {
+		if session.Owner == p.Name {
+			count++
+		}
+	}
+	for _, pending := range sm.pending {
+		if pending.owner == p.Name {
+			count++
+		}
+	}
+	if count >= p.MaxSessions {
+		return fmt.Errorf("session quota reached: %q may keep %d sessions", p.Name, p.MaxSessions)
+	}
+	return nil
+}
 
-	session := &StockfishSession{
-		ID:         sessionID,
-		cmd:        cmd,
-		stdin:      bufio.NewWriter(stdin),
-		stdout:     bufio.NewScanner(stdout),
-		stderr:     bufio.NewScanner(stderr),
-		lastUsed:   time.Now(),
-		logger:     sm.logger.With().Str("session_id", sessionID).Logger(),
-		cancelFunc: cancel,
+// countEngineSessions must be called with sm.mu held.
+func (sm *SessionManager) countEngineSessions(engineName string) int {
+	count := 0
+	for _, session := range sm.sessions {
+		if session.Engine == engineName {
+			count++
+		}
+	}
+	for _, pending := range sm.pending {
+		if pending.engine == engineName {
+			count++
+		}
 	}
+	return count
+}
 
+func (sm *SessionManager) createSession(
+	sessionID string,
+	engine EngineConfig,
+) (*StockfishSession, error) {
+	session, err := startEngineSession(
+		sessionID,
+		engine,
+		sm.logger.With().Str("session_id", sessionID).Str("engine", engine.Name).Logger(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.transcript = newTranscript(sm.config.TranscriptSize)
 	return session, nil
 }
 
@@ -192,12 +506,20 @@ func (sm *SessionManager) removeSession(sessionID string) {
 		session.close()
 		delete(sm.sessions, sessionID)
 		sm.logger.Info().Str("session_id", sessionID).Msg("Removed Stockfish session")
+		sm.notifyWaiters()
 	}
 }
 
 func (sm *SessionManager) cleanupRoutine() {
-	ticker := time.NewTicker(sm.config.SessionTimeout / 2)
-	if sm.config.SessionTimeout < 2*time.Second {
+	timeout := sm.config.SessionTimeout
+	for _, engine := range sm.config.Engines {
+		if engine.SessionTimeout > 0 && engine.SessionTimeout < timeout {
+			timeout = engine.SessionTimeout
+		}
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	if timeout < 2*time.Second {
 		ticker = time.NewTicker(time.Second)
 	}
 	defer ticker.Stop()
@@ -218,9 +540,16 @@ func (sm *SessionManager) cleanupExpiredSessions() {
 	defer sm.mu.Unlock()
 
 	now := time.Now().UTC()
+	defer sm.notifyWaiters()
+	for sessionID, state := range sm.restorable {
+		if sm.stateExpired(state) {
+			delete(sm.restorable, sessionID)
+			sm.logger.Info().Str("session_id", sessionID).Msg("Dropped expired saved session")
+		}
+	}
 	for sessionID, session := range sm.sessions {
 		session.mu.RLock()
-		expired := now.Sub(session.lastUsed) > sm.config.SessionTimeout
+		expired := now.Sub(session.lastUsed) > session.engine.SessionTimeout
 		session.mu.RUnlock()
 
 		if expired {
@@ -231,76 +560,115 @@ func (sm *SessionManager) cleanupExpiredSessions() {
 	}
 }
 
-func (s *StockfishSession) executeCommand(command string, timeout time.Duration) ([]string, error) {
+// executeCommand sends a raw UCI command and returns the engine's reply:
+// up to "uciok", "readyok" or "bestmove", and nothing for commands that
+// have no reply.
+func (s *StockfishSession) executeCommand(
+	ctx context.Context,
+	command string,
+	timeout time.Duration,
+) ([]string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.lastUsed = time.Now()
 
-	if _, err := s.stdin.WriteString(command + "\n"); err != nil {
-		return nil, fmt.Errorf("failed to write command: %w", err)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	responses, err := s.client.Exec(ctx, command)
+	if err == nil {
+		s.record(command)
 	}
-	if err := s.stdin.Flush(); err != nil {
-		return nil, fmt.Errorf("failed to flush command: %w", err)
+	if isGoCommand(command) {
+		chargeSearch(ctx, responses, time.Since(started))
 	}
+	err = commandError(err, timeout)
+	s.transcript.add(command, responses, time.Since(started), err)
+	return responses, err
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+// search runs a search from the position last sent.
+func (s *StockfishSession) search(
+	ctx context.Context,
+	limits uci.Limits,
+	timeout time.Duration,
+) (*uci.SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	var responses []string
-	done := make(chan struct{})
-	var scanErr error
+	s.lastUsed = time.Now()
 
-	go func() {
-		defer close(done)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-		for {
-			if !s.stdout.Scan() {
-				scanErr = s.stdout.Err()
-				return
-			}
+	started := time.Now()
+	result, err := s.client.Go(ctx, limits, nil)
+	chargeSearch(ctx, result.Lines, time.Since(started))
+	return result, commandError(err, timeout)
+}
 
-			line := s.stdout.Text()
-			responses = append(responses, line)
+func commandError(err error, timeout time.Duration) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("command timeout after %v", timeout)
+	}
+	return err
+}
 
-			if shouldStopReading(command, line) {
-				return
-			}
-		}
-	}()
+// syncVariant switches UCI_Chess960 to match the position of a "position"
+// command before it is sent. Other commands are left alone. s.mu guards
+// chess960 against concurrent commands on the session.
+func (s *StockfishSession) syncVariant(command string) error {
+	if !isPositionCommand(command) {
+		return nil
+	}
+	game, err := parsePositionCommand(command)
+	if err != nil {
+		// Let the engine report the malformed command.
+		return nil
+	}
+	chess960 := game.Start().Chess960()
 
-	select {
-	case <-done:
-		if scanErr != nil {
-			return responses, fmt.Errorf("scan error: %w", scanErr)
-		}
-		return responses, nil
-	case <-ctx.Done():
-		return responses, fmt.Errorf("command timeout after %v", timeout)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if chess960 == s.chess960 {
+		return nil
+	}
+	s.lastUsed = time.Now()
+	setoption := uci.FormatSetOption(optionChess960, fmt.Sprint(chess960))
+	if err := s.client.Send(context.Background(), setoption); err != nil {
+		return err
 	}
+	s.chess960 = chess960
+	s.logger.Debug().Bool("chess960", chess960).Msg("Switched UCI_Chess960")
+	return nil
 }
 
-func shouldStopReading(command, response string) bool {
-	switch {
-	case command == StockfishCmdUCI && response == "uciok":
-		return true
-	case command == StockfishCmdIsReady && response == "readyok":
-		return true
-	case command == StockfishCmdQuit:
-		return true
-	case command == StockfishCmdStop:
-		return true
-	case response == "bestmove" || (len(response) > 8 && response[:8] == "bestmove"):
-		return true
-	}
-	return false
+// writeCommand sends a command that produces no output, such as "setoption".
+func (s *StockfishSession) writeCommand(command string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUsed = time.Now()
+	return s.client.Send(context.Background(), command)
+}
+
+// exited reports whether the engine process has gone away.
+func (s *StockfishSession) exited() bool {
+	return s.client.Err() != nil
+}
+
+// holdSlot makes closing the session free its engine slot.
+func (s *StockfishSession) holdSlot(slots *engineSlots) {
+	release := s.release
+	s.release = sync.OnceFunc(func() {
+		release()
+		slots.release(1)
+	})
 }
 
 func (s *StockfishSession) close() {
-	if s.cancelFunc != nil {
-		s.cancelFunc()
-	}
-	if s.cmd != nil && s.cmd.Process != nil {
-		_ = s.cmd.Process.Kill()
-	}
+	_ = s.client.Close()
+	s.release()
 }
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestPersistentExecutorKeepsSession(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	sm := newTestSessionManager(t, testConfig(engine))
+	executor := NewPersistentSessionExecutor(sm, zerolog.Nop())
+
+	id, _, _, err := executor.Execute(context.Background(), testEngine, "position startpos moves e2e4", "", PriorityInteractive, 0)
+	if err != nil {
+		t.Fatalf("position: %v", err)
+	}
+	for _, command := range []string{"go depth 2", "position fen 4k3/8/8/8/8/8/8/4K3 w - - 0 1", "go depth 2"} {
+		got, responses, _, err := executor.Execute(context.Background(), testEngine, command, id, PriorityInteractive, 0)
+		if err != nil || got != id {
+			t.Fatalf("%s: session %s, %v", command, got, err)
+		}
+		if strings.HasPrefix(command, "go") && responses[len(responses)-1] != "bestmove e2e4" {
+			t.Errorf("%s: %q", command, responses)
+		}
+	}
+
+	if n := len(sm.sessions); n != 1 {
+		t.Errorf("%d sessions, want 1", n)
+	}
+	want := []string{
+		"position startpos moves e2e4",
+		"go depth 2",
+		"position fen 4k3/8/8/8/8/8/8/4K3 w - - 0 1",
+		"go depth 2",
+	}
+	if got := commandLog(t, engine); !slices.Equal(got, want) {
+		t.Errorf("engine received %q, want %q", got, want)
+	}
+}
+
+func TestPersistentExecutorSwitchesChess960(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	sm := newTestSessionManager(t, testConfig(engine))
+	executor := NewPersistentSessionExecutor(sm, zerolog.Nop())
+
+	id, _, _, err := executor.Execute(context.Background(),
+		testEngine,
+		"position fen bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+		"",
+		PriorityInteractive,
+		0,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"position startpos", "isready"} {
+		if _, _, _, err := executor.Execute(context.Background(), testEngine, command, id, PriorityInteractive, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := commandLog(t, engine)
+	want := []string{
+		"setoption name UCI_Chess960 value true",
+		"position fen bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+		"setoption name UCI_Chess960 value false",
+		"position startpos",
+		"isready",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("engine received %q, want %q", got, want)
+	}
+}
+
+func TestPersistentExecutorRemovesEndedSessions(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  ucitest.Script
+		command string
+	}{
+		{"quit", ucitest.Script{}, "quit"},
+		{"crash", ucitest.Script{CrashOn: "go"}, "go depth 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := newTestSessionManager(t, testConfig(fakeEngine(t, tt.script)))
+			executor := NewPersistentSessionExecutor(sm, zerolog.Nop())
+
+			id, _, _, err := executor.Execute(context.Background(), testEngine, "isready", "", PriorityInteractive, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := sm.sessions[id]
+			executor.Execute(context.Background(), testEngine, tt.command, id, PriorityInteractive, 0)
+
+			if _, ok := sm.sessions[id]; ok {
+				t.Error("session still registered")
+			}
+			select {
+			case <-session.client.Done():
+			case <-time.After(5 * time.Second):
+				t.Error("engine process still running")
+			}
+		})
+	}
+}
+
+func TestSessionTimeoutDoesNotBreakSession(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{SearchTime: time.Minute}))
+	config.CommandTimeout = 100 * time.Millisecond
+	sm := newTestSessionManager(t, config)
+	executor := NewPersistentSessionExecutor(sm, zerolog.Nop())
+
+	id, responses, _, err := executor.Execute(context.Background(), testEngine, "go infinite", "", PriorityInteractive, 0)
+	if err == nil || !strings.Contains(err.Error(), "command timeout after 100ms") {
+		t.Fatalf("err = %v", err)
+	}
+	// The search was stopped, so its best move ends the output and the
+	// session stays usable.
+	if responses[len(responses)-1] != "bestmove e2e4" {
+		t.Errorf("responses = %q", responses)
+	}
+	if _, _, _, err := executor.Execute(context.Background(), testEngine, "isready", id, PriorityInteractive, 0); err != nil {
+		t.Errorf("isready after timeout: %v", err)
+	}
+}
+
+func TestSessionBoundToEngine(t *testing.T) {
+	sm := newTestSessionManager(t, testConfig(fakeEngine(t, ucitest.Script{})))
+
+	session, _, err := sm.getOrCreateSession(context.Background(), "mine", "", PriorityInteractive)
+	if err != nil || session.ID != "mine" || session.Engine != testEngine {
+		t.Fatalf("session %+v, %v", session, err)
+	}
+	if _, _, err := sm.getOrCreateSession(context.Background(), "mine", "other", PriorityInteractive); err == nil {
+		t.Error("session rebound to another engine")
+	}
+	if _, _, err := sm.getOrCreateSession(context.Background(), "", "other", PriorityInteractive); err == nil ||
+		!strings.Contains(err.Error(), "unknown engine") {
+		t.Errorf("unknown engine: %v", err)
+	}
+}
+
+func TestQueuedSessionBoundToEngine(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	engine.MaxSessions = 1
+	other := fakeEngine(t, ucitest.Script{})
+	other.Name = "other"
+	config := testConfig(engine)
+	config.MaxSessions = 3
+	config.Engines[other.Name] = other
+	sm := newTestSessionManager(t, config)
+
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, _, err := sm.getOrCreateSession(context.Background(), "x", testEngine, PriorityInteractive)
+		done <- err
+	}()
+	waitFor(t, func() bool { return sm.QueueMetrics().Length == 1 })
+
+	// While the first request for x waits, x is created on another engine.
+	if _, _, err := sm.getOrCreateSession(context.Background(), "x", other.Name, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	sm.removeSession("a")
+	if err := <-done; err == nil || !strings.Contains(err.Error(), `bound to engine "other"`) {
+		t.Errorf("queued request for x on %q: %v", testEngine, err)
+	}
+}
+
+func TestMaxSessionsWithoutQueue(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	engine.MaxSessions = 1
+	config := testConfig(engine)
+	config.QueueMaxLength = 0
+	sm := newTestSessionManager(t, config)
+
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	// The client's own session is still served.
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Errorf("existing session: %v", err)
+	}
+	_, _, err := sm.getOrCreateSession(context.Background(), "b", "", PriorityInteractive)
+	if err == nil || !strings.Contains(err.Error(), "maximum number of sessions (1) reached") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestQueuedRequestGetsFreedSession(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	engine.MaxSessions = 1
+	sm := newTestSessionManager(t, testConfig(engine))
+
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	type outcome struct {
+		wait *QueueWait
+		err  error
+	}
+	done := make(chan outcome)
+	go func() {
+		_, wait, err := sm.getOrCreateSession(context.Background(), "b", "", PriorityBatch)
+		done <- outcome{wait, err}
+	}()
+
+	waitFor(t, func() bool { return sm.QueueMetrics().Length == 1 })
+	sm.removeSession("a")
+
+	select {
+	case got := <-done:
+		if got.err != nil {
+			t.Fatal(got.err)
+		}
+		if got.wait == nil || got.wait.Position != 1 || got.wait.Priority != PriorityBatch {
+			t.Errorf("wait = %+v", got.wait)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued request not served")
+	}
+
+	metrics := sm.QueueMetrics()
+	if metrics.Enqueued != 1 || metrics.Served != 1 || metrics.Length != 0 {
+		t.Errorf("metrics = %+v", metrics)
+	}
+}
+
+func TestInteractiveRequestServedBeforeBatchReservation(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	config.MaxSessions = 1
+	sm := newTestSessionManager(t, config)
+
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch tool asks for an engine first, then an interactive session.
+	reserved := make(chan error, 1)
+	go func() {
+		_, release, err := sm.engines.reserveUpTo(context.Background(), 2)
+		if err == nil {
+			release()
+		}
+		reserved <- err
+	}()
+	waitFor(t, func() bool { return sm.QueueMetrics().Length == 1 })
+	created := make(chan error, 1)
+	go func() {
+		_, _, err := sm.getOrCreateSession(context.Background(), "b", "", PriorityInteractive)
+		created <- err
+	}()
+	waitFor(t, func() bool { return sm.QueueMetrics().Length == 2 })
+
+	sm.removeSession("a")
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case err := <-reserved:
+		t.Fatalf("batch reservation served before the interactive session: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("interactive request not served")
+	}
+
+	sm.removeSession("b")
+	select {
+	case err := <-reserved:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch reservation not served")
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	engine.MaxSessions = 1
+	config := testConfig(engine)
+	config.QueueMaxWait = 100 * time.Millisecond
+	sm := newTestSessionManager(t, config)
+
+	if _, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	_, wait, err := sm.getOrCreateSession(context.Background(), "b", "", PriorityInteractive)
+	if err == nil || !strings.Contains(err.Error(), "no session freed up within 100ms") {
+		t.Errorf("err = %v", err)
+	}
+	if wait == nil || wait.WaitMS < 100 {
+		t.Errorf("wait = %+v", wait)
+	}
+	if metrics := sm.QueueMetrics(); metrics.TimedOut != 1 {
+		t.Errorf("metrics = %+v", metrics)
+	}
+}
+
+func TestIdleSessionEvicted(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	engine.MaxSessions = 1
+	config := testConfig(engine)
+	config.EvictIdleAfter = 10 * time.Millisecond
+	sm := newTestSessionManager(t, config)
+
+	a, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, _, err := sm.getOrCreateSession(context.Background(), "b", "", PriorityInteractive); err != nil {
+		t.Fatalf("b: %v", err)
+	}
+
+	if _, ok := sm.sessions["a"]; ok {
+		t.Error("idle session a not evicted")
+	}
+	if err := a.client.IsReady(context.Background()); !errors.Is(err, uci.ErrClosed) {
+		t.Errorf("evicted engine: %v", err)
+	}
+	if metrics := sm.QueueMetrics(); metrics.Evicted != 1 {
+		t.Errorf("metrics = %+v", metrics)
+	}
+}
+
+func TestExpiredSessionsCleanedUp(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	engine.SessionTimeout = 50 * time.Millisecond
+	sm := newTestSessionManager(t, testConfig(engine))
+
+	session, _, err := sm.getOrCreateSession(context.Background(), "a", "", PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	sm.cleanupExpiredSessions()
+
+	if n := len(sm.sessions); n != 0 {
+		t.Errorf("%d sessions left", n)
+	}
+	select {
+	case <-session.client.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("expired engine still running")
+	}
+}
+
+func TestSessionManagerClose(t *testing.T) {
+	sm := newTestSessionManager(t, testConfig(fakeEngine(t, ucitest.Script{})))
+
+	var sessions []*StockfishSession
+	for _, id := range []string{"a", "b"} {
+		session, _, err := sm.getOrCreateSession(context.Background(), id, "", PriorityInteractive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+	sm.Close()
+
+	for _, session := range sessions {
+		select {
+		case <-session.client.Done():
+		case <-time.After(5 * time.Second):
+			t.Errorf("session %s still running", session.ID)
+		}
+	}
+	if _, _, err := sm.getOrCreateSession(context.Background(), "c", "", PriorityInteractive); err == nil {
+		t.Error("session created after Close")
+	}
+}
+
+func TestEphemeralExecutorUsesFreshEngine(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	config := testConfig(engine)
+	executor := NewEphemeralSessionExecutor(newEngineRegistry(config, zerolog.Nop()), config.CommandTimeout, zerolog.Nop())
+
+	for i := 0; i < 2; i++ {
+		id, responses, wait, err := executor.Execute(context.Background(), testEngine, "go depth 1", "ignored", PriorityInteractive, 0)
+		if err != nil || id != SessionIDStdioEphemeral || wait != nil {
+			t.Fatalf("id %s, wait %+v, err %v", id, wait, err)
+		}
+		if responses[len(responses)-1] != "bestmove e2e4" {
+			t.Errorf("responses = %q", responses)
+		}
+	}
+	// Without configured options no handshake precedes the command.
+	if got := commandLog(t, engine); !slices.Equal(got, []string{"go depth 1", "go depth 1"}) {
+		t.Errorf("engine received %q", got)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
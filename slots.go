@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// engineSlots caps the engine processes running at once across persistent
+// sessions, matches, batches and test suites, so that MCP_STOCKFISH_MAX_SESSIONS
+// bounds the whole server and not each tool separately.
+type engineSlots struct {
+	mu       sync.Mutex
+	capacity int
+	used     int
+	// changed is closed and replaced whenever slots are released.
+	changed chan struct{}
+}
+
+func newEngineSlots(capacity int) *engineSlots {
+	return &engineSlots{capacity: capacity, changed: make(chan struct{})}
+}
+
+// take takes as many free slots as it can, up to n, if at least need are
+// free. It returns how many it took.
+func (s *engineSlots) take(need, n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	free := s.capacity - s.used
+	if free < need {
+		return 0
+	}
+	taken := min(free, n)
+	s.used += taken
+	return taken
+}
+
+// acquire takes n slots, waiting up to maxWait for them to free up.
+func (s *engineSlots) acquire(ctx context.Context, n int, maxWait time.Duration) error {
+	_, err := s.acquireUpTo(ctx, n, n, maxWait)
+	return err
+}
+
+// acquireUpTo waits up to maxWait for need slots to be free, then takes as
+// many free slots as it can, up to n. It returns how many it took. Without
+// maxWait it fails at once when fewer than need are free.
+func (s *engineSlots) acquireUpTo(ctx context.Context, need, n int, maxWait time.Duration) (int, error) {
+	if need > s.capacity {
+		return 0, fmt.Errorf("%d engines needed, but at most %d may run at once", need, s.capacity)
+	}
+
+	var deadline <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		s.mu.Lock()
+		if free := s.capacity - s.used; free >= need {
+			taken := min(free, n)
+			s.used += taken
+			s.mu.Unlock()
+			return taken, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if deadline == nil {
+			return 0, s.fullError()
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return 0, fmt.Errorf("%w; none freed up within %v", s.fullError(), maxWait)
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (s *engineSlots) release(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used -= n
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *engineSlots) free() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capacity - s.used
+}
+
+func (s *engineSlots) inUse() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+// wait returns a channel closed the next time slots are released.
+func (s *engineSlots) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+func (s *engineSlots) fullError() error {
+	return fmt.Errorf("maximum number of engines (%d) running", s.capacity)
+}
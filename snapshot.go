@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+const snapshotVersion = 1
+
+// SessionState is the logical state of a persistent session: what a client
+// set up through it, enough to recreate the session on a new engine process.
+type SessionState struct {
+	ID     string `json:"id"`
+	Engine string `json:"engine"`
+	Owner  string `json:"owner,omitempty"`
+	// Options are the options set by the client, latest value per name, in
+	// the order they were first set. The engine's configured options are
+	// not included as a new process gets them anyway.
+	Options []EngineOptionValue `json:"options,omitempty"`
+	// Position is the last "position" command; the fields below describe
+	// the game it sets up when it could be parsed.
+	Position  string    `json:"position,omitempty"`
+	StartFEN  string    `json:"start_fen,omitempty"`
+	Moves     []string  `json:"moves,omitempty"`
+	SAN       []string  `json:"san,omitempty"`
+	FEN       string    `json:"fen,omitempty"`
+	NewGames  int       `json:"new_games,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// SessionSnapshot is the file written on shutdown and periodically.
+type SessionSnapshot struct {
+	Version  int            `json:"version"`
+	SavedAt  time.Time      `json:"saved_at"`
+	Sessions []SessionState `json:"sessions"`
+}
+
+// record updates the state after command succeeded.
+func (s *StockfishSession) record(command string) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	s.state.LastUsed = time.Now()
+	switch strings.ToLower(fields[0]) {
+	case StockfishCmdSetOption:
+		name, value, ok := parseSetOption(command)
+		if !ok || value == "" {
+			return // buttons are actions, not state
+		}
+		for i := range s.state.Options {
+			if strings.EqualFold(s.state.Options[i].Name, name) {
+				s.state.Options[i].Value = value
+				return
+			}
+		}
+		s.state.Options = append(s.state.Options, EngineOptionValue{Name: name, Value: value})
+	case StockfishCmdPosition:
+		s.state.Position = strings.TrimSpace(command)
+		s.state.StartFEN, s.state.Moves, s.state.SAN, s.state.FEN = "", nil, nil, ""
+		if game, err := parsePositionCommand(command); err == nil {
+			s.state.StartFEN = game.Start().FEN()
+			s.state.Moves = game.UCIMoves()
+			s.state.SAN = game.SANs()
+			s.state.FEN = game.Position().FEN()
+		}
+	case StockfishCmdUCINewGame:
+		s.state.NewGames++
+	}
+}
+
+// parseSetOption splits "setoption name <name> [value <value>]".
+func parseSetOption(command string) (name, value string, ok bool) {
+	fields := strings.Fields(command)
+	if len(fields) < 3 || strings.ToLower(fields[1]) != "name" {
+		return "", "", false
+	}
+	rest := fields[2:]
+	for i, f := range rest {
+		if strings.ToLower(f) == "value" {
+			return strings.Join(rest[:i], " "), strings.Join(rest[i+1:], " "), i > 0
+		}
+	}
+	return strings.Join(rest, " "), "", true
+}
+
+// State returns a copy of the session's logical state.
+func (s *StockfishSession) State() SessionState {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	state := s.state
+	state.Options = append([]EngineOptionValue(nil), s.state.Options...)
+	return state
+}
+
+// replay brings a new session to a saved state: the client's options, then
+// the position.
+func (s *StockfishSession) replay(state SessionState, timeout time.Duration) error {
+	for _, opt := range state.Options {
+		if err := s.writeCommand(uci.FormatSetOption(opt.Name, opt.Value)); err != nil {
+			return err
+		}
+	}
+	if state.Position != "" {
+		if err := s.syncVariant(state.Position); err != nil {
+			return err
+		}
+		if err := s.writeCommand(state.Position); err != nil {
+			return err
+		}
+	}
+	if _, err := s.executeCommand(context.Background(), StockfishCmdIsReady, timeout); err != nil {
+		return fmt.Errorf("engine not ready after replaying state: %w", err)
+	}
+
+	s.stateMu.Lock()
+	s.state = state
+	s.state.Options = append([]EngineOptionValue(nil), state.Options...)
+	s.stateMu.Unlock()
+	s.setOwner(state.Owner)
+	return nil
+}
+
+func (s *StockfishSession) setOwner(owner string) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	s.Owner = owner
+	s.state.Owner = owner
+}
+
+// Snapshot returns the state of every live session plus the saved ones
+// not yet recreated.
+func (sm *SessionManager) Snapshot() SessionSnapshot {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	snapshot := SessionSnapshot{
+		Version:  snapshotVersion,
+		SavedAt:  time.Now().UTC(),
+		Sessions: []SessionState{},
+	}
+	for _, session := range sm.sessions {
+		snapshot.Sessions = append(snapshot.Sessions, session.State())
+	}
+	for _, state := range sm.restorable {
+		snapshot.Sessions = append(snapshot.Sessions, state)
+	}
+	return snapshot
+}
+
+// saveSnapshot writes the snapshot file, replacing the previous one only
+// once the new one is complete.
+func (sm *SessionManager) saveSnapshot() error {
+	path := sm.config.SnapshotPath
+	if path == "" {
+		return nil
+	}
+	snapshot := sm.Snapshot()
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write session snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write session snapshot: %w", err)
+	}
+
+	sm.logger.Debug().
+		Str("path", path).
+		Int("sessions", len(snapshot.Sessions)).
+		Msg("Session snapshot saved")
+	return nil
+}
+
+// loadSnapshot reads the sessions saved by a previous run. They are
+// recreated when their clients come back; those expired by now are dropped.
+func (sm *SessionManager) loadSnapshot() error {
+	path := sm.config.SnapshotPath
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session snapshot: %w", err)
+	}
+
+	var snapshot SessionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("invalid session snapshot %s: %w", path, err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("session snapshot %s has version %d, want %d", path, snapshot.Version, snapshotVersion)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, state := range snapshot.Sessions {
+		if state.ID == "" || sm.stateExpired(state) {
+			continue
+		}
+		sm.restorable[state.ID] = state
+	}
+	sm.logger.Info().
+		Str("path", path).
+		Int("saved", len(snapshot.Sessions)).
+		Int("restorable", len(sm.restorable)).
+		Msg("Session snapshot loaded")
+	return nil
+}
+
+// stateExpired reports whether a saved session has outlived its engine's
+// session timeout.
+func (sm *SessionManager) stateExpired(state SessionState) bool {
+	timeout := sm.config.SessionTimeout
+	if engine, ok := sm.config.Engines[state.Engine]; ok && engine.SessionTimeout > 0 {
+		timeout = engine.SessionTimeout
+	}
+	return time.Since(state.LastUsed) > timeout
+}
+
+// restoreSession recreates a saved session under its original ID on the
+// slot reserveSession took for it. It runs without sm.mu held, since
+// replaying the state waits for the engine.
+func (sm *SessionManager) restoreSession(state SessionState, engine EngineConfig) (*StockfishSession, error) {
+	session, err := sm.createSession(state.ID, engine)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.replay(state, sm.config.CommandTimeout); err != nil {
+		session.close()
+		return nil, fmt.Errorf("failed to restore session %s: %w", state.ID, err)
+	}
+
+	sm.logger.Info().
+		Str("session_id", state.ID).
+		Str("engine", engine.Name).
+		Int("options", len(state.Options)).
+		Int("moves", len(state.Moves)).
+		Msg("Restored session from snapshot")
+	return session, nil
+}
+
+func (sm *SessionManager) snapshotRoutine() {
+	ticker := time.NewTicker(sm.config.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := sm.saveSnapshot(); err != nil {
+				sm.logger.Warn().Err(err).Msg("Periodic session snapshot failed")
+			}
+		case <-sm.stopCleanupCh:
+			return
+		}
+	}
+}
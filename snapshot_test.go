@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestSessionRestoredAfterRestart(t *testing.T) {
+	snapshotPath := filepath.Join(t.TempDir(), "sessions.json")
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	config.SnapshotPath = snapshotPath
+
+	sm := newSessionManager(config, newEngineRegistry(config, zerolog.Nop()), zerolog.Nop())
+	executor := NewPersistentSessionExecutor(sm, zerolog.Nop())
+	id, _, _, err := executor.Execute(context.Background(), testEngine, "setoption name Hash value 64", "", PriorityInteractive, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{
+		"setoption name Clear Hash",
+		"setoption name MultiPV value 3",
+		"ucinewgame",
+		"setoption name hash value 128",
+		"position startpos moves e2e4 e7e5",
+	} {
+		if _, _, _, err := executor.Execute(context.Background(), testEngine, command, id, PriorityInteractive, 0); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+	}
+	sm.Close()
+
+	// The next run starts a new engine process, replaying the session's
+	// state before the client's first command.
+	engine := fakeEngine(t, ucitest.Script{})
+	config = testConfig(engine)
+	config.SnapshotPath = snapshotPath
+	sm = newTestSessionManager(t, config)
+	if _, ok := sm.restorable[id]; !ok || len(sm.sessions) != 0 {
+		t.Fatalf("session %s not restorable: %v", id, sm.restorable)
+	}
+
+	executor = NewPersistentSessionExecutor(sm, zerolog.Nop())
+	got, responses, _, err := executor.Execute(context.Background(), testEngine, "go depth 2", id, PriorityInteractive, 0)
+	if err != nil || got != id {
+		t.Fatalf("go: session %s, %v", got, err)
+	}
+	if responses[len(responses)-1] != "bestmove e2e4" {
+		t.Errorf("go: %q", responses)
+	}
+
+	want := []string{
+		"setoption name Hash value 128",
+		"setoption name MultiPV value 3",
+		"position startpos moves e2e4 e7e5",
+		"isready",
+		"go depth 2",
+	}
+	if log := commandLog(t, engine); !slices.Equal(log, want) {
+		t.Errorf("engine received %q, want %q", log, want)
+	}
+
+	state := sm.sessions[id].State()
+	if state.NewGames != 1 || !slices.Equal(state.SAN, []string{"e4", "e5"}) ||
+		state.FEN != "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2" {
+		t.Errorf("restored state = %+v", state)
+	}
+}
+
+func TestExpiredSnapshotStatesDropped(t *testing.T) {
+	engine := fakeEngine(t, ucitest.Script{})
+	config := testConfig(engine)
+	config.SnapshotPath = filepath.Join(t.TempDir(), "sessions.json")
+
+	sm := newSessionManager(config, newEngineRegistry(config, zerolog.Nop()), zerolog.Nop())
+	sm.restorable["stale"] = SessionState{ID: "stale", Engine: testEngine, LastUsed: time.Now().Add(-time.Hour)}
+	sm.restorable["fresh"] = SessionState{ID: "fresh", Engine: testEngine, LastUsed: time.Now()}
+	sm.Close()
+
+	sm = newTestSessionManager(t, config)
+	if _, ok := sm.restorable["stale"]; ok {
+		t.Error("expired session restorable")
+	}
+	if _, ok := sm.restorable["fresh"]; !ok {
+		t.Error("fresh session not restorable")
+	}
+}
+
+func TestSlowRestoreDoesNotBlockOtherSessions(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{ReadyDelay: 500 * time.Millisecond}))
+	sm := newTestSessionManager(t, config)
+	sm.restorable["slow"] = SessionState{ID: "slow", Engine: testEngine, LastUsed: time.Now()}
+
+	restored := make(chan *StockfishSession, 2)
+	for range 2 {
+		go func() {
+			session, _, err := sm.getOrCreateSession(context.Background(), "slow", "", PriorityInteractive)
+			if err != nil {
+				t.Error(err)
+			}
+			restored <- session
+		}()
+	}
+	waitFor(t, func() bool {
+		sm.mu.RLock()
+		defer sm.mu.RUnlock()
+		return sm.pending["slow"] != nil
+	})
+
+	started := time.Now()
+	if _, _, err := sm.getOrCreateSession(context.Background(), "fast", "", PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 250*time.Millisecond {
+		t.Errorf("new session waited %v for the restore", elapsed)
+	}
+
+	// Both requests for the restored session get the same engine.
+	if a, b := <-restored, <-restored; a == nil || a != b {
+		t.Errorf("restored sessions %p and %p", a, b)
+	}
+	if pool := sm.PoolStatus(); pool.Active != 2 || pool.Engines != 2 {
+		t.Errorf("pool = %+v", pool)
+	}
+}
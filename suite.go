@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+const defaultSuiteMoveTime = time.Second
+
+type SuiteConfig struct {
+	Engine   string
+	MoveTime time.Duration
+	Depth    int
+	Nodes    int
+	// Workers is the number of engines searching in parallel, capped by the
+	// session limits.
+	Workers int
+}
+
+func (cfg *SuiteConfig) limits() uci.Limits {
+	return uci.Limits{MoveTime: cfg.MoveTime, Depth: cfg.Depth, Nodes: int64(cfg.Nodes)}
+}
+
+// SuitePosition is the outcome of one EPD record.
+type SuitePosition struct {
+	Index      int      `json:"index"`
+	ID         string   `json:"id,omitempty"`
+	FEN        string   `json:"fen"`
+	BestMoves  []string `json:"bm,omitempty"`
+	AvoidMoves []string `json:"am,omitempty"`
+	Move       string   `json:"move,omitempty"`
+	Solved     bool     `json:"solved"`
+	// SolveTimeMS is when the engine settled on a correct move for good,
+	// taken from the "time" of the first info line of that final stretch.
+	SolveTimeMS *int64 `json:"solve_time_ms,omitempty"`
+	Depth       int    `json:"depth,omitempty"`
+	ScoreCP     *int   `json:"score_cp,omitempty"`
+	Mate        *int   `json:"mate,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type SuiteResult struct {
+	Status    string          `json:"status"`
+	Engine    string          `json:"engine"`
+	Budget    string          `json:"budget"`
+	Workers   int             `json:"workers"`
+	Total     int             `json:"total"`
+	Solved    int             `json:"solved"`
+	Failed    int             `json:"failed"`
+	Errors    int             `json:"errors"`
+	Score     float64         `json:"score_percent"`
+	ElapsedMS int64           `json:"elapsed_ms"`
+	Positions []SuitePosition `json:"positions"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// SuiteRunner runs EPD test suites on engine processes of its own, one per
+// worker, outside of the session pool.
+type SuiteRunner struct {
+	engines        *EngineRegistry
+	maxSessions    int
+	commandTimeout time.Duration
+	logger         zerolog.Logger
+}
+
+func newSuiteRunner(
+	engines *EngineRegistry,
+	maxSessions int,
+	commandTimeout time.Duration,
+	logger zerolog.Logger,
+) *SuiteRunner {
+	return &SuiteRunner{
+		engines:        engines,
+		maxSessions:    maxSessions,
+		commandTimeout: commandTimeout,
+		logger:         logger.With().Str("component", ComponentSuiteRunner).Logger(),
+	}
+}
+
+// Run searches every record with the same budget, spreading them over up to
+// MaxSessions engines. Records left when ctx is cancelled are reported as
+// errors and the result is marked partial.
+func (r *SuiteRunner) Run(
+	ctx context.Context,
+	records []EPDRecord,
+	cfg SuiteConfig,
+) (*SuiteResult, error) {
+	engine, err := r.engines.Get(cfg.Engine)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MoveTime <= 0 && cfg.Depth <= 0 && cfg.Nodes <= 0 {
+		cfg.MoveTime = defaultSuiteMoveTime
+	}
+
+	workers := min(r.maxSessions, engine.MaxSessions, len(records))
+	if cfg.Workers > 0 {
+		workers = min(workers, cfg.Workers)
+	}
+	workers = max(workers, 1)
+
+	// Like batches, suites run on the engine slots that are free.
+	workers, release, err := r.engines.reserveUpTo(ctx, workers)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	limits := cfg.limits()
+	result := &SuiteResult{
+		Status:    "success",
+		Engine:    engine.Name,
+		Budget:    strings.TrimPrefix(limits.String(), StockfishCmdGo+" "),
+		Workers:   workers,
+		Total:     len(records),
+		Positions: make([]SuitePosition, len(records)),
+	}
+
+	r.logger.Info().
+		Str("engine", engine.Name).
+		Int("positions", len(records)).
+		Int("workers", workers).
+		Str("budget", result.Budget).
+		Msg("Test suite started")
+
+	started := time.Now()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			player := &enginePlayer{config: engine, logger: r.logger}
+			defer player.close()
+			for i := range jobs {
+				result.Positions[i] = r.runPosition(ctx, player, i, records[i], limits)
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(records); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	result.ElapsedMS = time.Since(started).Milliseconds()
+
+	for i := next; i < len(records); i++ {
+		result.Positions[i] = suitePosition(i, records[i])
+		result.Positions[i].Error = "not run: " + ctx.Err().Error()
+	}
+	if next < len(records) {
+		result.Status = "partial"
+		result.Error = ctx.Err().Error()
+	}
+
+	for _, p := range result.Positions {
+		switch {
+		case p.Error != "":
+			result.Errors++
+		case p.Solved:
+			result.Solved++
+		default:
+			result.Failed++
+		}
+	}
+	if result.Total > 0 {
+		result.Score = math.Round(1000*float64(result.Solved)/float64(result.Total)) / 10
+	}
+
+	r.logger.Info().
+		Int("solved", result.Solved).
+		Int("failed", result.Failed).
+		Int("errors", result.Errors).
+		Int64("elapsed_ms", result.ElapsedMS).
+		Msg("Test suite finished")
+
+	return result, nil
+}
+
+func suitePosition(index int, record EPDRecord) SuitePosition {
+	return SuitePosition{
+		Index:      index + 1,
+		ID:         record.ID,
+		FEN:        record.FEN,
+		BestMoves:  record.BestMoves,
+		AvoidMoves: record.AvoidMoves,
+	}
+}
+
+func (r *SuiteRunner) runPosition(
+	ctx context.Context,
+	player *enginePlayer,
+	index int,
+	record EPDRecord,
+	limits uci.Limits,
+) SuitePosition {
+	result := suitePosition(index, record)
+	pos := record.position
+
+	if err := player.newGame(r.commandTimeout); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	lines, err := player.searchLines(ctx, newGame(pos).PositionCommand(), limits, r.commandTimeout+limits.MoveTime)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	search := parseSearchOutput(lines)
+	m, err := pos.ParseUCI(search.BestMove)
+	if err != nil {
+		result.Error = fmt.Sprintf("engine returned an illegal move: %s", search.BestMove)
+		return result
+	}
+
+	result.Move = pos.SAN(m)
+	result.Solved = record.accepts(m)
+	if line := search.Best(); line != nil {
+		result.Depth, result.ScoreCP, result.Mate = line.Depth, line.ScoreCP, line.Mate
+	}
+	if result.Solved {
+		result.SolveTimeMS = solveTime(pos, record, lines)
+	}
+	return result
+}
+
+// accepts reports whether m satisfies the record's bm and am operations.
+func (e *EPDRecord) accepts(m Move) bool {
+	if len(e.best) > 0 && !containsMove(e.best, m) {
+		return false
+	}
+	return !containsMove(e.avoid, m)
+}
+
+func containsMove(moves []Move, m Move) bool {
+	for _, candidate := range moves {
+		if candidate == m {
+			return true
+		}
+	}
+	return false
+}
+
+// solveTime walks the main-line info lines and returns the time of the
+// first one after which the PV always started with an accepted move.
+func solveTime(pos *Position, record EPDRecord, lines []string) *int64 {
+	var since *int64
+	for _, line := range lines {
+		info, ok := parseInfoLine(line)
+		if !ok || info.MultiPV != 1 || len(info.PV) == 0 {
+			continue
+		}
+		m, err := pos.ParseUCI(info.PV[0])
+		switch {
+		case err != nil || !record.accepts(m):
+			since = nil
+		case since == nil:
+			t := info.TimeMS
+			since = &t
+		}
+	}
+	if since == nil {
+		zero := int64(0)
+		return &zero
+	}
+	return since
+}
+
+func (r *SuiteRunner) handle(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	text, err := request.RequireString("epd")
+	if err != nil {
+		return mcp.NewToolResultError("Missing 'epd' parameter"), nil
+	}
+	records, err := parseEPD(text)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid EPD: %s", err.Error())), nil
+	}
+	if len(records) == 0 {
+		return mcp.NewToolResultError("No positions in EPD"), nil
+	}
+	if limit := request.GetInt("max_positions", 0); limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+
+	cfg := SuiteConfig{
+		Engine:   request.GetString("engine", ""),
+		MoveTime: time.Duration(request.GetInt("movetime", 0)) * time.Millisecond,
+		Depth:    request.GetInt("depth", 0),
+		Nodes:    request.GetInt("nodes", 0),
+		Workers:  request.GetInt("workers", 0),
+	}
+
+	r.logger.Info().
+		Int("positions", len(records)).
+		Str("engine", cfg.Engine).
+		Msg("Received test suite request")
+
+	result, err := r.Run(ctx, records, cfg)
+	if err != nil {
+		r.logger.Warn().Err(err).Msg("Test suite failed")
+		return mcp.NewToolResultError(fmt.Sprintf("Test suite failed: %s", err.Error())), nil
+	}
+	return jsonToolResult(result, r.logger)
+}
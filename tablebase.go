@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+// Syzygy WDL values, from the point of view of the side to move. Cursed
+// wins and blessed losses are wins/losses that the fifty-move rule turns
+// into draws.
+const (
+	WDLLoss        = -2
+	WDLBlessedLoss = -1
+	WDLDraw        = 0
+	WDLCursedWin   = 1
+	WDLWin         = 2
+)
+
+var wdlNames = map[int]string{
+	WDLLoss:        "loss",
+	WDLBlessedLoss: "blessed_loss",
+	WDLDraw:        "draw",
+	WDLCursedWin:   "cursed_win",
+	WDLWin:         "win",
+}
+
+// Engines report tablebase wins far above any evaluation: Stockfish uses
+// 20000 minus the distance in plies, older versions around 15000.
+const tablebaseWinCP = 10000
+
+type TablebaseMove struct {
+	UCI      string `json:"uci"`
+	SAN      string `json:"san"`
+	WDL      int    `json:"wdl"`
+	Category string `json:"category"`
+	ScoreCP  *int   `json:"score_cp,omitempty"`
+	Mate     *int   `json:"mate,omitempty"`
+}
+
+type TablebaseResult struct {
+	Status   string `json:"status"`
+	FEN      string `json:"fen"`
+	Engine   string `json:"engine"`
+	Pieces   int    `json:"pieces"`
+	WDL      int    `json:"wdl"`
+	Category string `json:"category"`
+	// Moves within a WDL class keep the engine's ranking, which follows
+	// DTZ; the DTZ values themselves are not part of UCI.
+	BestMoves []TablebaseMove `json:"best_moves"`
+	Moves     []TablebaseMove `json:"moves"`
+	TBHits    int64           `json:"tbhits"`
+	Source    string          `json:"source"`
+}
+
+// TablebaseProber answers tablebase queries through an engine configured
+// with SyzygyPath: when the root is in the tablebases, the engine ranks
+// every root move by its tablebase value and reports it as the score.
+type TablebaseProber struct {
+	engines        *EngineRegistry
+	config         StockfishConfig
+	commandTimeout time.Duration
+	logger         zerolog.Logger
+}
+
+func newTablebaseProber(
+	config StockfishConfig,
+	engines *EngineRegistry,
+	logger zerolog.Logger,
+) *TablebaseProber {
+	return &TablebaseProber{
+		engines:        engines,
+		config:         config,
+		commandTimeout: config.CommandTimeout,
+		logger:         logger.With().Str("component", ComponentTablebase).Logger(),
+	}
+}
+
+func (t *TablebaseProber) Probe(ctx context.Context, fen, engineName string) (*TablebaseResult, error) {
+	if t.config.SyzygyPath == "" {
+		return nil, fmt.Errorf("no tablebases configured (set MCP_STOCKFISH_SYZYGY_PATH)")
+	}
+
+	pos, err := parseFEN(fen)
+	if err != nil {
+		return nil, err
+	}
+	if pos.castling != [2][2]Square{{NoSquare, NoSquare}, {NoSquare, NoSquare}} {
+		return nil, fmt.Errorf("positions with castling rights are not in the tablebases")
+	}
+	pieces := pos.pieceCount()
+	if pieces > t.config.SyzygyProbeLimit {
+		return nil, fmt.Errorf(
+			"position has %d pieces, tablebases cover at most %d",
+			pieces,
+			t.config.SyzygyProbeLimit,
+		)
+	}
+
+	engine, err := t.engines.Get(engineName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TablebaseResult{
+		Status:    "success",
+		FEN:       pos.FEN(),
+		Engine:    engine.Name,
+		Pieces:    pieces,
+		BestMoves: []TablebaseMove{},
+		Moves:     []TablebaseMove{},
+		Source:    "engine",
+	}
+
+	legal := pos.LegalMoves()
+	if len(legal) == 0 {
+		result.WDL = WDLDraw
+		if pos.InCheck() {
+			result.WDL = WDLLoss
+		}
+		result.Category = wdlNames[result.WDL]
+		result.Source = "board"
+		return result, nil
+	}
+
+	engine.Options = append(
+		append([]EngineOptionValue(nil), engine.Options...),
+		EngineOptionValue{Name: "MultiPV", Value: strconv.Itoa(len(legal))},
+	)
+	release, err := t.engines.reserve(ctx, 1, PriorityInteractive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	player := &enginePlayer{config: engine, logger: t.logger}
+	defer player.close()
+
+	search, err := player.search(
+		ctx,
+		newGame(pos).PositionCommand(),
+		uci.Limits{Depth: 1},
+		t.commandTimeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range search.Lines {
+		result.TBHits = max(result.TBHits, line.TBHits)
+	}
+	if result.TBHits == 0 {
+		return nil, fmt.Errorf("position not found in the tablebases at %q", t.config.SyzygyPath)
+	}
+
+	for _, line := range search.Lines {
+		if len(line.PV) == 0 {
+			continue
+		}
+		m, err := pos.ParseUCI(line.PV[0])
+		if err != nil {
+			continue
+		}
+		wdl := lineWDL(line)
+		result.Moves = append(result.Moves, TablebaseMove{
+			UCI:      pos.UCI(m),
+			SAN:      pos.SAN(m),
+			WDL:      wdl,
+			Category: wdlNames[wdl],
+			ScoreCP:  line.ScoreCP,
+			Mate:     line.Mate,
+		})
+	}
+	if len(result.Moves) == 0 {
+		return nil, fmt.Errorf("engine reported no tablebase moves")
+	}
+
+	// Keep the engine's ranking within a WDL class.
+	sort.SliceStable(result.Moves, func(i, j int) bool {
+		return result.Moves[i].WDL > result.Moves[j].WDL
+	})
+	result.WDL = result.Moves[0].WDL
+	result.Category = wdlNames[result.WDL]
+	for _, m := range result.Moves {
+		if m.WDL == result.WDL {
+			result.BestMoves = append(result.BestMoves, m)
+		}
+	}
+
+	return result, nil
+}
+
+// lineWDL classifies a tablebase-ranked score.
+func lineWDL(line InfoLine) int {
+	switch {
+	case line.Mate != nil && *line.Mate > 0:
+		return WDLWin
+	case line.Mate != nil:
+		return WDLLoss
+	case line.ScoreCP == nil:
+		return WDLDraw
+	case *line.ScoreCP >= tablebaseWinCP:
+		return WDLWin
+	case *line.ScoreCP > 0:
+		return WDLCursedWin
+	case *line.ScoreCP <= -tablebaseWinCP:
+		return WDLLoss
+	case *line.ScoreCP < 0:
+		return WDLBlessedLoss
+	}
+	return WDLDraw
+}
+
+func (t *TablebaseProber) handle(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	fen, err := request.RequireString("fen")
+	if err != nil {
+		return mcp.NewToolResultError("Missing 'fen' parameter"), nil
+	}
+	engineName := request.GetString("engine", "")
+
+	t.logger.Info().Str("fen", fen).Str("engine", engineName).Msg("Received tablebase probe")
+
+	result, err := t.Probe(ctx, fen, engineName)
+	if err != nil {
+		t.logger.Warn().Err(err).Str("fen", fen).Msg("Tablebase probe failed")
+		return mcp.NewToolResultError(fmt.Sprintf("Tablebase probe failed: %s", err.Error())), nil
+	}
+	return jsonToolResult(result, t.logger)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func newTestProber(t *testing.T, script ucitest.Script) *TablebaseProber {
+	t.Helper()
+	config := testConfig(fakeEngine(t, script))
+	config.SyzygyPath = t.TempDir()
+	config.SyzygyProbeLimit = 7
+	return newTablebaseProber(config, newEngineRegistry(config, zerolog.Nop()), zerolog.Nop())
+}
+
+func TestProbeTablebase(t *testing.T) {
+	prober := newTestProber(t, ucitest.Script{
+		BestMove: "b2b1",
+		Depth:    1,
+		Info: strings.Join([]string{
+			"info depth 1 multipv 1 score cp 19990 nodes 10 tbhits 3 pv b2b1",
+			"info depth 1 multipv 2 score cp 19980 nodes 10 tbhits 3 pv a1a2",
+			"info depth 1 multipv 3 score cp 0 nodes 10 tbhits 3 pv b2h2",
+			"info depth 1 multipv 4 score cp 150 nodes 10 tbhits 3 pv a1b1",
+		}, ";"),
+	})
+
+	result, err := prober.Probe(context.Background(), "8/8/8/8/8/8/1Q6/K6k w - - 0 1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WDL != WDLWin || result.Category != "win" || result.Pieces != 3 || result.TBHits != 3 {
+		t.Errorf("result = %+v", result)
+	}
+	var best []string
+	for _, m := range result.BestMoves {
+		best = append(best, m.SAN)
+	}
+	if strings.Join(best, " ") != "Qb1+ Ka2" {
+		t.Errorf("best moves = %v", best)
+	}
+	var categories []string
+	for _, m := range result.Moves {
+		categories = append(categories, m.UCI+":"+m.Category)
+	}
+	if got := strings.Join(categories, " "); got != "b2b1:win a1a2:win a1b1:cursed_win b2h2:draw" {
+		t.Errorf("moves = %s", got)
+	}
+}
+
+func TestProbeTablebaseRejects(t *testing.T) {
+	prober := newTestProber(t, ucitest.Script{})
+
+	tests := []struct {
+		fen     string
+		wantErr string
+	}{
+		{startFEN, "castling rights"},
+		{"r3k3/pppppppp/8/8/8/8/8/4K3 w - - 0 1", "11 pieces"},
+		{"8/8/8/8/8/8/1Q6/K6k w - - 0 1", "not found in the tablebases"},
+	}
+	for _, tt := range tests {
+		if _, err := prober.Probe(context.Background(), tt.fen, ""); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: %v", tt.fen, err)
+		}
+	}
+
+	// Finished games are answered from the board.
+	result, err := prober.Probe(context.Background(), "7k/6Q1/6K1/8/8/8/8/8 b - - 0 1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != "board" || result.WDL != WDLLoss {
+		t.Errorf("checkmate: %+v", result)
+	}
+}
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+func newChessEngineTool(engines *EngineRegistry) mcp.Tool {
+	return mcp.NewTool(
+		"chess_engine",
+		mcp.WithDescription(`
+Advanced chess analysis using Stockfish engine via UCI (Universal Chess Interface).
+Analyzes positions, finds best moves, evaluates positions. Returns structured results.
+
+MOVE NOTATION: Use algebraic notation (e2e4, g1f3, e1g1 for castling, e7e8q for promotion)
+EVALUATION: Centipawns (100 = 1 pawn), positive = White advantage, negative = Black advantage
+
+═══ UCI COMMAND REFERENCE ═══
+
+ENGINE CONTROL:
+┌─ uci           → Initialize engine, get info & options
+├─ isready       → Check if engine ready for commands  
+├─ quit          → Shutdown engine
+└─ stop          → Stop current analysis immediately
+
+POSITION SETUP:
+┌─ position startpos                    → Initial chess position
+├─ position startpos moves e2e4 e7e5    → Initial + move sequence
+├─ position fen [FEN]                   → Custom position from FEN
+└─ position fen [FEN] moves [MOVES]     → Custom FEN + additional moves
+
+ANALYSIS COMMANDS:
+┌─ go depth [N]       → Analyze N plies deep (typical: 15-25)
+├─ go movetime [MS]   → Analyze for N milliseconds (typical: 3000-10000)
+├─ go infinite       → Analyze until stopped (use 'stop' to end)
+├─ go wtime [MS] btime [MS]  → Analysis with time controls
+└─ go nodes [N]      → Analyze exactly N nodes
+`+describeEngineOptions(engines)+`
+TYPICAL WORKFLOWS:
+1. Quick analysis:    "position startpos moves e2e4" → "go movetime 3000"
+2. Deep analysis:     "position startpos" → "go depth 25" 
+3. Custom position:   "position fen r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq -" → "go depth 20"
+4. Multi-line:        "setoption name MultiPV value 3" → "position startpos" → "go depth 18"
+5. Weaker play:       "setoption name Skill Level value 10" → "position startpos" → "go depth 15"
+
+EXAMPLES:
+• "uci" → Get engine info
+• "position startpos moves e2e4 e7e5 g1f3 b8c6" → Set position  
+• "go depth 18" → Deep analysis
+• "setoption name Hash value 512" → Increase memory
+• "setoption name MultiPV value 5" → Show top 5 moves
+		`+describeEngines(engines)),
+		mcp.WithString(
+			"command",
+			mcp.Required(),
+			mcp.Description(`
+Exact UCI command to execute. Use commands from the reference above.
+
+QUICK REFERENCE:
+• uci, isready, quit, stop
+• position startpos [moves MOVE_LIST]  
+• position fen FEN_STRING [moves MOVE_LIST]
+• go depth N | go movetime MS | go infinite
+• setoption name OPTION_NAME value VALUE
+
+MOVE FORMAT: e2e4 e7e5 g1f3 (algebraic notation)
+EXAMPLES: "position startpos moves e2e4", "go depth 15", "setoption name Hash value 256"
+			`),
+		),
+		mcp.WithString(
+			"session_id",
+			mcp.Description(
+				"Session to run the command in, as returned by a previous call; omit it to start a new one. "+
+					"Sessions saved before a server restart resume under the same ID, "+
+					"and session_transcript shows the commands sent to it.",
+			),
+		),
+		mcp.WithString(
+			"engine",
+			mcp.Description(
+				"Configured engine to run the command on (default: the default engine). See list_engines.",
+			),
+			mcp.Enum(engines.Names()...),
+		),
+		mcp.WithBoolean(
+			"use_book",
+			mcp.Description(
+				`Report opening book moves for "position" commands in a "book" field (default: server setting)`,
+			),
+		),
+		mcp.WithBoolean(
+			"render",
+			mcp.Description(`Add a Unicode diagram of the position to "position" command results in a "board" field`),
+		),
+		mcp.WithString(
+			"priority",
+			mcp.Description("Queue priority when all sessions are busy (default: interactive). Advisory: set batch for bulk work so interactive requests go first"),
+			mcp.Enum(string(PriorityInteractive), string(PriorityBatch)),
+		),
+	)
+}
+
+// describeEngineOptions documents the options each engine announced in its
+// "uci" reply; "setoption" commands are checked against the same list.
+func describeEngineOptions(engines *EngineRegistry) string {
+	var b strings.Builder
+	for _, name := range engines.Names() {
+		info, _ := engines.Status(name)
+		if info == nil || len(info.Options) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\nENGINE OPTIONS of %s (setoption name [NAME] value [VALUE]):\n", name)
+		width := 0
+		for _, opt := range info.Options {
+			width = max(width, utf8.RuneCountInString(opt.Name))
+		}
+		for i, opt := range info.Options {
+			branch := "├─"
+			switch {
+			case i == len(info.Options)-1:
+				branch = "└─"
+			case i == 0:
+				branch = "┌─"
+			}
+			fmt.Fprintf(&b, "%s %-*s → %s\n", branch, width, opt.Name, formatOption(opt))
+		}
+	}
+	if b.Len() == 0 {
+		return "\nENGINE OPTIONS: send \"uci\" to list them, then \"setoption name [NAME] value [VALUE]\".\n"
+	}
+	return b.String()
+}
+
+// formatOption describes an option's type, range and default, e.g.
+// "spin 1 to 1024, default 1".
+func formatOption(opt uci.Option) string {
+	desc := opt.Type
+	switch {
+	case opt.Type == "combo" && len(opt.Vars) > 0:
+		desc += " " + strings.Join(opt.Vars, "|")
+	case opt.Min != "" || opt.Max != "":
+		desc += fmt.Sprintf(" %s to %s", defaultString(opt.Min, "?"), defaultString(opt.Max, "?"))
+	}
+	if opt.Type != "button" {
+		desc += ", default " + defaultString(opt.Default, "empty")
+	}
+	return desc
+}
+
+// describeEngines lists the engines as identified by the startup check.
+func describeEngines(engines *EngineRegistry) string {
+	var b strings.Builder
+	b.WriteString("\n═══ ENGINES ON THIS SERVER ═══\n")
+	for _, name := range engines.Names() {
+		fmt.Fprintf(&b, "• %s", name)
+		if name == engines.DefaultName() {
+			b.WriteString(" (default)")
+		}
+		info, err := engines.Status(name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(&b, ": UNAVAILABLE, failed its startup check: %v\n", err)
+			continue
+		case info == nil:
+			b.WriteString("\n")
+			continue
+		}
+
+		b.WriteString(": " + defaultString(info.IDName, "unnamed engine"))
+		if info.IDAuthor != "" {
+			b.WriteString(" by " + info.IDAuthor)
+		}
+		switch info.NNUE {
+		case NNUEEnabled:
+			b.WriteString(", NNUE enabled")
+			if len(info.Networks) > 0 {
+				b.WriteString(" (" + strings.Join(info.Networks, ", ") + ")")
+			}
+		case NNUEDisabled:
+			b.WriteString(", classical evaluation")
+		case NNUEError:
+			b.WriteString(", NNUE network error: " + info.NNUEMessage)
+		}
+		fmt.Fprintf(&b, ", %d options\n", len(info.Options))
+	}
+	return b.String()
+}
+
+func newListEnginesTool() mcp.Tool {
+	return mcp.NewTool(
+		"list_engines",
+		mcp.WithDescription(`
+Lists the UCI engines configured on this server. For each engine reports the
+name to pass as "engine" to other tools, plus the "id name", "id author" and
+option list the engine announces in its "uci" reply, and whether it evaluates
+with NNUE and which network files it loaded.
+		`),
+	)
+}
+
+func newRunMatchTool() mcp.Tool {
+	return mcp.NewTool(
+		"run_match",
+		mcp.WithDescription(`
+Plays an engine-vs-engine match between two engine configurations and reports
+wins/draws/losses for engine A, the Elo difference with a 95% error margin,
+and the PGN of every game.
+
+Each opening is played twice with colors reversed. Games end on checkmate,
+stalemate, threefold repetition, the fifty-move rule or insufficient
+material, and can optionally be adjudicated by score.
+
+EXAMPLE: engine_a="stockfish", options_a="Skill Level=5",
+         engine_b="stockfish", options_b="Skill Level=10", games=10, movetime=100
+		`),
+		mcp.WithString("engine_a", mcp.Description("Engine for player A (default: the default engine)")),
+		mcp.WithString("engine_b", mcp.Description("Engine for player B (default: the default engine)")),
+		mcp.WithString(
+			"options_a",
+			mcp.Description(`Options for player A, e.g. "Skill Level=5;Hash=64"`),
+		),
+		mcp.WithString(
+			"options_b",
+			mcp.Description(`Options for player B, e.g. "Skill Level=10;Hash=64"`),
+		),
+		mcp.WithNumber("games", mcp.Description("Number of games (default: 2)"), mcp.Min(1)),
+		mcp.WithArray(
+			"openings",
+			mcp.Description(`Starting positions: FENs or PGN move lines such as "1. e4 c5 2. Nf3"`),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithNumber("movetime", mcp.Description("Milliseconds per move (default: 100)")),
+		mcp.WithNumber("depth", mcp.Description("Fixed search depth per move")),
+		mcp.WithNumber("nodes", mcp.Description("Fixed node count per move")),
+		mcp.WithNumber("max_plies", mcp.Description("Adjudicate a draw after this many plies (default: 400)")),
+		mcp.WithNumber("resign_score", mcp.Description("Resign when own score is at or below -N centipawns")),
+		mcp.WithNumber("resign_moves", mcp.Description("...for this many consecutive own moves (0 disables)")),
+		mcp.WithNumber("draw_score", mcp.Description("Adjudicate a draw when both scores are within ±N centipawns")),
+		mcp.WithNumber("draw_moves", mcp.Description("...for this many consecutive moves each (0 disables)")),
+		mcp.WithNumber("draw_min_ply", mcp.Description("Earliest ply at which draw adjudication applies")),
+		mcp.WithBoolean("include_pgn", mcp.Description("Include game PGNs in the result (default: true)")),
+	)
+}
+
+func newNewGameTool() mcp.Tool {
+	return mcp.NewTool(
+		"new_game",
+		mcp.WithDescription(`
+Starts a game between the user and the engine and returns its game_id.
+If the engine has the first move it is played right away. Every game tool
+returns the game status: FEN, move history (SAN and UCI), legal moves,
+clocks, result and PGN.
+
+STRENGTH: skill_level (0-20, Stockfish "Skill Level") or elo (enables
+UCI_LimitStrength and sets UCI_Elo).
+TIME: time_ms + increment_ms for a clock (engine gets wtime/btime/winc/binc),
+otherwise movetime (ms, default 1000) or depth per engine move.
+		`),
+		mcp.WithString(
+			"user_color",
+			mcp.Description("Side the user plays (default: white)"),
+			mcp.Enum("white", "black", "random"),
+		),
+		mcp.WithString("fen", mcp.Description("Starting position (default: initial position)")),
+		mcp.WithNumber(
+			"chess960_index",
+			mcp.Description("Start from this Chess960 position (0-959) instead of fen"),
+		),
+		mcp.WithString("engine", mcp.Description("Engine to play against (default: the default engine)")),
+		mcp.WithNumber("skill_level", mcp.Description("Skill Level 0-20"), mcp.Min(0), mcp.Max(20)),
+		mcp.WithNumber("elo", mcp.Description("Target playing strength via UCI_Elo")),
+		mcp.WithNumber("time_ms", mcp.Description("Initial clock time per side in milliseconds")),
+		mcp.WithNumber("increment_ms", mcp.Description("Increment per move in milliseconds")),
+		mcp.WithNumber("movetime", mcp.Description("Engine thinking time per move in ms without a clock")),
+		mcp.WithNumber("depth", mcp.Description("Engine search depth per move without a clock")),
+		mcp.WithBoolean(
+			"use_book",
+			mcp.Description("Play opening book moves before searching (default: server setting)"),
+		),
+	)
+}
+
+func newMakeMoveTool() mcp.Tool {
+	return mcp.NewTool(
+		"make_move",
+		mcp.WithDescription(`
+Plays the user's move in a game. The move is checked for legality and may be
+given in SAN (Nf3, exd5, O-O, e8=Q) or UCI (g1f3). Unless auto_reply is
+false the engine answers immediately.
+		`),
+		mcp.WithString("game_id", mcp.Required(), mcp.Description("Game returned by new_game")),
+		mcp.WithString("move", mcp.Required(), mcp.Description("Move in SAN or UCI notation")),
+		mcp.WithBoolean("auto_reply", mcp.Description("Let the engine reply right away (default: true)")),
+	)
+}
+
+func newEngineMoveTool() mcp.Tool {
+	return mcp.NewTool(
+		"engine_move",
+		mcp.WithDescription("Asks the engine to play its move in a game when it is the engine's turn."),
+		mcp.WithString("game_id", mcp.Required(), mcp.Description("Game returned by new_game")),
+	)
+}
+
+func newUndoMoveTool() mcp.Tool {
+	return mcp.NewTool(
+		"undo_move",
+		mcp.WithDescription(`
+Takes back moves in a game. By default takes back until it is the user's
+turn again (the user's last move and the engine's reply). The clocks are
+restored to their times after the last remaining move. Games ended by
+resignation or time forfeit cannot be taken back.
+		`),
+		mcp.WithString("game_id", mcp.Required(), mcp.Description("Game returned by new_game")),
+		mcp.WithNumber("plies", mcp.Description("Number of half-moves to take back"), mcp.Min(1)),
+	)
+}
+
+func newResignGameTool() mcp.Tool {
+	return mcp.NewTool(
+		"resign_game",
+		mcp.WithDescription("Resigns the game on behalf of the user."),
+		mcp.WithString("game_id", mcp.Required(), mcp.Description("Game returned by new_game")),
+	)
+}
+
+func newGameStatusTool() mcp.Tool {
+	return mcp.NewTool(
+		"game_status",
+		mcp.WithDescription("Returns the current state of a game: position, history, clocks and result."),
+		mcp.WithString("game_id", mcp.Required(), mcp.Description("Game returned by new_game")),
+	)
+}
+
+func newProbeTablebaseTool(probeLimit int) mcp.Tool {
+	return mcp.NewTool(
+		"probe_tablebase",
+		mcp.WithDescription(fmt.Sprintf(`
+Probes the local Syzygy tablebases for an endgame position with at most %d
+pieces and no castling rights. Returns the WDL value for the side to move
+(2 win, 1 cursed win, 0 draw, -1 blessed loss, -2 loss), the optimal moves
+and every legal move with its own WDL, ranked by the engine's DTZ-aware
+ordering. DTZ itself is not available over UCI and is reported as null.
+		`, probeLimit)),
+		mcp.WithString("fen", mcp.Required(), mcp.Description("Position to probe, in FEN")),
+		mcp.WithString("engine", mcp.Description("Engine used for probing (default: the default engine)")),
+	)
+}
+
+func newBookMovesTool() mcp.Tool {
+	return mcp.NewTool(
+		"book_moves",
+		mcp.WithDescription(`
+Looks up a position in the local Polyglot opening book. Returns every book
+move in UCI and SAN with its weight and the probability of it being played,
+highest weight first. An empty list means the position is out of book.
+		`),
+		mcp.WithString("fen", mcp.Description("Position in FEN (default: initial position)")),
+		mcp.WithArray(
+			"moves",
+			mcp.Description("Moves played from fen, in UCI or SAN"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+	)
+}
+
+func newRenderBoardTool() mcp.Tool {
+	return mcp.NewTool(
+		"render_board",
+		mcp.WithDescription(`
+Draws a chess position. Text formats (ascii, unicode) return a diagram with
+coordinates plus a line with the side to move, check, last and best move.
+The svg format returns an SVG image (as MCP image content and as an embedded
+image/svg+xml resource) with highlighted last-move squares, a red check
+marker on the king and arrows for the last move (blue) and best move (green).
+		`),
+		mcp.WithString("fen", mcp.Description("Position in FEN (default: initial position)")),
+		mcp.WithArray(
+			"moves",
+			mcp.Description("Moves played from fen, in UCI or SAN; the last one is shown as the last move"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithString(
+			"format",
+			mcp.Description("Output format (default: unicode)"),
+			mcp.Enum(RenderASCII, RenderUnicode, RenderSVG),
+		),
+		mcp.WithString(
+			"orientation",
+			mcp.Description("Side shown at the bottom (default: white)"),
+			mcp.Enum("white", "black"),
+		),
+		mcp.WithString("last_move", mcp.Description("Last move to highlight, as UCI squares (e.g. e2e4)")),
+		mcp.WithString("best_move", mcp.Description("Move to draw as the best-move arrow, in SAN or UCI")),
+		mcp.WithBoolean("coordinates", mcp.Description("Draw file and rank labels (default: true)")),
+	)
+}
+
+func newCheckMoveTool() mcp.Tool {
+	return mcp.NewTool(
+		"check_move",
+		mcp.WithDescription(`
+Judges a move in a position ("is Nxe5 a blunder here?"). Searches the
+position normally and restricted to the move (searchmoves), then returns both
+evaluations from the side to move's point of view, the centipawn loss, the
+loss in win percentage, a classification (best, excellent, good, inaccuracy,
+mistake, blunder) and, for bad moves, the refutation line in SAN.
+		`),
+		mcp.WithString("move", mcp.Required(), mcp.Description("Move to check, in SAN or UCI")),
+		mcp.WithString("fen", mcp.Description("Position in FEN (default: initial position)")),
+		mcp.WithArray(
+			"moves",
+			mcp.Description("Moves played from fen before the checked move, in UCI or SAN"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("engine", mcp.Description("Engine used for the search (default: the default engine)")),
+		mcp.WithNumber("depth", mcp.Description("Search depth of each search (default: 16)")),
+		mcp.WithNumber("movetime", mcp.Description("Time per search in milliseconds, instead of depth")),
+	)
+}
+
+func newDescribePositionTool() mcp.Tool {
+	return mcp.NewTool(
+		"describe_position",
+		mcp.WithDescription(`
+Lists the concrete facts of a position, to explain an evaluation rather than
+only quote it: material per side and the balance, hanging and undefended
+pieces, pieces attacked by less valuable ones, pins, checks (current checkers
+and available checking moves), passed, doubled and isolated pawns, king
+shelter per side, and the opponent's immediate threat, found by letting the
+side to move pass (null move) and searching. Includes a plain-language summary.
+		`),
+		mcp.WithString("fen", mcp.Description("Position in FEN (default: initial position)")),
+		mcp.WithArray(
+			"moves",
+			mcp.Description("Moves played from fen, in UCI or SAN"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithBoolean("threat", mcp.Description("Search for the opponent's threat with the engine (default: true)")),
+		mcp.WithString("engine", mcp.Description("Engine used for the threat search (default: the default engine)")),
+		mcp.WithNumber("depth", mcp.Description("Depth of the threat search (default: 12)")),
+		mcp.WithNumber("movetime", mcp.Description("Time for the threat search in milliseconds, instead of depth")),
+	)
+}
+
+func newGeneratePuzzlesTool() mcp.Tool {
+	return mcp.NewTool(
+		"generate_puzzles",
+		mcp.WithDescription(`
+Turns games into tactical puzzles. Every position of the PGN is searched with
+two lines; positions where one move is clearly winning and the alternatives
+are not become puzzles. The solution line is followed and re-checked for a
+unique best move up to max_moves solver moves. Each puzzle has its FEN, the
+solution in UCI and SAN, the move played in the game and themes (mate-in-N,
+fork, pin, promotion). Output as JSON or as EPD (bm, pv, id, c0).
+		`),
+		mcp.WithString("pgn", mcp.Required(), mcp.Description("PGN text with one or more games")),
+		mcp.WithString(
+			"format",
+			mcp.Description("Output format (default: json)"),
+			mcp.Enum(PuzzleFormatJSON, PuzzleFormatEPD),
+		),
+		mcp.WithString("engine", mcp.Description("Engine used for the searches (default: the default engine)")),
+		mcp.WithNumber("depth", mcp.Description("Search depth per position (default: 14)")),
+		mcp.WithNumber("movetime", mcp.Description("Time per position in milliseconds, instead of depth")),
+		mcp.WithNumber("max_moves", mcp.Description("Longest solution, in solver moves (default: 4)")),
+		mcp.WithNumber("max_puzzles", mcp.Description("Stop after this many puzzles (default: 20)")),
+	)
+}
+
+func newRunTestSuiteTool() mcp.Tool {
+	return mcp.NewTool(
+		"run_test_suite",
+		mcp.WithDescription(`
+Runs an EPD test suite (WAC, STS, Bratko-Kopec, ...) to validate an engine
+configuration. Every position is searched with the same budget, in parallel
+on up to MaxSessions engine processes. A position is solved when the engine's
+move is one of the "bm" moves and none of the "am" moves. Reports per position
+the move played, solved/failed, the solve time and the final score, plus
+totals.
+
+EXAMPLE: epd="... bm Qg6; id \"WAC.001\";", movetime=1000
+		`),
+		mcp.WithString("epd", mcp.Required(), mcp.Description("EPD text, one position per line")),
+		mcp.WithString("engine", mcp.Description("Engine to test (default: the default engine)")),
+		mcp.WithNumber("movetime", mcp.Description("Time per position in milliseconds (default: 1000)")),
+		mcp.WithNumber("depth", mcp.Description("Fixed depth per position")),
+		mcp.WithNumber("nodes", mcp.Description("Fixed nodes per position")),
+		mcp.WithNumber("workers", mcp.Description("Engines searching in parallel (default and maximum: MaxSessions)")),
+		mcp.WithNumber("max_positions", mcp.Description("Only run the first N positions")),
+	)
+}
+
+func newAnalyzeBatchTool() mcp.Tool {
+	return mcp.NewTool(
+		"analyze_batch",
+		mcp.WithDescription(`
+Evaluates many positions in one call, e.g. every candidate after a move.
+Items are spread over a pool of up to MaxSessions engines. Each item is a FEN
+string or an object {id, fen, moves, depth, movetime}; its own depth or
+movetime overrides the shared one. Results come back in item order with the
+best move, score, WDL and PV in SAN, or a per-item error (invalid position,
+timeout, ...). Other items still return when some fail.
+
+EXAMPLE: items=["<fen1>", {"id": "after Nf3", "moves": ["e4", "e5", "Nf3"]}], depth=14
+		`),
+		mcp.WithArray(
+			"items",
+			mcp.Required(),
+			mcp.Description("Positions to analyze (up to 500)"),
+			mcp.Items(map[string]any{
+				"anyOf": []any{
+					map[string]any{"type": "string", "description": "FEN"},
+					map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"id":       map[string]any{"type": "string"},
+							"fen":      map[string]any{"type": "string"},
+							"moves":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+							"depth":    map[string]any{"type": "number"},
+							"movetime": map[string]any{"type": "number"},
+						},
+					},
+				},
+			}),
+		),
+		mcp.WithString("engine", mcp.Description("Engine used for the searches (default: the default engine)")),
+		mcp.WithNumber("depth", mcp.Description("Search depth per item (default: 16)")),
+		mcp.WithNumber("movetime", mcp.Description("Time per item in milliseconds, instead of depth")),
+		mcp.WithNumber("workers", mcp.Description("Engines searching in parallel (default and maximum: MaxSessions)")),
+	)
+}
+
+func newChess960PositionTool() mcp.Tool {
+	return mcp.NewTool(
+		"chess960_position",
+		mcp.WithDescription(`
+Generates a Chess960 (Fischer Random) start position from its index 0-959 in
+the standard numbering (518 is the classical setup), or a random one. Returns
+the back rank, the FEN with X-FEN castling rights and the Shredder-FEN.
+Positions like these can be passed to new_game, chess_engine and the analysis
+tools: Chess960 castling rules, king-takes-rook UCI castling and the engine's
+UCI_Chess960 option are then used automatically.
+		`),
+		mcp.WithNumber("index", mcp.Description("Position index 0-959 (default: random)")),
+	)
+}
+
+func newHealthTool() mcp.Tool {
+	return mcp.NewTool(
+		"health",
+		mcp.WithDescription(`
+Runs the server's readiness checks: every configured engine is launched and
+must answer "uciok" and "readyok" in time, and the persistent session pool
+must have room or a free queue place. The status is "ok", "degraded" when
+only a non-default engine fails, or "unavailable". Results are cached for a
+couple of seconds.
+		`),
+	)
+}
+
+func newSessionTranscriptTool() mcp.Tool {
+	return mcp.NewTool(
+		"session_transcript",
+		mcp.WithDescription(`
+Returns the recent commands sent to a persistent chess_engine session, oldest
+first, with the engine's reply, duration and error of each. Long replies keep
+their last lines only. The same transcript is available as the resource
+stockfish://sessions/{id}/transcript.
+		`),
+		mcp.WithString("session_id", mcp.Required(), mcp.Description("Session returned by chess_engine")),
+		mcp.WithNumber("limit", mcp.Description("Return only the last N commands (default: all kept)")),
+		mcp.WithBoolean("export", mcp.Description("Also write the transcript as JSONL to the server's transcript directory")),
+	)
+}
+
+func newSessionTranscriptResource() mcp.ResourceTemplate {
+	return mcp.NewResourceTemplate(
+		"stockfish://sessions/{id}/transcript",
+		"Session transcript",
+		mcp.WithTemplateDescription("Recent commands of a persistent chess_engine session with replies, timings and errors"),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+}
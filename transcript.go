@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+)
+
+// transcriptMaxResponse bounds the reply lines kept per entry. The tail is
+// kept: it holds the final info lines and the bestmove.
+const transcriptMaxResponse = 64
+
+// TranscriptEntry is one command a client sent to a session.
+type TranscriptEntry struct {
+	Seq        int       `json:"seq"`
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"`
+	Response   []string  `json:"response,omitempty"`
+	Truncated  int       `json:"truncated_lines,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// transcript keeps the last entries of a session. A nil transcript records
+// nothing.
+type transcript struct {
+	mu      sync.Mutex
+	size    int
+	seq     int
+	entries []TranscriptEntry
+}
+
+func newTranscript(size int) *transcript {
+	if size <= 0 {
+		return nil
+	}
+	return &transcript{size: size}
+}
+
+func (t *transcript) add(command string, response []string, elapsed time.Duration, err error) {
+	if t == nil {
+		return
+	}
+
+	entry := TranscriptEntry{
+		Time:       time.Now().Add(-elapsed).UTC(),
+		Command:    strings.TrimSpace(command),
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if n := len(response); n > transcriptMaxResponse {
+		entry.Truncated = n - transcriptMaxResponse
+		response = response[entry.Truncated:]
+	}
+	entry.Response = append([]string(nil), response...)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	entry.Seq = t.seq
+	if len(t.entries) == t.size {
+		copy(t.entries, t.entries[1:])
+		t.entries = t.entries[:len(t.entries)-1]
+	}
+	t.entries = append(t.entries, entry)
+}
+
+// snapshot returns the last limit entries, all of them if limit <= 0, and
+// the number of commands recorded so far.
+func (t *transcript) snapshot(limit int) ([]TranscriptEntry, int) {
+	if t == nil {
+		return []TranscriptEntry{}, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := t.entries
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return append([]TranscriptEntry{}, entries...), t.seq
+}
+
+// TranscriptResult is returned by the session_transcript tool and resource.
+type TranscriptResult struct {
+	Status     string            `json:"status"`
+	SessionID  string            `json:"session_id"`
+	Engine     string            `json:"engine"`
+	Commands   int               `json:"commands"`
+	Entries    []TranscriptEntry `json:"entries"`
+	ExportPath string            `json:"export_path,omitempty"`
+}
+
+// TranscriptHandler serves the transcripts of persistent sessions.
+type TranscriptHandler struct {
+	sessionManager *SessionManager
+	exportDir      string
+	logger         zerolog.Logger
+}
+
+func newTranscriptHandler(sm *SessionManager, logger zerolog.Logger) *TranscriptHandler {
+	return &TranscriptHandler{
+		sessionManager: sm,
+		exportDir:      sm.config.TranscriptDir,
+		logger:         logger.With().Str("component", ComponentTranscript).Logger(),
+	}
+}
+
+func (h *TranscriptHandler) transcript(
+	ctx context.Context,
+	sessionID string,
+	limit int,
+) (*TranscriptResult, error) {
+	h.sessionManager.mu.RLock()
+	session, ok := h.sessionManager.sessions[sessionID]
+	h.sessionManager.mu.RUnlock()
+	if !ok || !ownedBy(session.Owner, principalFromContext(ctx)) {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+
+	entries, commands := session.transcript.snapshot(limit)
+	return &TranscriptResult{
+		Status:    "success",
+		SessionID: session.ID,
+		Engine:    session.Engine,
+		Commands:  commands,
+		Entries:   entries,
+	}, nil
+}
+
+// export writes the transcript as one JSON entry per line to
+// <exportDir>/<session id>.jsonl, replacing an earlier export.
+func (h *TranscriptHandler) export(result *TranscriptResult) (string, error) {
+	if h.exportDir == "" {
+		return "", fmt.Errorf("transcript export is disabled: set MCP_STOCKFISH_TRANSCRIPT_DIR")
+	}
+	id := result.SessionID
+	if id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("session id %q cannot be used as a file name", id)
+	}
+	if err := os.MkdirAll(h.exportDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to export transcript: %w", err)
+	}
+
+	path := filepath.Join(h.exportDir, id+".jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to export transcript: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, entry := range result.Entries {
+		if err := enc.Encode(entry); err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to export transcript: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to export transcript: %w", err)
+	}
+
+	h.logger.Info().
+		Str("session_id", id).
+		Str("path", path).
+		Int("entries", len(result.Entries)).
+		Msg("Transcript exported")
+	return path, nil
+}
+
+func (h *TranscriptHandler) handle(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("session_id")
+	if err != nil {
+		return mcp.NewToolResultError("Missing 'session_id' parameter"), nil
+	}
+
+	result, err := h.transcript(ctx, sessionID, request.GetInt("limit", 0))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if request.GetBool("export", false) {
+		if result.ExportPath, err = h.export(result); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	return jsonToolResult(result, h.logger)
+}
+
+// handleResource serves stockfish://sessions/{id}/transcript.
+func (h *TranscriptHandler) handleResource(
+	ctx context.Context,
+	request mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+	var sessionID string
+	switch id := request.Params.Arguments["id"].(type) {
+	case string:
+		sessionID = id
+	case []string:
+		if len(id) > 0 {
+			sessionID = id[0]
+		}
+	}
+	if sessionID == "" {
+		return nil, fmt.Errorf("invalid transcript URI %q", request.Params.URI)
+	}
+
+	result, err := h.transcript(ctx, sessionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      request.Params.URI,
+			MIMEType: "application/json",
+			Text:     string(data),
+		},
+	}, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rs/zerolog"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestSessionTranscript(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{SearchTime: time.Minute}))
+	config.CommandTimeout = 300 * time.Millisecond
+	config.TranscriptSize = 3
+	config.TranscriptDir = t.TempDir()
+	sm := newTestSessionManager(t, config)
+	executor := NewPersistentSessionExecutor(sm, zerolog.Nop())
+	handler := newTranscriptHandler(sm, zerolog.Nop())
+
+	id, _, _, err := executor.Execute(context.Background(), testEngine, "uci", "", PriorityInteractive, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"isready", "position startpos moves e2e4", "go infinite"} {
+		executor.Execute(context.Background(), testEngine, command, id, PriorityInteractive, 0)
+	}
+
+	text, isError := callTool(t, handler.handle, map[string]any{"session_id": id, "export": true})
+	if isError {
+		t.Fatalf("tool error: %s", text)
+	}
+	result := decode[TranscriptResult](t, text)
+	if result.Commands != 4 || len(result.Entries) != 3 {
+		t.Fatalf("%d commands, %d entries, want 4 and 3", result.Commands, len(result.Entries))
+	}
+	first, last := result.Entries[0], result.Entries[2]
+	if first.Seq != 2 || first.Command != "isready" || first.Response[0] != "readyok" {
+		t.Errorf("first entry = %+v", first)
+	}
+	if last.Command != "go infinite" || !strings.Contains(last.Error, "command timeout") || last.DurationMS < 300 {
+		t.Errorf("last entry = %+v", last)
+	}
+
+	f, err := os.Open(result.ExportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if filepath.Dir(result.ExportPath) != config.TranscriptDir {
+		t.Errorf("exported to %s", result.ExportPath)
+	}
+	var exported []TranscriptEntry
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		exported = append(exported, decode[TranscriptEntry](t, scanner.Text()))
+	}
+	if len(exported) != 3 || exported[1].Command != "position startpos moves e2e4" {
+		t.Errorf("exported %+v", exported)
+	}
+
+	text, _ = callTool(t, handler.handle, map[string]any{"session_id": id, "limit": 1})
+	if entries := decode[TranscriptResult](t, text).Entries; len(entries) != 1 || entries[0].Seq != 4 {
+		t.Errorf("limited entries = %+v", entries)
+	}
+	if text, isError = callTool(t, handler.handle, map[string]any{"session_id": "missing"}); !isError {
+		t.Errorf("unknown session: %s", text)
+	}
+}
+
+func TestSessionTranscriptResource(t *testing.T) {
+	config := testConfig(fakeEngine(t, ucitest.Script{}))
+	config.TranscriptSize = 10
+	sm := newTestSessionManager(t, config)
+	executor := NewPersistentSessionExecutor(sm, zerolog.Nop())
+	handler := newTranscriptHandler(sm, zerolog.Nop())
+
+	id, _, _, err := executor.Execute(context.Background(), testEngine, "go depth 2", "", PriorityInteractive, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var request mcp.ReadResourceRequest
+	request.Params.URI = "stockfish://sessions/" + id + "/transcript"
+	request.Params.Arguments = map[string]any{"id": []string{id}}
+	contents, err := handler.handleResource(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := contents[0].(mcp.TextResourceContents)
+	var result TranscriptResult
+	if err := json.Unmarshal([]byte(text.Text), &result); err != nil {
+		t.Fatal(err)
+	}
+	if text.URI != request.Params.URI || len(result.Entries) != 1 {
+		t.Fatalf("resource = %+v", result)
+	}
+	entry := result.Entries[0]
+	if entry.Command != "go depth 2" || entry.Response[len(entry.Response)-1] != "bestmove e2e4" {
+		t.Errorf("entry = %+v", entry)
+	}
+}
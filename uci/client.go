@@ -0,0 +1,507 @@
+// Package uci is a client for chess engines that speak the Universal Chess
+// Interface, such as Stockfish.
+//
+// A Client owns one engine process (or any pair of pipes, see NewClient)
+// and turns the line protocol into typed calls:
+//
+//	engine, err := uci.Start(ctx, uci.Command{Path: "stockfish"})
+//	if err != nil {
+//		return err
+//	}
+//	defer engine.Close()
+//
+//	id, err := engine.Handshake(ctx)
+//	...
+//	err = engine.SetPosition(ctx, "", "e2e4", "e7e5")
+//	result, err := engine.Go(ctx, uci.Limits{Depth: 20}, func(info uci.Info) {
+//		log.Println(info.Depth, info.PV)
+//	})
+//
+// Every call takes a context. A search whose context ends is stopped with
+// "stop" and its best move so far is still returned, with the context's
+// error. Stop and PonderHit may be called while Go is running.
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrClosed is returned by calls on a client that was closed or quit.
+	ErrClosed = errors.New("uci: engine closed")
+	// ErrExited is returned when the engine's output ends on its own, e.g.
+	// because the process crashed.
+	ErrExited = errors.New("uci: engine exited")
+)
+
+const (
+	// StopTimeout is how long a cancelled search waits for "bestmove"
+	// after sending "stop" before the engine is considered hung and closed.
+	StopTimeout = 2 * time.Second
+
+	maxLineLength = 1 << 20
+	// waitDelay bounds how long the output is read after the process exits,
+	// in case a child of the engine still holds the pipe open.
+	waitDelay = time.Second
+)
+
+// Command describes the engine process to start.
+type Command struct {
+	Path string
+	Args []string
+	// Dir is the working directory; empty means the current one.
+	Dir string
+	// Env is the environment; nil inherits the server's.
+	Env []string
+	// Stderr receives the engine's standard error. It is discarded when nil.
+	Stderr io.Writer
+	// SysProcAttr holds OS-specific attributes of the process.
+	SysProcAttr *syscall.SysProcAttr
+	// Started, if set, is called once the process runs and before anything
+	// is sent to it, e.g. to apply resource limits. When it fails the
+	// process is killed and Start returns its error.
+	Started func(p *os.Process) error
+}
+
+// Client talks to one engine. Calls that read engine output are
+// serialized; Send, SetOption, Stop and PonderHit only write and may be
+// called at any time.
+type Client struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.Reader
+
+	// wmu guards writes, rmu gives one call at a time the engine output.
+	wmu sync.Mutex
+	w   *bufio.Writer
+	rmu sync.Mutex
+
+	// lines carries engine output and is closed when it ends, after
+	// readErr is set. done is closed right after.
+	lines   chan string
+	readErr error
+	done    chan struct{}
+
+	// For a started engine: eof is closed when the output hits EOF, exited
+	// when the process has been waited for, after waitErr is set.
+	eof     chan struct{}
+	exited  chan struct{}
+	waitErr error
+
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// Start launches the engine. The process is killed when ctx is done, as
+// with exec.CommandContext; use Quit or Close to end it earlier.
+func Start(ctx context.Context, c Command) (*Client, error) {
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Env = c.Env
+	cmd.Stderr = c.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = io.Discard
+	}
+	cmd.WaitDelay = waitDelay
+	cmd.SysProcAttr = c.SysProcAttr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("uci: stdin pipe: %w", err)
+	}
+	// The output pipe is ours rather than exec's, so that it can be read to
+	// the end after the process exits and closed when that never comes.
+	stdout, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("uci: stdout pipe: %w", err)
+	}
+	cmd.Stdout = w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		stdout.Close()
+		return nil, fmt.Errorf("uci: start %s: %w", c.Path, err)
+	}
+
+	client := newClient(cmd, stdin, stdout)
+	if c.Started != nil {
+		if err := c.Started(cmd.Process); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("uci: start %s: %w", c.Path, err)
+		}
+	}
+	return client, nil
+}
+
+// NewClient speaks UCI over existing pipes, e.g. to an engine running in
+// the same process. Closing the client closes both ends that are closers.
+func NewClient(stdin io.WriteCloser, stdout io.Reader) *Client {
+	return newClient(nil, stdin, stdout)
+}
+
+func newClient(cmd *exec.Cmd, stdin io.WriteCloser, stdout io.Reader) *Client {
+	c := &Client{
+		cmd:     cmd,
+		stdin:   stdin,
+		stdout:  stdout,
+		w:       bufio.NewWriter(stdin),
+		lines:   make(chan string, 256),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+		eof:     make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	go c.read()
+	if cmd != nil {
+		go c.wait()
+	}
+	return c
+}
+
+func (c *Client) read() {
+	defer close(c.done)
+
+	scanner := bufio.NewScanner(c.stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		select {
+		case c.lines <- scanner.Text():
+		case <-c.closing:
+			// Nobody will read it; keep draining so the engine can exit.
+		}
+	}
+	close(c.eof)
+
+	err := scanner.Err()
+	if c.cmd != nil {
+		<-c.exited
+		// The process status explains the end of output better than
+		// the read error of a pipe closed by wait.
+		if c.waitErr != nil || errors.Is(err, os.ErrClosed) {
+			err = c.waitErr
+		}
+	}
+	c.readErr = err
+	close(c.lines)
+}
+
+func (c *Client) wait() {
+	c.waitErr = c.cmd.Wait()
+	close(c.exited)
+
+	select {
+	case <-c.eof:
+	case <-time.After(waitDelay):
+		c.stdout.(io.Closer).Close()
+	}
+}
+
+// Process returns the engine process, or nil for a client made with
+// NewClient.
+func (c *Client) Process() *os.Process {
+	if c.cmd == nil {
+		return nil
+	}
+	return c.cmd.Process
+}
+
+// Done is closed once the engine output has ended and, for a started
+// engine, the process has exited.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err reports why the engine output ended, or nil while it is running.
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.exitErr()
+	default:
+		return nil
+	}
+}
+
+// exitErr must only be called once lines is closed.
+func (c *Client) exitErr() error {
+	select {
+	case <-c.closing:
+		return ErrClosed
+	default:
+	}
+	if c.readErr != nil {
+		return fmt.Errorf("%w: %v", ErrExited, c.readErr)
+	}
+	return ErrExited
+}
+
+// Send writes a command that has no reply, such as "position" or
+// "setoption".
+func (c *Client) Send(ctx context.Context, line string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-c.closing:
+		return ErrClosed
+	case <-c.done:
+		return c.exitErr()
+	default:
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.w.WriteString(line + "\n"); err != nil {
+		return fmt.Errorf("uci: write %q: %w", line, err)
+	}
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("uci: write %q: %w", line, err)
+	}
+	return nil
+}
+
+func (c *Client) next(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-c.lines:
+		if !ok {
+			return "", c.exitErr()
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// roundTrip sends line and collects the output up to and including the
+// terminator line.
+func (c *Client) roundTrip(ctx context.Context, line, terminator string) ([]string, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if err := c.Send(ctx, line); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		l, err := c.next(ctx)
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, l)
+		if strings.TrimSpace(l) == terminator {
+			return lines, nil
+		}
+	}
+}
+
+// Handshake switches the engine to UCI mode and returns its identification
+// and options.
+func (c *Client) Handshake(ctx context.Context) (*ID, error) {
+	lines, err := c.roundTrip(ctx, "uci", "uciok")
+	if err != nil {
+		return nil, err
+	}
+	return ParseID(lines), nil
+}
+
+// IsReady waits until the engine has processed every command sent so far.
+func (c *Client) IsReady(ctx context.Context) error {
+	_, err := c.roundTrip(ctx, "isready", "readyok")
+	return err
+}
+
+// SetOption sets an engine option. An empty value sends no "value" part,
+// as for button options.
+func (c *Client) SetOption(ctx context.Context, name, value string) error {
+	return c.Send(ctx, FormatSetOption(name, value))
+}
+
+// FormatSetOption returns the "setoption" command for name and value.
+func FormatSetOption(name, value string) string {
+	if value == "" {
+		return "setoption name " + name
+	}
+	return fmt.Sprintf("setoption name %s value %s", name, value)
+}
+
+// NewGame tells the engine the next search is from a different game and
+// waits until it is ready.
+func (c *Client) NewGame(ctx context.Context) error {
+	if err := c.Send(ctx, "ucinewgame"); err != nil {
+		return err
+	}
+	return c.IsReady(ctx)
+}
+
+// SetPosition sets the position to search: fen, or the standard start
+// position when fen is empty or "startpos", followed by moves in UCI
+// notation.
+func (c *Client) SetPosition(ctx context.Context, fen string, moves ...string) error {
+	line := "position startpos"
+	if fen != "" && fen != "startpos" {
+		line = "position fen " + fen
+	}
+	if len(moves) > 0 {
+		line += " moves " + strings.Join(moves, " ")
+	}
+	return c.Send(ctx, line)
+}
+
+// SearchResult is the outcome of a "go" command.
+type SearchResult struct {
+	BestMove string
+	Ponder   string
+	// Info holds every "info" line in order, Lines the raw output
+	// including the "bestmove" line.
+	Info  []Info
+	Lines []string
+}
+
+// Go searches the current position until the engine reports its best move,
+// calling onInfo, if not nil, for each "info" line as it arrives. When ctx
+// ends first the search is stopped and the result is returned with ctx's
+// error.
+func (c *Client) Go(ctx context.Context, limits Limits, onInfo func(Info)) (*SearchResult, error) {
+	return c.search(ctx, limits.String(), onInfo)
+}
+
+func (c *Client) search(ctx context.Context, line string, onInfo func(Info)) (*SearchResult, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	result := &SearchResult{}
+	if err := c.Send(ctx, line); err != nil {
+		return result, err
+	}
+
+	readCtx := ctx
+	stopped := false
+	for {
+		l, err := c.next(readCtx)
+		if err != nil && !stopped && ctx.Err() != nil {
+			// Ask for the best move so far and wait a little for it.
+			stopped = true
+			if err := c.Send(context.Background(), "stop"); err != nil {
+				return result, err
+			}
+			var cancel context.CancelFunc
+			readCtx, cancel = context.WithTimeout(context.Background(), StopTimeout)
+			defer cancel()
+			continue
+		}
+		if err != nil {
+			if stopped && errors.Is(err, context.DeadlineExceeded) {
+				// The engine ignores "stop"; its output can no longer be
+				// matched to commands.
+				c.Close()
+				return result, fmt.Errorf("%w; engine did not stop within %v", ctx.Err(), StopTimeout)
+			}
+			return result, err
+		}
+
+		result.Lines = append(result.Lines, l)
+		if info, ok := ParseInfo(l); ok {
+			result.Info = append(result.Info, info)
+			if onInfo != nil {
+				onInfo(info)
+			}
+			continue
+		}
+		if fields := strings.Fields(l); len(fields) > 0 && fields[0] == "bestmove" {
+			result.BestMove, result.Ponder, _ = ParseBestMove(l)
+			break
+		}
+	}
+
+	if stopped {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// Stop asks the engine to end the running search. Go then returns as
+// usual with the best move found.
+func (c *Client) Stop(ctx context.Context) error {
+	return c.Send(ctx, "stop")
+}
+
+// PonderHit tells the engine that the expected move was played, turning
+// a "go ponder" search into a normal one.
+func (c *Client) PonderHit(ctx context.Context) error {
+	return c.Send(ctx, "ponderhit")
+}
+
+// Exec sends a raw command line and returns its output: up to "uciok",
+// "readyok" or "bestmove" for "uci", "isready" and "go", none for the
+// others. A "quit" waits for the engine to exit.
+func (c *Client) Exec(ctx context.Context, line string) ([]string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("uci: empty command")
+	}
+	switch strings.ToLower(fields[0]) {
+	case "uci":
+		return c.roundTrip(ctx, line, "uciok")
+	case "isready":
+		return c.roundTrip(ctx, line, "readyok")
+	case "go":
+		result, err := c.search(ctx, line, nil)
+		return result.Lines, err
+	case "quit":
+		return nil, c.Quit(ctx)
+	}
+	return nil, c.Send(ctx, line)
+}
+
+// Quit asks the engine to exit and waits for it, killing it when ctx ends
+// first.
+func (c *Client) Quit(ctx context.Context) error {
+	err := c.Send(ctx, "quit")
+	c.shutdown()
+	if err != nil {
+		c.Close()
+		return err
+	}
+	if c.cmd == nil {
+		return c.Close()
+	}
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.Close()
+		return ctx.Err()
+	}
+}
+
+// shutdown marks the client closed: output is drained and dropped, and
+// further calls fail with ErrClosed.
+func (c *Client) shutdown() {
+	c.closeOnce.Do(func() { close(c.closing) })
+}
+
+// Close kills the engine, or closes the pipes of a client made with
+// NewClient, without waiting for it to quit.
+func (c *Client) Close() error {
+	c.shutdown()
+	_ = c.stdin.Close()
+	if c.cmd == nil {
+		if closer, ok := c.stdout.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil
+	}
+	_ = c.cmd.Process.Kill()
+	<-c.done
+	return nil
+}
@@ -0,0 +1,244 @@
+package uci_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+	"github.com/sonirico/mcp-stockfish/uci/ucitest"
+)
+
+func TestMain(m *testing.M) {
+	ucitest.Main()
+	os.Exit(m.Run())
+}
+
+// start runs the script as a child process, closed with the test.
+func start(t *testing.T, script ucitest.Script) *uci.Client {
+	t.Helper()
+	path, args := script.Command()
+	client, err := uci.Start(context.Background(), uci.Command{Path: path, Args: args})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func inProcess(t *testing.T, script ucitest.Script) *uci.Client {
+	t.Helper()
+	client := script.Client()
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func timeout(t *testing.T, d time.Duration) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestHandshake(t *testing.T) {
+	for name, client := range map[string]*uci.Client{
+		"process":    start(t, ucitest.Script{Name: "Fish 1"}),
+		"in process": inProcess(t, ucitest.Script{Name: "Fish 1"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			id, err := client.Handshake(timeout(t, 5*time.Second))
+			if err != nil {
+				t.Fatalf("Handshake: %v", err)
+			}
+			if id.Name != "Fish 1" || id.Author != "ucitest" {
+				t.Errorf("id = %q by %q", id.Name, id.Author)
+			}
+			if len(id.Options) != len(ucitest.Options) {
+				t.Fatalf("got %d options, want %d", len(id.Options), len(ucitest.Options))
+			}
+			hash, ok := id.Option("hash")
+			if !ok || hash.Type != "spin" || hash.Default != "16" || hash.Max != "33554432" {
+				t.Errorf("Hash option = %+v, %v", hash, ok)
+			}
+			if err := client.IsReady(timeout(t, 5*time.Second)); err != nil {
+				t.Errorf("IsReady: %v", err)
+			}
+		})
+	}
+}
+
+func TestGoStreamsInfo(t *testing.T) {
+	client := inProcess(t, ucitest.Script{Depth: 5, BestMove: "d2d4"})
+	ctx := timeout(t, 5*time.Second)
+
+	if err := client.NewGame(ctx); err != nil {
+		t.Fatalf("NewGame: %v", err)
+	}
+	if err := client.SetPosition(ctx, "", "e2e4", "e7e5"); err != nil {
+		t.Fatalf("SetPosition: %v", err)
+	}
+
+	var depths []int
+	result, err := client.Go(ctx, uci.Limits{Depth: 5}, func(info uci.Info) {
+		depths = append(depths, info.Depth)
+	})
+	if err != nil {
+		t.Fatalf("Go: %v", err)
+	}
+	if result.BestMove != "d2d4" {
+		t.Errorf("BestMove = %q", result.BestMove)
+	}
+	if len(depths) != 5 || depths[4] != 5 {
+		t.Errorf("streamed depths %v", depths)
+	}
+	if len(result.Info) != 5 || len(result.Lines) != 6 {
+		t.Errorf("got %d info and %d lines", len(result.Info), len(result.Lines))
+	}
+	last := result.Info[4]
+	if last.Score == nil || last.Score.Mate || last.Score.Value != 50 || last.PV[0] != "d2d4" {
+		t.Errorf("last info = %+v", last)
+	}
+}
+
+func TestGoCancelStopsSearch(t *testing.T) {
+	client := start(t, ucitest.Script{SearchTime: time.Minute})
+
+	started := time.Now()
+	result, err := client.Go(timeout(t, 100*time.Millisecond), uci.Limits{Infinite: true}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if result.BestMove != "e2e4" {
+		t.Errorf("BestMove after stop = %q", result.BestMove)
+	}
+	if elapsed := time.Since(started); elapsed > uci.StopTimeout {
+		t.Errorf("stopping took %v", elapsed)
+	}
+
+	// The engine is still in sync.
+	if err := client.IsReady(timeout(t, 5*time.Second)); err != nil {
+		t.Errorf("IsReady after stop: %v", err)
+	}
+}
+
+func TestStopFromAnotherGoroutine(t *testing.T) {
+	client := inProcess(t, ucitest.Script{SearchTime: time.Minute})
+	ctx := timeout(t, 10*time.Second)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		client.Stop(ctx)
+	}()
+	result, err := client.Go(ctx, uci.Limits{Infinite: true}, nil)
+	if err != nil {
+		t.Fatalf("Go: %v", err)
+	}
+	if result.BestMove != "e2e4" {
+		t.Errorf("BestMove = %q", result.BestMove)
+	}
+}
+
+func TestHungEngineIsClosed(t *testing.T) {
+	client := start(t, ucitest.Script{SearchTime: time.Minute, NoBestMove: true})
+
+	_, err := client.Go(timeout(t, 50*time.Millisecond), uci.Limits{Depth: 30}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("engine still running")
+	}
+	if err := client.IsReady(timeout(t, time.Second)); !errors.Is(err, uci.ErrClosed) {
+		t.Errorf("IsReady on a closed client: %v", err)
+	}
+}
+
+func TestCrash(t *testing.T) {
+	client := start(t, ucitest.Script{CrashOn: "go"})
+	ctx := timeout(t, 5*time.Second)
+
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("Handshake: %v", err)
+	}
+	_, err := client.Go(ctx, uci.Limits{Depth: 1}, nil)
+	if !errors.Is(err, uci.ErrExited) {
+		t.Fatalf("err = %v, want ErrExited", err)
+	}
+	if !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("err = %v, want the exit status", err)
+	}
+	if client.Err() == nil {
+		t.Error("Err() = nil after the crash")
+	}
+}
+
+func TestGarbageAndStderrSpam(t *testing.T) {
+	client := start(t, ucitest.Script{Garbage: true, StderrLines: 2000})
+	ctx := timeout(t, 5*time.Second)
+
+	id, err := client.Handshake(ctx)
+	if err != nil {
+		t.Fatalf("Handshake: %v", err)
+	}
+	if id.Name != "FakeFish" || len(id.Options) != len(ucitest.Options) {
+		t.Errorf("id = %+v", id)
+	}
+	for i := 0; i < 3; i++ {
+		result, err := client.Go(ctx, uci.Limits{Depth: 3}, nil)
+		if err != nil {
+			t.Fatalf("Go: %v", err)
+		}
+		if result.BestMove != "e2e4" || len(result.Info) < 3 {
+			t.Errorf("search %d: bestmove %q, %d info lines", i, result.BestMove, len(result.Info))
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "commands.log")
+	client := start(t, ucitest.Script{Log: log})
+	ctx := timeout(t, 5*time.Second)
+
+	lines, err := client.Exec(ctx, "uci")
+	if err != nil || lines[len(lines)-1] != "uciok" {
+		t.Fatalf("uci: %v, %q", err, lines)
+	}
+	if lines, err := client.Exec(ctx, "position startpos moves e2e4"); err != nil || lines != nil {
+		t.Errorf("position: %v, %q", err, lines)
+	}
+	if err := client.SetOption(ctx, "Clear Hash", ""); err != nil {
+		t.Errorf("SetOption: %v", err)
+	}
+	lines, err = client.Exec(ctx, "go depth 2")
+	if err != nil || lines[len(lines)-1] != "bestmove e2e4" {
+		t.Errorf("go: %v, %q", err, lines)
+	}
+	if _, err := client.Exec(ctx, "quit"); err != nil {
+		t.Errorf("quit: %v", err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "uci\nposition startpos moves e2e4\nsetoption name Clear Hash\ngo depth 2\nquit\n"
+	if string(data) != want {
+		t.Errorf("engine received\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestQuitInProcess(t *testing.T) {
+	client := inProcess(t, ucitest.Script{})
+	if err := client.Quit(timeout(t, 5*time.Second)); err != nil {
+		t.Fatalf("Quit: %v", err)
+	}
+	if err := client.IsReady(timeout(t, time.Second)); !errors.Is(err, uci.ErrClosed) {
+		t.Errorf("IsReady after Quit: %v", err)
+	}
+}
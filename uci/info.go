@@ -0,0 +1,126 @@
+package uci
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info is one parsed "info" line. Scores are from the point of view of the
+// side to move, as in UCI.
+type Info struct {
+	Depth          int
+	SelDepth       int
+	MultiPV        int
+	Score          *Score
+	WDL            *WDL
+	Nodes          int64
+	NPS            int64
+	TBHits         int64
+	HashFull       int
+	Time           time.Duration
+	CurrMove       string
+	CurrMoveNumber int
+	PV             []string
+	// String is the free text of an "info string" line.
+	String string
+}
+
+// Score is the evaluation of an info line.
+type Score struct {
+	// Mate is true when Value counts moves to mate rather than centipawns.
+	Mate  bool
+	Value int
+	// Bound is "lowerbound" or "upperbound" when the score is not exact.
+	Bound string
+}
+
+// WDL is the win/draw/loss outlook in permille reported with UCI_ShowWDL.
+type WDL struct {
+	Win  int
+	Draw int
+	Loss int
+}
+
+// ParseInfo parses an "info" line. It reports false for any other line.
+// Unknown tokens are skipped; MultiPV defaults to 1.
+func ParseInfo(line string) (Info, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "info" {
+		return Info{}, false
+	}
+	info := Info{MultiPV: 1}
+
+	for i := 1; i < len(fields); i++ {
+		next := func() string {
+			if i+1 < len(fields) {
+				i++
+				return fields[i]
+			}
+			return ""
+		}
+		atoi := func() int {
+			n, _ := strconv.Atoi(next())
+			return n
+		}
+		atoi64 := func() int64 {
+			n, _ := strconv.ParseInt(next(), 10, 64)
+			return n
+		}
+
+		switch fields[i] {
+		case "depth":
+			info.Depth = atoi()
+		case "seldepth":
+			info.SelDepth = atoi()
+		case "multipv":
+			info.MultiPV = atoi()
+		case "nodes":
+			info.Nodes = atoi64()
+		case "nps":
+			info.NPS = atoi64()
+		case "tbhits":
+			info.TBHits = atoi64()
+		case "hashfull":
+			info.HashFull = atoi()
+		case "time":
+			info.Time = time.Duration(atoi64()) * time.Millisecond
+		case "currmove":
+			info.CurrMove = next()
+		case "currmovenumber":
+			info.CurrMoveNumber = atoi()
+		case "score":
+			kind := next()
+			n, err := strconv.Atoi(next())
+			if err != nil || (kind != "cp" && kind != "mate") {
+				continue
+			}
+			info.Score = &Score{Mate: kind == "mate", Value: n}
+		case "lowerbound", "upperbound":
+			if info.Score != nil {
+				info.Score.Bound = fields[i]
+			}
+		case "wdl":
+			info.WDL = &WDL{Win: atoi(), Draw: atoi(), Loss: atoi()}
+		case "pv":
+			info.PV = append([]string(nil), fields[i+1:]...)
+			i = len(fields)
+		case "string":
+			info.String = strings.Join(fields[i+1:], " ")
+			i = len(fields)
+		}
+	}
+	return info, true
+}
+
+// ParseBestMove parses a "bestmove <move> [ponder <move>]" line.
+func ParseBestMove(line string) (move, ponder string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "bestmove" {
+		return "", "", false
+	}
+	if len(fields) > 3 && fields[2] == "ponder" {
+		ponder = fields[3]
+	}
+	return fields[1], ponder, true
+}
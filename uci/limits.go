@@ -0,0 +1,60 @@
+package uci
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits are the parameters of a "go" command. Zero fields are left out;
+// a zero Limits searches until the engine decides to stop.
+type Limits struct {
+	// SearchMoves restricts the search to these moves, in UCI notation.
+	SearchMoves []string
+	Ponder      bool
+	WTime       time.Duration
+	BTime       time.Duration
+	WInc        time.Duration
+	BInc        time.Duration
+	MovesToGo   int
+	Depth       int
+	Nodes       int64
+	Mate        int
+	MoveTime    time.Duration
+	Infinite    bool
+}
+
+// String returns the "go" command, e.g. "go depth 20".
+func (l Limits) String() string {
+	parts := []string{"go"}
+	add := func(name string, value int64) {
+		if value > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", name, value))
+		}
+	}
+
+	if l.Ponder {
+		parts = append(parts, "ponder")
+	}
+	// Clock times are sent even when zero if either side has a clock, as a
+	// flagged engine still needs to know it.
+	if l.WTime > 0 || l.BTime > 0 {
+		parts = append(parts,
+			fmt.Sprintf("wtime %d btime %d", l.WTime.Milliseconds(), l.BTime.Milliseconds()))
+	}
+	add("winc", l.WInc.Milliseconds())
+	add("binc", l.BInc.Milliseconds())
+	add("movestogo", int64(l.MovesToGo))
+	add("depth", int64(l.Depth))
+	add("nodes", l.Nodes)
+	add("mate", int64(l.Mate))
+	add("movetime", l.MoveTime.Milliseconds())
+	if l.Infinite {
+		parts = append(parts, "infinite")
+	}
+	// searchmoves takes the rest of the line.
+	if len(l.SearchMoves) > 0 {
+		parts = append(parts, "searchmoves "+strings.Join(l.SearchMoves, " "))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,149 @@
+package uci
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ID is what an engine reports about itself in its reply to "uci".
+type ID struct {
+	Name    string   `json:"id_name,omitempty"`
+	Author  string   `json:"id_author,omitempty"`
+	Options []Option `json:"options"`
+}
+
+// Option is one "option name ... type ..." line of a "uci" reply.
+type Option struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Default string   `json:"default,omitempty"`
+	Min     string   `json:"min,omitempty"`
+	Max     string   `json:"max,omitempty"`
+	Vars    []string `json:"vars,omitempty"`
+}
+
+// Option returns the option of that name, compared case-insensitively as
+// UCI option names are.
+func (id *ID) Option(name string) (Option, bool) {
+	for _, opt := range id.Options {
+		if strings.EqualFold(opt.Name, name) {
+			return opt, true
+		}
+	}
+	return Option{}, false
+}
+
+// Validate checks a "setoption" value against the option's type: spins
+// take an integer within min and max, checks "true" or "false", combos one
+// of their vars and buttons no value. Strings take anything.
+func (o Option) Validate(value string) error {
+	switch strings.ToLower(o.Type) {
+	case "spin":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("option %q takes an integer %s, not %q", o.Name, o.spinRange(), value)
+		}
+		min, errMin := strconv.ParseInt(o.Min, 10, 64)
+		max, errMax := strconv.ParseInt(o.Max, 10, 64)
+		if errMin == nil && n < min || errMax == nil && n > max {
+			return fmt.Errorf("option %q must be %s, not %d", o.Name, o.spinRange(), n)
+		}
+	case "check":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("option %q takes true or false, not %q", o.Name, value)
+		}
+	case "combo":
+		if !slices.ContainsFunc(o.Vars, func(v string) bool { return strings.EqualFold(v, value) }) {
+			return fmt.Errorf("option %q takes one of %s, not %q", o.Name, strings.Join(o.Vars, ", "), value)
+		}
+	case "button":
+		if value != "" {
+			return fmt.Errorf("option %q is a button and takes no value", o.Name)
+		}
+	}
+	return nil
+}
+
+func (o Option) spinRange() string {
+	switch {
+	case o.Min != "" && o.Max != "":
+		return fmt.Sprintf("between %s and %s", o.Min, o.Max)
+	case o.Min != "":
+		return "of at least " + o.Min
+	case o.Max != "":
+		return "of at most " + o.Max
+	}
+	return "of any size"
+}
+
+// ParseID extracts the "id" and "option" lines of a "uci" reply. Other
+// lines are ignored.
+func ParseID(lines []string) *ID {
+	id := &ID{Options: []Option{}}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "id name "):
+			id.Name = strings.TrimPrefix(line, "id name ")
+		case strings.HasPrefix(line, "id author "):
+			id.Author = strings.TrimPrefix(line, "id author ")
+		case strings.HasPrefix(line, "option "):
+			if opt, ok := ParseOption(line); ok {
+				id.Options = append(id.Options, opt)
+			}
+		}
+	}
+	return id
+}
+
+// ParseOption parses
+// "option name <id> type <t> [default <x>] [min <x>] [max <x>] [var <x>]*".
+// Values may contain spaces, so tokens are accumulated until the next
+// keyword. It reports false when the name or type is missing.
+func ParseOption(line string) (Option, bool) {
+	var (
+		opt     Option
+		keyword string
+		value   []string
+	)
+
+	flush := func() {
+		v := strings.Join(value, " ")
+		switch keyword {
+		case "name":
+			opt.Name = v
+		case "type":
+			opt.Type = v
+		case "default":
+			if v != "<empty>" {
+				opt.Default = v
+			}
+		case "min":
+			opt.Min = v
+		case "max":
+			opt.Max = v
+		case "var":
+			opt.Vars = append(opt.Vars, v)
+		}
+		value = value[:0]
+	}
+
+	for _, token := range strings.Fields(strings.TrimPrefix(line, "option")) {
+		switch token {
+		case "name", "type", "default", "min", "max", "var":
+			if keyword != "" {
+				flush()
+			}
+			keyword = token
+		default:
+			value = append(value, token)
+		}
+	}
+	if keyword != "" {
+		flush()
+	}
+
+	return opt, opt.Name != "" && opt.Type != ""
+}
@@ -0,0 +1,164 @@
+package uci_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+func TestParseInfo(t *testing.T) {
+	info, ok := uci.ParseInfo(
+		"info depth 24 seldepth 31 multipv 2 score mate -3 upperbound wdl 0 12 988 " +
+			"nodes 1234567 nps 987654 hashfull 412 tbhits 7 time 1250 pv e7e5 g1f3 b8c6",
+	)
+	if !ok {
+		t.Fatal("not parsed")
+	}
+	want := uci.Info{
+		Depth:    24,
+		SelDepth: 31,
+		MultiPV:  2,
+		Score:    &uci.Score{Mate: true, Value: -3, Bound: "upperbound"},
+		WDL:      &uci.WDL{Win: 0, Draw: 12, Loss: 988},
+		Nodes:    1234567,
+		NPS:      987654,
+		HashFull: 412,
+		TBHits:   7,
+		Time:     1250 * time.Millisecond,
+		PV:       []string{"e7e5", "g1f3", "b8c6"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("ParseInfo =\n%+v\nwant\n%+v", info, want)
+	}
+
+	info, _ = uci.ParseInfo("info depth 3 currmove e2e4 currmovenumber 1")
+	if info.MultiPV != 1 || info.Score != nil || info.CurrMove != "e2e4" || info.CurrMoveNumber != 1 {
+		t.Errorf("currmove info = %+v", info)
+	}
+
+	info, _ = uci.ParseInfo("info string NNUE evaluation using nn-1111.nnue enabled")
+	if info.String != "NNUE evaluation using nn-1111.nnue enabled" {
+		t.Errorf("String = %q", info.String)
+	}
+
+	if _, ok := uci.ParseInfo("bestmove e2e4"); ok {
+		t.Error("bestmove line parsed as info")
+	}
+}
+
+func TestParseBestMove(t *testing.T) {
+	tests := []struct {
+		line, move, ponder string
+		ok                 bool
+	}{
+		{"bestmove e2e4 ponder e7e5", "e2e4", "e7e5", true},
+		{"bestmove a7a8q", "a7a8q", "", true},
+		{"bestmove (none)", "(none)", "", true},
+		{"bestmove", "", "", false},
+		{"info depth 1", "", "", false},
+	}
+	for _, tt := range tests {
+		move, ponder, ok := uci.ParseBestMove(tt.line)
+		if move != tt.move || ponder != tt.ponder || ok != tt.ok {
+			t.Errorf("ParseBestMove(%q) = %q, %q, %v", tt.line, move, ponder, ok)
+		}
+	}
+}
+
+func TestParseOption(t *testing.T) {
+	tests := []struct {
+		line string
+		want uci.Option
+	}{
+		{
+			"option name Hash type spin default 16 min 1 max 33554432",
+			uci.Option{Name: "Hash", Type: "spin", Default: "16", Min: "1", Max: "33554432"},
+		},
+		{
+			"option name Clear Hash type button",
+			uci.Option{Name: "Clear Hash", Type: "button"},
+		},
+		{
+			"option name Debug Log File type string default <empty>",
+			uci.Option{Name: "Debug Log File", Type: "string"},
+		},
+		{
+			"option name Analysis Contempt type combo default Both var Off var White var Black var Both",
+			uci.Option{
+				Name:    "Analysis Contempt",
+				Type:    "combo",
+				Default: "Both",
+				Vars:    []string{"Off", "White", "Black", "Both"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, ok := uci.ParseOption(tt.line)
+		if !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseOption(%q) = %+v, %v", tt.line, got, ok)
+		}
+	}
+
+	if _, ok := uci.ParseOption("option name Orphan"); ok {
+		t.Error("option without type accepted")
+	}
+}
+
+func TestLimitsString(t *testing.T) {
+	tests := []struct {
+		limits uci.Limits
+		want   string
+	}{
+		{uci.Limits{}, "go"},
+		{uci.Limits{Depth: 20}, "go depth 20"},
+		{uci.Limits{MoveTime: 1500 * time.Millisecond}, "go movetime 1500"},
+		{uci.Limits{Infinite: true}, "go infinite"},
+		{
+			uci.Limits{WTime: time.Minute, BTime: 0, WInc: time.Second, BInc: time.Second, MovesToGo: 20},
+			"go wtime 60000 btime 0 winc 1000 binc 1000 movestogo 20",
+		},
+		{
+			uci.Limits{Ponder: true, Nodes: 100000, Mate: 3, SearchMoves: []string{"e2e4", "d2d4"}},
+			"go ponder nodes 100000 mate 3 searchmoves e2e4 d2d4",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.limits.String(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.limits, got, tt.want)
+		}
+	}
+}
+
+func TestOptionValidate(t *testing.T) {
+	hash := uci.Option{Name: "Hash", Type: "spin", Default: "16", Min: "1", Max: "33554432"}
+	contempt := uci.Option{Name: "Analysis Contempt", Type: "combo", Vars: []string{"Off", "White", "Black", "Both"}}
+	ponder := uci.Option{Name: "Ponder", Type: "check"}
+	clear := uci.Option{Name: "Clear Hash", Type: "button"}
+	evalFile := uci.Option{Name: "EvalFile", Type: "string"}
+
+	tests := []struct {
+		opt     uci.Option
+		value   string
+		wantErr string
+	}{
+		{hash, "256", ""},
+		{hash, "0", "between 1 and 33554432"},
+		{hash, "lots", "takes an integer"},
+		{contempt, "white", ""},
+		{contempt, "Always", "one of Off, White, Black, Both"},
+		{ponder, "true", ""},
+		{ponder, "yes", "true or false"},
+		{clear, "", ""},
+		{clear, "now", "takes no value"},
+		{evalFile, "nn-0123456789ab.nnue", ""},
+	}
+	for _, tt := range tests {
+		err := tt.opt.Validate(tt.value)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s = %q: %v, want %q", tt.opt.Name, tt.value, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Package ucitest provides a scriptable fake UCI engine for tests.
+//
+// The engine knows no chess: it answers the protocol, reports a few info
+// lines per search and plays a fixed best move. A Script makes it
+// misbehave in the ways real engines do: slow searches, crashes, garbage
+// output, a missing "bestmove" and stderr noise.
+//
+// It runs in process over pipes with Script.Client, or as a child process:
+// a test binary calls Main from TestMain, which takes over when the binary
+// is started with the arguments of Script.Command.
+package ucitest
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sonirico/mcp-stockfish/uci"
+)
+
+// RunArg is the first argument that turns a test binary into the fake
+// engine, see Main.
+const RunArg = "ucitest-engine"
+
+// CrashStatus is the exit status of a scripted crash.
+const CrashStatus = 3
+
+// Garbage lines are mixed into the output by Script.Garbage. None of them
+// ends a command.
+var Garbage = []string{
+	"",
+	"\x00\x01\x02 not uci at all",
+	"bestmov",
+	"info depth x score cp abc",
+	"readyok?",
+	"option name",
+	strings.Repeat("#", 4096),
+}
+
+// Options are the options the fake engine announces on "uci".
+var Options = []uci.Option{
+	{Name: "Threads", Type: "spin", Default: "1", Min: "1", Max: "1024"},
+	{Name: "Hash", Type: "spin", Default: "16", Min: "1", Max: "33554432"},
+	{Name: "MultiPV", Type: "spin", Default: "1", Min: "1", Max: "500"},
+	{Name: "UCI_ShowWDL", Type: "check", Default: "false"},
+	{Name: "UCI_Chess960", Type: "check", Default: "false"},
+	{Name: "Clear Hash", Type: "button"},
+}
+
+// Script describes how the fake engine behaves. The zero value is a well
+// behaved engine that searches instantly.
+type Script struct {
+	// Name is reported as "id name"; "FakeFish" when empty.
+	Name string
+	// BestMove is played by every search; "e2e4" when empty.
+	BestMove string
+	// Depth is the number of info lines per search; 3 when zero.
+	Depth int
+	// SearchTime is how long a search takes unless stopped.
+	SearchTime time.Duration
+	// ReadyDelay is how long the engine takes to answer "isready".
+	ReadyDelay time.Duration
+	// CrashOn makes the engine exit with CrashStatus as soon as it
+	// receives a command with this first word, e.g. "go".
+	CrashOn string
+	// NoBestMove makes searches end without "bestmove", even on "stop".
+	NoBestMove bool
+	// Garbage interleaves the Garbage lines with every reply.
+	Garbage bool
+	// StderrLines is the number of lines written to stderr on startup and
+	// for every command.
+	StderrLines int
+	// Log is a file that every received command is appended to.
+	Log string
+	// Network is reported at the start of every search the way Stockfish
+	// reports its NNUE network.
+	Network string
+	// Info holds further lines, separated by ";", reported at the end of
+	// every search, e.g. MultiPV lines.
+	Info string
+}
+
+func (s *Script) bind(fs *flag.FlagSet) {
+	fs.StringVar(&s.Name, "name", "", "id name")
+	fs.StringVar(&s.BestMove, "bestmove", "", "move played by every search")
+	fs.IntVar(&s.Depth, "depth", 0, "info lines per search")
+	fs.DurationVar(&s.SearchTime, "search-time", 0, "search duration")
+	fs.DurationVar(&s.ReadyDelay, "ready-delay", 0, "delay before readyok")
+	fs.StringVar(&s.CrashOn, "crash-on", "", "command that makes the engine exit")
+	fs.BoolVar(&s.NoBestMove, "no-bestmove", false, "never report a best move")
+	fs.BoolVar(&s.Garbage, "garbage", false, "mix garbage into the output")
+	fs.IntVar(&s.StderrLines, "stderr-lines", 0, "stderr lines per command")
+	fs.StringVar(&s.Log, "log", "", "file receiving every command")
+	fs.StringVar(&s.Network, "network", "", "NNUE network reported on every search")
+	fs.StringVar(&s.Info, "info", "", "lines reported at the end of every search, separated by ';'")
+}
+
+// ParseScript parses the arguments produced by Args.
+func ParseScript(args []string) (Script, error) {
+	var s Script
+	fs := flag.NewFlagSet(RunArg, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	s.bind(fs)
+	err := fs.Parse(args)
+	return s, err
+}
+
+// Args returns the script as command-line flags, leaving out zero values.
+func (s Script) Args() []string {
+	fs := flag.NewFlagSet(RunArg, flag.ContinueOnError)
+	var bound Script
+	bound.bind(fs)
+	// The flags point into bound, so they now read the script's values.
+	bound = s
+
+	var args []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if value := f.Value.String(); value != f.DefValue {
+			args = append(args, fmt.Sprintf("-%s=%s", f.Name, value))
+		}
+	})
+	return args
+}
+
+// Command returns the arguments that start the running test binary as the
+// fake engine: os.Args[0] with them, in a test whose TestMain calls Main.
+func (s Script) Command() (path string, args []string) {
+	return os.Args[0], append([]string{RunArg}, s.Args()...)
+}
+
+// Main runs the fake engine on the standard streams and exits when the
+// process was started with RunArg, and returns otherwise. Call it first
+// thing in TestMain.
+func Main() {
+	if len(os.Args) < 2 || os.Args[1] != RunArg {
+		return
+	}
+	script, err := ParseScript(os.Args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	os.Exit(script.Serve(os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Client starts the engine in process and returns a client connected to
+// it. The engine stops when the client is closed.
+func (s Script) Client() *uci.Client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		s.Serve(inR, outW, io.Discard)
+		// Like a process exiting: the output ends and input is refused.
+		outW.Close()
+		inR.Close()
+	}()
+	return uci.NewClient(inW, outR)
+}
+
+// engine is the state of one Serve call.
+type engine struct {
+	Script
+	mu     sync.Mutex
+	out    *bufio.Writer
+	errOut io.Writer
+	log    io.Writer
+
+	stop   chan struct{}
+	search sync.WaitGroup
+}
+
+// Serve speaks UCI on in and out until "quit", the end of in, or a
+// scripted crash, and returns the exit status.
+func (s Script) Serve(in io.Reader, out, errOut io.Writer) int {
+	e := &engine{Script: s, out: bufio.NewWriter(out), errOut: errOut}
+	if s.Log != "" {
+		f, err := os.OpenFile(s.Log, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return 2
+		}
+		defer f.Close()
+		e.log = f
+	}
+	defer e.stopSearch()
+
+	e.spam("startup")
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if e.log != nil {
+			fmt.Fprintln(e.log, line)
+		}
+		e.spam(fields[0])
+		if fields[0] == s.CrashOn {
+			return CrashStatus
+		}
+
+		switch fields[0] {
+		case "uci":
+			e.reply(e.uciReply()...)
+		case "isready":
+			time.Sleep(s.ReadyDelay)
+			e.reply("readyok")
+		case "go":
+			e.stopSearch()
+			e.startSearch()
+		case "stop":
+			e.stopSearch()
+		case "quit":
+			return 0
+		case "setoption", "position", "ucinewgame", "ponderhit", "debug", "register":
+		default:
+			e.reply("Unknown command: '" + line + "'. Type help for more information.")
+		}
+	}
+	return 0
+}
+
+func (e *engine) uciReply() []string {
+	name := e.Name
+	if name == "" {
+		name = "FakeFish"
+	}
+	lines := []string{"id name " + name, "id author ucitest"}
+	for _, opt := range Options {
+		line := fmt.Sprintf("option name %s type %s", opt.Name, opt.Type)
+		if opt.Type != "button" {
+			line += " default " + opt.Default
+		}
+		if opt.Min != "" {
+			line += fmt.Sprintf(" min %s max %s", opt.Min, opt.Max)
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, "uciok")
+}
+
+// reply writes lines, with garbage in front when scripted.
+func (e *engine) reply(lines ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Garbage {
+		for _, g := range Garbage {
+			e.out.WriteString(g + "\n")
+		}
+	}
+	for _, line := range lines {
+		e.out.WriteString(line + "\n")
+	}
+	e.out.Flush()
+}
+
+func (e *engine) spam(context string) {
+	for i := 0; i < e.StderrLines; i++ {
+		fmt.Fprintf(e.errOut, "stderr noise %d after %s %s\n", i, context, strings.Repeat(".", 64))
+	}
+}
+
+func (e *engine) startSearch() {
+	depth := e.Depth
+	if depth <= 0 {
+		depth = 3
+	}
+	move := e.BestMove
+	if move == "" {
+		move = "e2e4"
+	}
+	stop := make(chan struct{})
+	e.stop = stop
+
+	e.search.Add(1)
+	go func() {
+		defer e.search.Done()
+		if e.Network != "" {
+			e.reply("info string NNUE evaluation using " + e.Network + " enabled")
+		}
+		started := time.Now()
+		step := e.SearchTime / time.Duration(depth)
+		for d := 1; d <= depth; d++ {
+			select {
+			case <-stop:
+				d = depth
+				continue
+			case <-time.After(step):
+			}
+			e.reply(fmt.Sprintf(
+				"info depth %d seldepth %d multipv 1 score cp %d nodes %d nps 1000 time %d pv %s",
+				d, d, 10*d, 1000*d, time.Since(started).Milliseconds(), move,
+			))
+		}
+		if e.Info != "" {
+			for _, line := range strings.Split(e.Info, ";") {
+				e.reply(line)
+			}
+		}
+		if e.SearchTime > 0 {
+			// Wait out the search time unless stopped.
+			select {
+			case <-stop:
+			case <-time.After(e.SearchTime - time.Since(started)):
+			}
+		}
+		if !e.NoBestMove {
+			e.reply("bestmove " + move)
+		}
+	}()
+}
+
+func (e *engine) stopSearch() {
+	if e.stop != nil {
+		close(e.stop)
+		e.stop = nil
+	}
+	e.search.Wait()
+}